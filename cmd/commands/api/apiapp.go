@@ -80,12 +80,75 @@ host = lcoalhost:6391
 password = ********
 database = 0
 prefix = {{.Appname}}_
+# 模式: single(默认, 使用 host)、sentinel 哨兵、cluster 集群
+#mode = single
+# 哨兵: 主节点名称和哨兵地址
+#master_name = mymaster
+#sentinel_addrs = 10.0.0.2:26379,10.0.0.3:26379,10.0.0.4:26379
+# 集群: 节点地址, 集群模式不使用 database; 是否从从节点读
+#cluster_nodes = 10.0.0.2:6379,10.0.0.3:6379,10.0.0.4:6379
+#cluster_read_only = false
+# TLS 连接; 跳过证书校验仅用于测试
+#tls = false
+#tls_skip_verify = false
+# 连接池: 最大连接数、最少空闲连接数; 超时秒数
+#pool_size = 100
+#min_idle_conns = 10
+#dial_timeout = 5
+#read_timeout = 3
+#write_timeout = 3
+#pool_timeout = 4
+#idle_timeout = 300
+#max_retries = 0
 
 [db]
 host = localhost:3306
 user = {{.Appname}}
 password = ******
 database = {{.Appname}}_db
+# 从库, 读请求轮询可用的从库
+#replicas = 10.0.0.2:3306,10.0.0.3:3306
+# 查询超时秒数, 请求超时或客户端断开时中止查询
+#query_timeout = 5
+# 驱动: mysql(默认)、postgres、sqlite3, 非 mysql 时需在 main 中引入对应的驱动
+#driver = mysql
+# 其它连接参数
+#params = collation=utf8mb4_general_ci&readTimeout=10s
+#timezone = Local
+# 连接池: 最大连接数、最大空闲连接数、连接最长使用秒数
+#max_open_conns = 100
+#max_idle_conns = 10
+#conn_max_lifetime = 3600
+# 启动时连接失败的重试次数和首次重试间隔秒数, 间隔逐次翻倍
+#connect_retries = 3
+#connect_retry_interval = 1
+# sql日志: off、slow 只记录慢查询、all 记录全部; 慢查询秒数; 是否记录查询参数
+#query_log = slow
+#slow_query_threshold = 1
+#query_log_vars = false
+# 游标分页的签名密钥, 多个节点需相同, 未配置时每次启动随机生成并记录警告, 重启后旧游标失效
+#cursor_secret = change-me
+
+# 命名连接, 通过 db.Use("report") 使用
+#[db.report]
+#host = localhost:3306
+#user = {{.Appname}}
+#password = ******
+#database = {{.Appname}}_report
+
+# 限流, 每个IP、每个已登录用户的请求频率, 如 600/m; 路由的限流见 pkg/middle-wares/ratelimit.go
+#[ratelimit]
+#ip = 600/m
+#user = 300/m
+# 部署在代理之后时开启, 从 X-Forwarded-For 取客户端IP
+#trust_proxy = false
+# redis 未连接或出错时是否放行, 关闭后按超出限流返回 429
+#fail_open = true
+
+# 模型缓存, 表名 = 缓存秒数, 未配置的表不缓存
+#[cache]
+#dict_area = 3600
+#member = 300
 
 [smtp]
 host = smtp.163.com
@@ -109,19 +172,27 @@ password = ****
 var apiMaingo = `package main
 
 import (
+	"{{.Appname}}/commands"
 	middleWares "{{.Appname}}/pkg/middle-wares"
 	_ "{{.Appname}}/routers"
 	HealthChecks "{{.Appname}}/service-logics/health-checks"
+	"flag"
+	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/astaxie/beego"
 	"github.com/astaxie/beego/plugins/cors"
 	"github.com/astaxie/beego/toolbox"
 	"github.com/astaxie/beego/utils"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
+	"github.com/yimishiji/bee/pkg/cron"
 	//"github.com/yimishiji/bee/pkg/db"
 	"github.com/yimishiji/bee/pkg/errors"
+	"github.com/yimishiji/bee/pkg/queue"
 )
 
 func init() {
@@ -151,6 +222,13 @@ func init() {
 }
 
 func main() {
+	//运行模式: api 提供接口; cron 执行定时任务和队列消费, 带参数时查看或执行任务:
+	//	./{{.Appname}} -run-mode=cron list
+	//	./{{.Appname}} -run-mode=cron run <name>
+	//	./{{.Appname}} -run-mode=cron history <name>
+	runMode := flag.String("run-mode", "api", "app run mode: api or cron")
+	flag.Parse()
+
 	if beego.BConfig.RunMode == "dev" {
 		beego.BConfig.WebConfig.DirectoryIndex = true
 		beego.BConfig.WebConfig.StaticDir[beego.AppConfig.String("DocsPath")] = "swagger"
@@ -161,12 +239,24 @@ func main() {
 		beego.LoadAppConfig("ini", localConf)
 	}
 
+	if *runMode == "cron" {
+		commands.RegisterCrontab()
+		if flag.NArg() > 0 {
+			if err := cron.Command(flag.Args(), os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		runCron()
+		return
+	}
+
 	//跨域请求配置
 	beego.InsertFilter("*", beego.BeforeRouter, cors.Allow(&cors.Options{
 		AllowAllOrigins:  true,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Authorization", "Access-Control-Allow-Origin", "Access-Control-Allow-Headers", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length", "Access-Control-Allow-Origin", "Access-Control-Allow-Headers", "Content-Type"},
+		ExposeHeaders:    []string{"Content-Length", "Access-Control-Allow-Origin", "Access-Control-Allow-Headers", "Content-Type", "X-RateLimit-Limit", "X-RateLimit-Window", "X-RateLimit-Remaining", "Retry-After"},
 		AllowCredentials: true,
 	}))
 
@@ -184,6 +274,60 @@ func MeiHuHandler(handler http.Handler) http.Handler {
 	return middleWares.NewMeiHuMiddleWare(handler)
 }
 
+//执行定时任务和队列消费, 收到退出信号后等待执行中的任务结束
+func runCron() {
+	commands.RegisterQueue()
+	if err := cron.Start(); err != nil {
+		beego.Error(err)
+		os.Exit(1)
+	}
+	queue.StartAll()
+	beego.Info("cron mode started")
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+	beego.Info("cron mode stopping")
+	cron.Stop(30 * time.Second)
+	queue.StopAll(30 * time.Second)
+}
+
+`
+
+var commandsCrontab = `package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/yimishiji/bee/pkg/cron"
+)
+
+//定时任务, -run-mode=cron 时执行
+//Single 为 true 时多节点只在一个节点执行; Timeout 超时后 ctx 取消; 上一次未结束时本次跳过
+func RegisterCrontab() {
+	cron.MustRegister(
+		cron.Job{
+			Name:        "heartbeat",
+			Spec:        "0 */10 * * * *",
+			Timeout:     time.Minute,
+			Description: "示例任务, 每10分钟输出一次日志",
+			Run: func(ctx context.Context) error {
+				beego.Info("cron heartbeat")
+				return nil
+			},
+		},
+	)
+}
+`
+
+var commandsQueue = `package commands
+
+//队列消费者, -run-mode=cron 时启动
+//bee generate job <name> 生成任务后在这里注册, 如 SendSmsJob.Register()
+func RegisterQueue() {
+}
 `
 
 var apiMainconngo = `package main
@@ -704,6 +848,23 @@ func (this *MeiHuMiddleWare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	//把url中的横杠（-）去掉
 	//r.URL.Path = strings.Replace(r.URL.Path, "-", "", -1)
 
+	//限流, 超出时返回 429 和 Retry-After
+	if checkRateLimit(w, r) == false {
+		resList := &base.Resp{
+			TimeTaken: 0,
+			Status:    base.ApiCode_RATE_LIMITED,
+			StatusTxt: "too many requests",
+			Results: []struct {
+			}{},
+			Links: "",
+		}
+		resList.Time = time.Now().Unix()
+		newByte, _ := json.Marshal(resList)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write(newByte)
+		return
+	}
+
 	//验证操作权限
 	if this.checkOperate(r) == false {
 		//数据组装
@@ -850,30 +1011,79 @@ func isAllowAllTokenUrl(url string) bool {
 	return false
 }
 `
-var UserServiceTpl = `package UserService
+var middleWaresRateLimit = `package middleWares
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/astaxie/beego"
 	"github.com/yimishiji/bee/pkg/base"
-	"github.com/yimishiji/bee/pkg/db"
+	"github.com/yimishiji/bee/pkg/ratelimit"
+)
+
+//按路由限流, 键同权限的格式 [METHOD]/path, 值如 10/m、100/h、5/10s
+//每个已登录用户或IP分别计数; 按IP、按用户的限流在 app.conf 的 [ratelimit] 中配置
+var rateLimitUrlList = map[string]string{
+	"[POST]/user/login": "10/m",
+}
+
+var rateLimitRules *ratelimit.Rules
+
+func init() {
+	rules, err := ratelimit.RulesFromConfig(rateLimitUrlList)
+	if err != nil {
+		beego.Error("ratelimit config:", err)
+		return
+	}
+	rateLimitRules = rules
+}
+
+//检查限流并写入 X-RateLimit-* 响应头, 超出时返回 false
+func checkRateLimit(w http.ResponseWriter, r *http.Request) bool {
+	if rateLimitRules == nil || r.Method == "OPTIONS" {
+		return true
+	}
+	res, ok := rateLimitRules.Check(getOperateKey(r), rateLimitRules.ClientIP(r), rateLimitUser(r))
+	if !ok {
+		return true
+	}
+	ratelimit.SetHeaders(w, res)
+	return res.Allowed
+}
+
+//token 有效时返回用户id, 否则为空按IP计数; 不使用未验证的 token, 避免换 token 绕过限流
+func rateLimitUser(r *http.Request) string {
+	token := strings.Replace(r.Header.Get("Authorization"), "Bearer ", "", 1)
+	if token == "" {
+		return ""
+	}
+	info, err := base.Tokens.UserInfo(token)
+	if err != nil || info == nil {
+		return ""
+	}
+	return strconv.Itoa(info.UserID)
+}
+`
+var UserServiceTpl = `package UserService
+
+import (
 	"github.com/astaxie/beego"
-	"encoding/json"
+	"github.com/yimishiji/bee/pkg/base"
 )
 
 type RoleRight struct {
 	RightAction string
 }
 
-//token登录
+//token登录, 登录信息取自 base.Tokens, 测试中可替换为 base.NewMemoryTokenStore()
 func LoginByAccessToken(token string) bool {
 	if token == "" {
 		return false
 	}
 
-	user := new(base.User)
-	user.AccessToken = token
-
-	userInfoCacheKey := token + ":info"
-	if err := db.Redis.Get(userInfoCacheKey).Err(); err == nil {
+	if _, err := base.Tokens.UserInfo(token); err == nil {
 		return true
 	}
 
@@ -910,14 +1120,12 @@ func LoginByAccessToken(token string) bool {
 // 获取用户的操作权限
 func GetOperateListByAccesstoken(token string) (operateList []RoleRight, err error) {
 
-	token = token + ":right"
-	data, err := db.Redis.Get(token).Result()
+	rights, err := base.Tokens.Rights(token)
 	if err != nil {
 		return operateList, err
 	}
-	err = json.Unmarshal([]byte(data), &operateList)
-	if err != nil {
-		beego.Error("some error")
+	for _, right := range rights {
+		operateList = append(operateList, RoleRight{RightAction: right})
 	}
 
 	if beego.BConfig.RunMode == "prod" {
@@ -1114,6 +1322,12 @@ func createAPI(cmd *commands.Command, args []string) int {
 		utils.WriteToFile(path.Join(appPath, "main.go"),
 			strings.Replace(apiMaingo, "{{.Appname}}", packPath, -1))
 
+		os.Mkdir(path.Join(appPath, "commands"), 0755)
+		fmt.Fprintf(output, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", path.Join(appPath, "commands", "crontab.go"), "\x1b[0m")
+		utils.WriteToFile(path.Join(appPath, "commands", "crontab.go"), commandsCrontab)
+		fmt.Fprintf(output, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", path.Join(appPath, "commands", "queue.go"), "\x1b[0m")
+		utils.WriteToFile(path.Join(appPath, "commands", "queue.go"), commandsQueue)
+
 		os.Mkdir(path.Join(appPath, "pkg"), 0755)
 		os.Mkdir(path.Join(appPath, "pkg", "middle-wares"), 0755)
 		fmt.Fprintf(output, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", path.Join(appPath, "pkg", "middle-wares", "middleware.go"), "\x1b[0m")
@@ -1126,6 +1340,9 @@ func createAPI(cmd *commands.Command, args []string) int {
 		fmt.Fprintf(output, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", path.Join(appPath, "pkg", "middle-wares", "allow-token.go"), "\x1b[0m")
 		utils.WriteToFile(path.Join(appPath, "pkg", "middle-wares", "allow-token.go"), middleWaresMainAlltoken)
 
+		fmt.Fprintf(output, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", path.Join(appPath, "pkg", "middle-wares", "ratelimit.go"), "\x1b[0m")
+		utils.WriteToFile(path.Join(appPath, "pkg", "middle-wares", "ratelimit.go"), middleWaresRateLimit)
+
 		os.Mkdir(path.Join(appPath, "service-logics"), 0755)
 		os.Mkdir(path.Join(appPath, "service-logics", "user"), 0755)
 		fmt.Fprintf(output, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", path.Join(appPath, "service-logics", "user", "user.go"), "\x1b[0m")
@@ -35,6 +35,7 @@ var Conf = struct {
 	Envs               []string
 	Bale               bale
 	Database           database
+	Appcode            appcode
 	EnableReload       bool              `json:"enable_reload" yaml:"enable_reload"`
 	EnableNotification bool              `json:"enable_notification" yaml:"enable_notification"`
 	Scripts            map[string]string `json:"scripts" yaml:"scripts"`
@@ -54,6 +55,11 @@ var Conf = struct {
 	Database: database{
 		Driver: "mysql",
 	},
+	Appcode: appcode{
+		ColumnTypes: map[string]string{},
+		Connections: map[string]string{},
+		Hidden:      []string{},
+	},
 	EnableNotification: true,
 	Scripts:            map[string]string{},
 }
@@ -80,6 +86,16 @@ type database struct {
 	Dir    string
 }
 
+// appcode holds the table/column options used by 'bee generate appcode'
+type appcode struct {
+	// "table.column": "[*]import/path.Type", overrides the generated Go type of a column
+	ColumnTypes map[string]string `json:"column_types" yaml:"column_types"`
+	// "table": "name", binds the generated model to the [db.<name>] connection
+	Connections map[string]string `json:"connections" yaml:"connections"`
+	// "column" or "table.column" patterns of the columns left out of the generated responses
+	Hidden []string `json:"hidden" yaml:"hidden"`
+}
+
 // LoadConfig loads the bee tool configuration.
 // It looks for Beefile or bee.json in the current path,
 // and falls back to default configuration in case not found.
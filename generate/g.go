@@ -22,3 +22,14 @@ var Level utils.DocValue
 var Tables utils.DocValue
 var Fields utils.DocValue
 var DDL utils.DocValue
+var Hidden utils.DocValue
+
+// ColumnTypes overrides the Go type generated for a column, keyed by "table.column"
+var ColumnTypes map[string]string
+
+// TableConnections binds the generated model of a table to a named connection, keyed by table name
+var TableConnections map[string]string
+
+// HiddenColumns lists the "column" or "table.column" patterns left out of the generated responses,
+// in addition to hidden.DefaultColumns
+var HiddenColumns []string
@@ -21,12 +21,14 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 	beeLogger "github.com/yimishiji/bee/logger"
 	"github.com/yimishiji/bee/logger/colors"
+	"github.com/yimishiji/bee/pkg/hidden"
 	strings2 "github.com/yimishiji/bee/pkg/strings"
 	"github.com/yimishiji/bee/utils"
 )
@@ -66,6 +68,7 @@ type MvcPath struct {
 	RouterPath     string
 	VuePath        string
 	FilterPath     string
+	ServicePath    string
 }
 
 // typeMapping maps SQL data type to corresponding Go data type
@@ -102,11 +105,11 @@ var typeMappingMysql = map[string]string{
 	"time":               "time.Time",
 	"float":              "float32", // float & decimal
 	"double":             "float64",
-	"decimal":            "float64",
+	"decimal":            "types.Decimal",
 	"binary":             "string", // binary
 	"varbinary":          "string",
 	"year":               "int16",
-	"json":               "string", // json
+	"json":               "types.JSON", // json
 }
 
 // typeMappingPostgres maps SQL data type to corresponding Go data type
@@ -130,17 +133,17 @@ var typeMappingPostgres = map[string]string{
 	"interval":                    "string",  // time interval, string for now
 	"real":                        "float32", // float & decimal
 	"double precision":            "float64",
-	"decimal":                     "float64",
-	"numeric":                     "float64",
-	"money":                       "float64", // money
-	"bytea":                       "string",  // binary
-	"tsvector":                    "string",  // fulltext
-	"ARRAY":                       "string",  // array
-	"USER-DEFINED":                "string",  // user defined
-	"uuid":                        "string",  // uuid
-	"json":                        "string",  // json
-	"jsonb":                       "string",  // jsonb
-	"inet":                        "string",  // ip address
+	"decimal":                     "types.Decimal",
+	"numeric":                     "types.Decimal",
+	"money":                       "types.Decimal", // money
+	"bytea":                       "string",        // binary
+	"tsvector":                    "string",        // fulltext
+	"ARRAY":                       "string",        // array
+	"USER-DEFINED":                "string",        // user defined
+	"uuid":                        "string",        // uuid
+	"json":                        "types.JSON",    // json
+	"jsonb":                       "types.JSON",    // jsonb
+	"inet":                        "string",        // ip address
 }
 
 // Table represent a table in a database
@@ -158,6 +161,15 @@ type Column struct {
 	Name string
 	Type string
 	Tag  *OrmTag
+
+	// import spec required by Type, e.g. JsonTypes "app/models/json-types"
+	ImportPkg string
+
+	// raw column definition as reported by information_schema
+	DataType      string
+	ColumnType    string
+	Nullable      bool
+	ColumnDefault string
 }
 
 // ForeignKey represents a foreign key column for a table
@@ -262,7 +274,7 @@ func (tag *OrmTag) String() string {
 		return ""
 	}
 	if tag.Comment != "" {
-		return fmt.Sprintf("`json:\"%s\" gorm:\"%s\" description:\"%s\"`", tag.Column, strings.Join(ormOptions, ";"), tag.Comment)
+		return fmt.Sprintf("`json:\"%s\" gorm:\"%s\" description:\"%s\"`", tag.Column, strings.Join(ormOptions, ";"), tagComment(tag.Comment))
 	}
 	return fmt.Sprintf("`json:\"%s\" gorm:\"%s\"`", tag.Column, strings.Join(ormOptions, ";"))
 }
@@ -309,14 +321,7 @@ func gen(dbms, connStr string, mode byte, selectedTableNames map[string]bool, ap
 	defer db.Close()
 	if trans, ok := dbDriver[dbms]; ok {
 		beeLogger.Log.Info("Analyzing database tables...")
-		var tableNames []string
-		if len(selectedTableNames) != 0 {
-			for tableName := range selectedTableNames {
-				tableNames = append(tableNames, tableName)
-			}
-		} else {
-			tableNames = trans.GetTableNames(db)
-		}
+		tableNames := getSelectedTableNames(selectedTableNames, db, trans)
 		tables := getTableObjects(tableNames, db, trans)
 		mvcPath := new(MvcPath)
 		mvcPath.ModelPath = path.Join(apppath, "models")
@@ -324,6 +329,7 @@ func gen(dbms, connStr string, mode byte, selectedTableNames map[string]bool, ap
 		mvcPath.RouterPath = path.Join(apppath, "routers")
 		mvcPath.VuePath = path.Join(apppath, "vue/src/components")
 		mvcPath.FilterPath = path.Join(apppath, "filters")
+		mvcPath.ServicePath = path.Join(apppath, "service-logics")
 
 		//算成vue文件目录
 		mkdirs(apppath, "vue", "src", "components")
@@ -353,6 +359,18 @@ func (*MysqlDB) GetTableNames(db *sql.DB) (tables []string) {
 	return
 }
 
+// getSelectedTableNames returns the tables picked by -tables, or every table
+// in the current database when no table was selected
+func getSelectedTableNames(selectedTableNames map[string]bool, db *sql.DB, dbTransformer DbTransformer) (tableNames []string) {
+	if len(selectedTableNames) == 0 {
+		return dbTransformer.GetTableNames(db)
+	}
+	for tableName := range selectedTableNames {
+		tableNames = append(tableNames, tableName)
+	}
+	return
+}
+
 // getTableObjects process each table name
 func getTableObjects(tableNames []string, db *sql.DB, dbTransformer DbTransformer) (tables []*Table) {
 	// if a table has a composite pk or doesn't have pk, we can't use it yet
@@ -371,10 +389,121 @@ func getTableObjects(tableNames []string, db *sql.DB, dbTransformer DbTransforme
 	// process columns, ignoring blacklisted tables
 	for _, tb := range tables {
 		dbTransformer.GetColumns(db, tb, blackList)
+		applyNullTypes(tb)
+		applyColumnTypes(tb)
+	}
+	return
+}
+
+// nullTypeMapping maps the Go type of a nullable column to its pkg/types nullable type
+var nullTypeMapping = map[string]string{
+	"string":        "types.NullString",
+	"int":           "types.NullInt64",
+	"int8":          "types.NullInt64",
+	"int16":         "types.NullInt64",
+	"int32":         "types.NullInt64",
+	"int64":         "types.NullInt64",
+	"uint":          "types.NullUint64",
+	"uint8":         "types.NullInt64",
+	"uint16":        "types.NullInt64",
+	"uint32":        "types.NullInt64",
+	"uint64":        "types.NullUint64",
+	"float32":       "types.NullFloat64",
+	"float64":       "types.NullFloat64",
+	"bool":          "types.NullBool",
+	"time.Time":     "types.NullTime",
+	"types.Decimal": "types.NullDecimal",
+}
+
+// applyNullTypes replaces the Go type of nullable columns, so that NULL is not read as a zero value
+func applyNullTypes(tb *Table) {
+	for _, col := range tb.Columns {
+		if !col.Tag.Null {
+			continue
+		}
+		if nullType, ok := nullTypeMapping[col.Type]; ok {
+			col.Type = nullType
+		}
+	}
+}
+
+// isNullType reports whether t is one of the pkg/types nullable types
+func isNullType(t string) bool {
+	for _, nullType := range nullTypeMapping {
+		if t == nullType {
+			return true
+		}
+	}
+	return t == "types.JSON"
+}
+
+// applyColumnTypes replaces the Go type of the columns configured in ColumnTypes
+func applyColumnTypes(tb *Table) {
+	for _, col := range tb.Columns {
+		if spec, ok := ColumnTypes[tb.Name+"."+col.Tag.Column]; ok && spec != "" {
+			col.Type, col.ImportPkg = parseColumnType(spec)
+		}
+	}
+}
+
+// parseColumnType parses a "[*]import/path.Type" column type override.
+// e.g. *app/models/json-types.OrderExtra => *JsonTypes.OrderExtra, JsonTypes "app/models/json-types"
+func parseColumnType(spec string) (goType string, importPkg string) {
+	ptr := ""
+	if strings.HasPrefix(spec, "*") {
+		ptr, spec = "*", spec[1:]
+	}
+	slash := strings.LastIndex(spec, "/")
+	dot := strings.LastIndex(spec, ".")
+	if slash == -1 || dot < slash {
+		return ptr + spec, ""
+	}
+	pkgPath, typeName := spec[:dot], spec[dot+1:]
+	alias := utils.CamelCase(strings.Replace(path.Base(pkgPath), "-", "_", -1))
+	return ptr + alias + "." + typeName, fmt.Sprintf("%s \"%s\"", alias, pkgPath)
+}
+
+// importPkgs returns the import specs required by the Go types of cols
+func importPkgs(cols []*Column) (pkgs []string) {
+	seen := make(map[string]bool)
+	add := func(pkg string) {
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	for _, col := range cols {
+		goType := strings.TrimLeft(col.Type, "*[]")
+		if strings.HasPrefix(goType, "time.") {
+			add("\"time\"")
+		}
+		if strings.HasPrefix(goType, "types.") {
+			add("\"github.com/yimishiji/bee/pkg/types\"")
+		}
+		if col.ImportPkg != "" {
+			add(col.ImportPkg)
+		}
 	}
+	sort.Strings(pkgs)
 	return
 }
 
+// importSpecs renders import specs to be placed inside an existing import block
+func importSpecs(pkgs []string) string {
+	if len(pkgs) == 0 {
+		return ""
+	}
+	return strings.Join(pkgs, "\n") + "\n"
+}
+
+// importDecl renders import specs as a standalone import declaration
+func importDecl(pkgs []string) string {
+	if len(pkgs) == 0 {
+		return ""
+	}
+	return "import (\n" + importSpecs(pkgs) + ")\n"
+}
+
 // GetConstraints gets primary key, unique key and foreign keys of a table from
 // information_schema and fill in the Table struct
 func (*MysqlDB) GetConstraints(db *sql.DB, table *Table, blackList map[string]bool) {
@@ -455,6 +584,11 @@ func (mysqlDB *MysqlDB) GetColumns(db *sql.DB, table *Table, blackList map[strin
 			beeLogger.Log.Fatalf("%s", err)
 		}
 
+		col.DataType = dataType
+		col.ColumnType = columnType
+		col.Nullable = isNullable == "YES"
+		col.ColumnDefault = columnDefault
+
 		// Tag info
 		tag := new(OrmTag)
 		tag.Column = colName
@@ -627,7 +761,8 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 			END AS column_type,
 			is_nullable,
 			column_default,
-			'' AS extra
+			'' AS extra,
+			col_description((quote_ident(table_schema) || '.' || quote_ident(table_name))::regclass::oid, ordinal_position) AS column_comment
 		FROM
 			information_schema.columns
 		WHERE
@@ -641,12 +776,12 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 
 	for colDefRows.Next() {
 		// datatype as bytes so that SQL <null> values can be retrieved
-		var colNameBytes, dataTypeBytes, columnTypeBytes, isNullableBytes, columnDefaultBytes, extraBytes []byte
-		if err := colDefRows.Scan(&colNameBytes, &dataTypeBytes, &columnTypeBytes, &isNullableBytes, &columnDefaultBytes, &extraBytes); err != nil {
+		var colNameBytes, dataTypeBytes, columnTypeBytes, isNullableBytes, columnDefaultBytes, extraBytes, columnCommentBytes []byte
+		if err := colDefRows.Scan(&colNameBytes, &dataTypeBytes, &columnTypeBytes, &isNullableBytes, &columnDefaultBytes, &extraBytes, &columnCommentBytes); err != nil {
 			beeLogger.Log.Fatalf("Could not query INFORMATION_SCHEMA for column information: %s", err)
 		}
-		colName, dataType, columnType, isNullable, columnDefault, extra :=
-			string(colNameBytes), string(dataTypeBytes), string(columnTypeBytes), string(isNullableBytes), string(columnDefaultBytes), string(extraBytes)
+		colName, dataType, columnType, isNullable, columnDefault, extra, columnComment :=
+			string(colNameBytes), string(dataTypeBytes), string(columnTypeBytes), string(isNullableBytes), string(columnDefaultBytes), string(extraBytes), string(columnCommentBytes)
 		// Create a column
 		col := new(Column)
 		col.Name = utils.CamelCase(colName)
@@ -654,10 +789,15 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 		if err != nil {
 			beeLogger.Log.Fatalf("%s", err)
 		}
+		col.DataType = dataType
+		col.ColumnType = columnType
+		col.Nullable = isNullable == "YES"
+		col.ColumnDefault = columnDefault
 
 		// Tag info
 		tag := new(OrmTag)
 		tag.Column = colName
+		tag.Comment = columnComment
 		if table.Pk == colName {
 			col.Name = "Id"
 			col.Type = "int"
@@ -732,6 +872,7 @@ func createPaths(mode byte, paths *MvcPath) {
 	if (mode & OController) == OController {
 		os.Mkdir(paths.ControllerPath, 0777)
 		os.Mkdir(paths.FilterPath, 0777)
+		os.Mkdir(paths.ServicePath, 0777)
 	}
 	if (mode & ORouter) == ORouter {
 		os.Mkdir(paths.RouterPath, 0777)
@@ -758,6 +899,9 @@ func writeSourceFiles(pkgPath string, tables []*Table, mode byte, paths *MvcPath
 		beeLogger.Log.Info("Creating filter files...")
 		writeFilterFiles(tables, paths.FilterPath, pkgPath)
 
+		beeLogger.Log.Info("Creating service files...")
+		writeServiceFiles(tables, paths.ServicePath, pkgPath)
+
 	}
 	if (ORouter & mode) == ORouter {
 		beeLogger.Log.Info("Creating router files...")
@@ -829,16 +973,13 @@ func writeModelFiles(tables []*Table, mPath string, pkgPath string) {
 		fileStr = strings.Replace(fileStr, "{{modelName}}", utils.CamelCase(tb.Name), -1)
 		fileStr = strings.Replace(fileStr, "{{tableName}}", tb.Name, -1)
 		fileStr = strings.Replace(fileStr, "{{pkgPath}}", pkgPath, -1)
+		fileStr = strings.Replace(fileStr, "{{connection}}", TableConnections[tb.Name], -1)
+		fileStr = replaceResponse(fileStr, tb, tables)
+		fileStr = strings.Replace(fileStr, "{{relations}}", relationsCode(tb, tables), -1)
 
-		// If table contains time field, import time.Time package
-		timePkg := ""
-		importTimePkg := ""
-		if tb.ImportTimePkg {
-			timePkg = "\"time\"\n"
-			importTimePkg = "import \"time\"\n"
-		}
-		fileStr = strings.Replace(fileStr, "{{timePkg}}", timePkg, -1)
-		fileStr = strings.Replace(fileStr, "{{importTimePkg}}", importTimePkg, -1)
+		// import the packages of time, json... field types
+		importPkg := importDecl(importPkgs(tb.Columns))
+		fileStr = strings.Replace(fileStr, "{{importPkg}}", importPkg, -1)
 		if _, err := f.WriteString(fileStr); err != nil {
 			beeLogger.Log.Fatalf("Could not write model file to '%s': %s", fpath, err)
 		}
@@ -870,7 +1011,7 @@ func writeModelFiles(tables []*Table, mPath string, pkgPath string) {
 		fileStr = strings.Replace(ModelBaseTPL, "{{modelStruct}}", tbString, 1)
 		fileStr = strings.Replace(fileStr, "{{modelName}}", utils.CamelCase(tb.Name), -1)
 		fileStr = strings.Replace(fileStr, "{{tableName}}", tb.Name, -1)
-		fileStr = strings.Replace(fileStr, "{{importTimePkg}}", importTimePkg, -1)
+		fileStr = strings.Replace(fileStr, "{{importPkg}}", importPkg, -1)
 
 		if _, err := f.WriteString(fileStr); err != nil {
 			beeLogger.Log.Fatalf("Could not write model file to '%s': %s", fpath, err)
@@ -881,6 +1022,116 @@ func writeModelFiles(tables []*Table, mPath string, pkgPath string) {
 	}
 }
 
+var tagCommentReplacer = strings.NewReplacer("`", "'", `\`, `\\`, `"`, `\"`, "\r\n", " ", "\n", " ", "\r", " ")
+
+// tagComment makes a column comment safe to use as the value of a struct tag in a raw string
+func tagComment(comment string) string {
+	return tagCommentReplacer.Replace(comment)
+}
+
+// isHiddenColumn reports whether the column of the table matches hidden.DefaultColumns or HiddenColumns.
+// A pattern is a column name or "table.column", both may use path.Match wildcards.
+func isHiddenColumn(table, column string) bool {
+	return hidden.Match(table, column, HiddenColumns...)
+}
+
+// relationsCode returns the db.Relation entries of the foreign keys of tb whose
+// referenced table is generated too, named after the column without its _id suffix
+func relationsCode(tb *Table, tables []*Table) string {
+	generated := make(map[string]bool)
+	for _, t := range tables {
+		generated[t.Name] = true
+	}
+	var columns []string
+	for column, fk := range tb.Fk {
+		if generated[fk.RefTable] {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+
+	var code string
+	for _, column := range columns {
+		fk := tb.Fk[column]
+		code += fmt.Sprintf("\t{Name: %q, Table: %q, ForeignKey: %q, References: %q, Model: &TableStructs.%s{}},\n",
+			utils.CamelCase(strings.TrimSuffix(column, "_id")), fk.RefTable, column, fk.RefColumn, utils.CamelCase(fk.RefTable))
+	}
+	return code
+}
+
+// replaceResponse fills the Response struct of the model template with the visible columns of tb.
+// Foreign key columns hold the referenced struct in the table struct; the response carries
+// only the referenced id, so the hidden columns of the referenced table are never exposed
+func replaceResponse(fileStr string, tb *Table, tables []*Table) string {
+	var fields, values, fkValues, hidden []string
+	var visible []*Column
+	for _, col := range tb.Columns {
+		if isHiddenColumn(tb.Name, col.Tag.Column) {
+			hidden = append(hidden, col.Tag.Column)
+			continue
+		}
+		tag := fmt.Sprintf("`json:\"%s\"`", col.Tag.Column)
+		if col.Tag.Comment != "" {
+			tag = fmt.Sprintf("`json:\"%s\" description:\"%s\"`", col.Tag.Column, tagComment(col.Tag.Comment))
+		}
+		if fk, ok := tb.Fk[col.Tag.Column]; ok && col.Tag.RelFk {
+			ref := referencedColumn(fk, tables)
+			fields = append(fields, fmt.Sprintf("%s %s %s", col.Name, ref.Type, tag))
+			fkValues = append(fkValues, fmt.Sprintf("if m.%s != nil {\n\t\tr.%s = m.%s.%s\n\t}\n\t", col.Name, col.Name, col.Name, ref.Name))
+			visible = append(visible, ref)
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%s %s %s", col.Name, col.Type, tag))
+		values = append(values, fmt.Sprintf("%s: m.%s,", col.Name, col.Name))
+		visible = append(visible, col)
+	}
+
+	hiddenComment := ""
+	if len(hidden) > 0 {
+		hiddenComment = ", leaving out the hidden columns: " + strings.Join(hidden, ", ")
+	}
+	fileStr = strings.Replace(fileStr, "{{hiddenComment}}", hiddenComment, -1)
+	fileStr = strings.Replace(fileStr, "{{hideColumns}}", hideColumnsCode(tb.Name, hidden), -1)
+	fileStr = strings.Replace(fileStr, "{{responseFields}}", strings.Join(fields, "\n\t"), -1)
+	fileStr = strings.Replace(fileStr, "{{responseValues}}", strings.Join(values, "\n\t\t"), -1)
+	fileStr = strings.Replace(fileStr, "{{responseFkValues}}", strings.Join(fkValues, ""), -1)
+	return strings.Replace(fileStr, "{{responsePkg}}", importSpecs(importPkgs(visible)), -1)
+}
+
+// hideColumnsCode registers the hidden columns of the table so filter, sortby, aggregate
+// and cursor parameters can't use them either
+func hideColumnsCode(table string, hidden []string) string {
+	if len(hidden) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\nfunc init() {\n\t// hidden columns can't be used in filter, sortby, aggregate or cursor parameters\n\tdb.HideColumns(%q, %s)\n}\n",
+		table, strings.Join(quoteAll(hidden), ", "))
+}
+
+func quoteAll(l []string) []string {
+	quoted := make([]string, len(l))
+	for i, s := range l {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return quoted
+}
+
+// referencedColumn returns the column of the referenced table a foreign key points to,
+// falling back to an int Id when that table is not generated
+func referencedColumn(fk *ForeignKey, tables []*Table) *Column {
+	for _, t := range tables {
+		if t.Name != fk.RefTable {
+			continue
+		}
+		for _, col := range t.Columns {
+			if col.Tag.Column == fk.RefColumn {
+				return col
+			}
+		}
+	}
+	return &Column{Name: "Id", Type: "int", Tag: &OrmTag{Column: fk.RefColumn}}
+}
+
 // writeControllerFiles generates controller files
 func writeControllerFiles(tables []*Table, cPath string, pkgPath string) {
 	w := colors.NewColorWriter(os.Stdout)
@@ -924,70 +1175,10 @@ func writeControllerFiles(tables []*Table, cPath string, pkgPath string) {
 			}
 		}
 
-		var createAutoArr []string
-		var updateAutoArr []string
-		var pkgListArr []string
-		var isUserTime bool = false
-		var isUserStrconv bool = false
-		for _, col := range tb.Columns {
-			if col.Name == "CreatedAt" {
-				if col.Type == "datetime" {
-					createAutoArr = append(createAutoArr, "v.CreatedAt = time.Now()\n")
-				} else if col.Type == "int" {
-					createAutoArr = append(createAutoArr, "v.CreatedAt = int(time.Now().Unix())\n")
-				}
-				isUserTime = true
-			}
-			if col.Name == "CreatedBy" {
-				if col.Type == "int" {
-					createAutoArr = append(createAutoArr, "v.CreatedBy,_ = strconv.Atoi(c.User.GetId())\n")
-					isUserStrconv = true
-				} else if col.Type == "uint" {
-					createAutoArr = append(createAutoArr, "uid, _ := strconv.ParseUint(c.User.GetId(), 10, 32)\n		v.CreatedBy = uint(uid)\n")
-					isUserStrconv = true
-				} else if col.Type == "string" {
-					createAutoArr = append(createAutoArr, "v.CreatedBy = c.User.GetId()\n")
-				}
-			}
-			if col.Name == "UpdatedAt" {
-				if col.Type == "datetime" {
-					updateAutoArr = append(updateAutoArr, "v.UpdatedAt = time.Now()\n")
-					createAutoArr = append(createAutoArr, "v.UpdatedAt = time.Now()\n")
-				} else if col.Type == "int" {
-					updateAutoArr = append(updateAutoArr, "v.UpdatedAt = int(time.Now().Unix())\n")
-					createAutoArr = append(createAutoArr, "v.UpdatedAt = int(time.Now().Unix())\n")
-				}
-				isUserTime = true
-			}
-			if col.Name == "UpdatedBy" {
-				if col.Type == "int" {
-					updateAutoArr = append(updateAutoArr, "v.UpdatedBy,_ = strconv.Atoi(c.User.GetId())\n")
-					isUserStrconv = true
-				} else if col.Type == "uint" {
-					createAutoArr = append(createAutoArr, "uid, _ := strconv.ParseUint(c.User.GetId(), 10, 32)\n		v.UpdatedBy = uint(uid)\n")
-					isUserStrconv = true
-				} else {
-					updateAutoArr = append(updateAutoArr, "v.UpdatedBy = c.User.GetId()\n")
-				}
-			}
-		}
-
-		if isUserTime {
-			pkgListArr = append(pkgListArr, "\"time\"\n")
-		}
-		if isUserStrconv {
-			pkgListArr = append(pkgListArr, "\"strconv\"\n")
-		}
-		pkgListArr = append(pkgListArr, "\"strings\"\n")
-
-		createAuto := strings.Join(createAutoArr, "")
-		updateAuto := strings.Join(updateAutoArr, "")
-		pkgList := strings.Join(pkgListArr, "")
+		pkgList := "\"strings\"\n"
 
 		fileStr := strings.Replace(CtrlTPL, "{{ctrlName}}", utils.CamelCase(tb.Name), -1)
 		fileStr = strings.Replace(fileStr, "{{pkgPath}}", pkgPath, -1)
-		fileStr = strings.Replace(fileStr, "{{createAuto}}", createAuto, -1)
-		fileStr = strings.Replace(fileStr, "{{updateAuto}}", updateAuto, -1)
 		fileStr = strings.Replace(fileStr, "{{pkg}}", pkgList, -1)
 		fileStr = strings.Replace(fileStr, "{{subPath}}", subPath, -1)
 
@@ -1007,6 +1198,163 @@ func writeControllerFiles(tables []*Table, cPath string, pkgPath string) {
 	notirceMsgArr = append(notirceMsgArr, "add to operate list:\n"+strings.Join(operateListArr, ""))
 }
 
+// autoFillCode returns the statements filling the created/updated time and user
+// columns of tb in the service Create and Update, and the packages they import
+func autoFillCode(tb *Table) (createAuto string, updateAuto string, pkgs []string) {
+	var createAutoArr []string
+	var updateAutoArr []string
+	var isUserTime bool = false
+	var isUserStrconv bool = false
+	var isUserTypes bool = false
+	for _, col := range tb.Columns {
+		if col.Name == "CreatedAt" {
+			if col.Type == "datetime" || col.Type == "time.Time" {
+				createAutoArr = append(createAutoArr, "v.CreatedAt = time.Now()\n")
+			} else if col.Type == "int" {
+				createAutoArr = append(createAutoArr, "v.CreatedAt = int(time.Now().Unix())\n")
+			} else if col.Type == "types.NullTime" {
+				createAutoArr = append(createAutoArr, "v.CreatedAt = types.NewNullTime(time.Now())\n")
+				isUserTypes = true
+			} else if col.Type == "types.NullInt64" {
+				createAutoArr = append(createAutoArr, "v.CreatedAt = types.NewNullInt64(time.Now().Unix())\n")
+				isUserTypes = true
+			}
+			isUserTime = true
+		}
+		if col.Name == "CreatedBy" {
+			if col.Type == "int" {
+				createAutoArr = append(createAutoArr, "v.CreatedBy,_ = strconv.Atoi(s.User.GetId())\n")
+				isUserStrconv = true
+			} else if col.Type == "uint" {
+				createAutoArr = append(createAutoArr, "uid, _ := strconv.ParseUint(s.User.GetId(), 10, 32)\n		v.CreatedBy = uint(uid)\n")
+				isUserStrconv = true
+			} else if col.Type == "string" {
+				createAutoArr = append(createAutoArr, "v.CreatedBy = s.User.GetId()\n")
+			} else if col.Type == "types.NullInt64" {
+				createAutoArr = append(createAutoArr, "createdBy, _ := strconv.ParseInt(s.User.GetId(), 10, 64)\n		v.CreatedBy = types.NewNullInt64(createdBy)\n")
+				isUserStrconv = true
+				isUserTypes = true
+			} else if col.Type == "types.NullUint64" {
+				createAutoArr = append(createAutoArr, "createdBy, _ := strconv.ParseUint(s.User.GetId(), 10, 64)\n		v.CreatedBy = types.NewNullUint64(createdBy)\n")
+				isUserStrconv = true
+				isUserTypes = true
+			} else if col.Type == "types.NullString" {
+				createAutoArr = append(createAutoArr, "v.CreatedBy = types.NewNullString(s.User.GetId())\n")
+				isUserTypes = true
+			}
+		}
+		if col.Name == "UpdatedAt" {
+			if col.Type == "datetime" || col.Type == "time.Time" {
+				updateAutoArr = append(updateAutoArr, "v.UpdatedAt = time.Now()\n")
+				createAutoArr = append(createAutoArr, "v.UpdatedAt = time.Now()\n")
+			} else if col.Type == "int" {
+				updateAutoArr = append(updateAutoArr, "v.UpdatedAt = int(time.Now().Unix())\n")
+				createAutoArr = append(createAutoArr, "v.UpdatedAt = int(time.Now().Unix())\n")
+			} else if col.Type == "types.NullTime" {
+				updateAutoArr = append(updateAutoArr, "v.UpdatedAt = types.NewNullTime(time.Now())\n")
+				createAutoArr = append(createAutoArr, "v.UpdatedAt = types.NewNullTime(time.Now())\n")
+				isUserTypes = true
+			} else if col.Type == "types.NullInt64" {
+				updateAutoArr = append(updateAutoArr, "v.UpdatedAt = types.NewNullInt64(time.Now().Unix())\n")
+				createAutoArr = append(createAutoArr, "v.UpdatedAt = types.NewNullInt64(time.Now().Unix())\n")
+				isUserTypes = true
+			}
+			isUserTime = true
+		}
+		if col.Name == "UpdatedBy" {
+			if col.Type == "int" {
+				updateAutoArr = append(updateAutoArr, "v.UpdatedBy,_ = strconv.Atoi(s.User.GetId())\n")
+				isUserStrconv = true
+			} else if col.Type == "uint" {
+				updateAutoArr = append(updateAutoArr, "uid, _ := strconv.ParseUint(s.User.GetId(), 10, 32)\n		v.UpdatedBy = uint(uid)\n")
+				isUserStrconv = true
+			} else if col.Type == "types.NullInt64" {
+				updateAutoArr = append(updateAutoArr, "updatedBy, _ := strconv.ParseInt(s.User.GetId(), 10, 64)\n		v.UpdatedBy = types.NewNullInt64(updatedBy)\n")
+				isUserStrconv = true
+				isUserTypes = true
+			} else if col.Type == "types.NullUint64" {
+				updateAutoArr = append(updateAutoArr, "updatedBy, _ := strconv.ParseUint(s.User.GetId(), 10, 64)\n		v.UpdatedBy = types.NewNullUint64(updatedBy)\n")
+				isUserStrconv = true
+				isUserTypes = true
+			} else if col.Type == "types.NullString" {
+				updateAutoArr = append(updateAutoArr, "v.UpdatedBy = types.NewNullString(s.User.GetId())\n")
+				isUserTypes = true
+			} else {
+				updateAutoArr = append(updateAutoArr, "v.UpdatedBy = s.User.GetId()\n")
+			}
+		}
+	}
+
+	if isUserTime {
+		pkgs = append(pkgs, "\"time\"")
+	}
+	if isUserStrconv {
+		pkgs = append(pkgs, "\"strconv\"")
+	}
+	if isUserTypes {
+		pkgs = append(pkgs, "\"github.com/yimishiji/bee/pkg/types\"")
+	}
+	return strings.Join(createAutoArr, ""), strings.Join(updateAutoArr, ""), pkgs
+}
+
+// writeServiceFiles generates the service-logics files wrapping model CRUD
+func writeServiceFiles(tables []*Table, sPath string, pkgPath string) {
+	w := colors.NewColorWriter(os.Stdout)
+
+	for _, tb := range tables {
+		if tb.Pk == "" {
+			continue
+		}
+
+		namespce := strings.Split(strings.ToLower(tb.Name), "_")
+		//文件目录结构, 与model相同
+		subPath := namespce[0]
+		if len(namespce) > 1 {
+			subPath = path.Join(subPath, strings.Join(namespce[1:], "-"))
+		}
+		mkdirs(sPath, namespce[0], strings.Join(namespce[1:], "-"))
+		fpath := path.Join(sPath, subPath, "service.go")
+
+		var f *os.File
+		var err error
+		if utils.IsExist(fpath) {
+			beeLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
+			if utils.AskForConfirmation() {
+				f, err = os.OpenFile(fpath, os.O_RDWR|os.O_TRUNC, 0666)
+				if err != nil {
+					beeLogger.Log.Warnf("%s", err)
+					continue
+				}
+			} else {
+				beeLogger.Log.Warnf("Skipped create file '%s'", fpath)
+				continue
+			}
+		} else {
+			f, err = os.OpenFile(fpath, os.O_CREATE|os.O_RDWR, 0666)
+			if err != nil {
+				beeLogger.Log.Warnf("%s", err)
+				continue
+			}
+		}
+
+		createAuto, updateAuto, pkgs := autoFillCode(tb)
+
+		fileStr := strings.Replace(ServiceTPL, "{{modelName}}", utils.CamelCase(tb.Name), -1)
+		fileStr = strings.Replace(fileStr, "{{pkgPath}}", pkgPath, -1)
+		fileStr = strings.Replace(fileStr, "{{subPath}}", subPath, -1)
+		fileStr = strings.Replace(fileStr, "{{createAuto}}", createAuto, -1)
+		fileStr = strings.Replace(fileStr, "{{updateAuto}}", updateAuto, -1)
+		fileStr = strings.Replace(fileStr, "{{pkg}}", importSpecs(pkgs), -1)
+
+		if _, err := f.WriteString(fileStr); err != nil {
+			beeLogger.Log.Fatalf("Could not write service file to '%s': %s", fpath, err)
+		}
+		utils.CloseFile(f)
+		fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
+		utils.FormatSourceCode(fpath)
+	}
+}
+
 func mkdirs(namespce ...string) {
 	namespceLen := len(namespce)
 
@@ -1061,12 +1409,12 @@ func writeFilterFiles(tables []*Table, cPath string, pkgPath string) {
 			}
 		}
 
-		var pkgListArr []string
 		var validArr []string
 		var inpuTfieldListArr []string
-		var isUserTime bool = false
+		var inputCols []*Column
 		for _, col := range tb.Columns {
-			if col.Tag.Null == false && col.Tag.Column != tb.Pk {
+			isInput := tb.Pk != col.Tag.Column && col.Name != "CreatedAt" && col.Name != "CreatedBy" && col.Name != "UpdatedAt" && col.Name != "UpdatedBy"
+			if col.Tag.Null == false && isInput {
 				fileStr := strings.Replace(FilterValidRuleTPL, "{{validFunc}}", "Required", -1)
 				fileStr = strings.Replace(fileStr, "{{colName}}", col.Name, -1)
 				fileStr = strings.Replace(fileStr, "{{colColumn}}", col.Tag.Column, -1)
@@ -1074,18 +1422,23 @@ func writeFilterFiles(tables []*Table, cPath string, pkgPath string) {
 				validArr = append(validArr, fileStr)
 			}
 
-			if tb.Pk != col.Tag.Column && col.Name != "CreatedAt" && col.Name != "CreatedBy" && col.Name != "UpdatedAt" && col.Name != "UpdatedBy" {
+			if isInput && col.Tag.Digits != "" && (col.Type == "types.Decimal" || col.Type == "types.NullDecimal") {
+				fileStr := strings.Replace(FilterDecimalRuleTPL, "{{colName}}", col.Name, -1)
+				fileStr = strings.Replace(fileStr, "{{colColumn}}", col.Tag.Column, -1)
+				fileStr = strings.Replace(fileStr, "{{digits}}", col.Tag.Digits, -1)
+				fileStr = strings.Replace(fileStr, "{{decimals}}", col.Tag.Decimals, -1)
+				validArr = append(validArr, fileStr)
+			}
+
+			if isInput {
 
 				structfield := fmt.Sprintf("%s %s %s", col.Name, col.Type, col.Tag.String())
 				inpuTfieldListArr = append(inpuTfieldListArr, structfield)
+				inputCols = append(inputCols, col)
 			}
 		}
 
-		if isUserTime {
-			pkgListArr = append(pkgListArr, "\"time\"\n")
-		}
-
-		pkgList := strings.Join(pkgListArr, "")
+		pkgList := importSpecs(importPkgs(inputCols))
 		validStr := strings.Join(validArr, "")
 		inpuTfieldList := strings.Join(inpuTfieldListArr, "\n	")
 
@@ -1242,6 +1595,9 @@ func writeVueControllerIndex(tables []*Table, cPath string, pkgPath string) {
 
 			// Add index page customFieldEdit
 			tlpstrField := strings.Replace(VueCreateCustomFormComponentTPL, "{{fieldName}}", col.Tag.Column, -1)
+			if isNullType(col.Type) && col.Tag.Default == "" {
+				tlpstrField = strings.Replace(tlpstrField, "'{{fieldDefault}}'", "null", -1)
+			}
 			tlpstrField = strings.Replace(tlpstrField, "{{fieldDefault}}", col.Tag.Default, -1)
 			customFieldEditArr = append(customFieldEditArr, tlpstrField)
 
@@ -1298,6 +1654,21 @@ func writeVueControllerIndex(tables []*Table, cPath string, pkgPath string) {
 				} else if col.Type == "float" {
 					tlpstr = strings.Replace(tlpstr, "this.customForm."+col.Tag.Column, "parseFloat(this.customForm."+col.Tag.Column+")", -1)
 					createSubmitDataFixArr = append(createSubmitDataFixArr, "	\n				  	  params['"+col.Tag.Column+"'] = parseFloat(params['"+col.Tag.Column+"']);")
+				} else if col.Type == "types.NullInt64" || col.Type == "types.NullFloat64" {
+					parseFunc := "parseInt"
+					if col.Type == "types.NullFloat64" {
+						parseFunc = "parseFloat"
+					}
+					formValue := "this.customForm." + col.Tag.Column
+					tlpstr = strings.Replace(tlpstr, formValue, formValue+" === null || "+formValue+" === '' ? null : "+parseFunc+"("+formValue+")", -1)
+					paramValue := "params['" + col.Tag.Column + "']"
+					createSubmitDataFixArr = append(createSubmitDataFixArr, "\n			      	  "+paramValue+" = "+paramValue+" === null || "+paramValue+" === '' ? null : "+parseFunc+"("+paramValue+");")
+				} else if col.Type == "types.NullUint64" {
+					// sent as a string, the value may exceed the js safe integer range
+					formValue := "this.customForm." + col.Tag.Column
+					tlpstr = strings.Replace(tlpstr, formValue, formValue+" === '' ? null : "+formValue, -1)
+					paramValue := "params['" + col.Tag.Column + "']"
+					createSubmitDataFixArr = append(createSubmitDataFixArr, "\n			      	  "+paramValue+" = "+paramValue+" === '' ? null : "+paramValue+";")
 				}
 				editSubmitItemsArr = append(editSubmitItemsArr, tlpstr)
 			}
@@ -1459,7 +1830,7 @@ func isSQLSignedIntType(t string) bool {
 }
 
 func isSQLDecimal(t string) bool {
-	return t == "decimal"
+	return t == "decimal" || t == "numeric"
 }
 
 func isSQLBinaryType(t string) bool {
@@ -1487,8 +1858,11 @@ func extractIntSignness(colType string) string {
 }
 
 func extractDecimal(colType string) (digits string, decimals string) {
-	decimalRegex := regexp.MustCompile(`decimal\(([0-9]+),([0-9]+)\)`)
+	decimalRegex := regexp.MustCompile(`(?:decimal|numeric)\(([0-9]+),([0-9]+)\)`)
 	decimal := decimalRegex.FindStringSubmatch(colType)
+	if decimal == nil {
+		return "", ""
+	}
 	digits, decimals = decimal[1], decimal[2]
 	return
 }
@@ -1538,11 +1912,11 @@ func getPackagePath(curpath string) (packpath string) {
 
 const (
 	StructModelTPL = `package models
-{{importTimePkg}}
+{{importPkg}}
 {{modelStruct}}
 `
 	ModelBaseTPL = `package TableStructs
-{{importTimePkg}}
+{{importPkg}}
 {{modelStruct}}
 
 func (t *{{modelName}}) TableName() string {
@@ -1553,27 +1927,113 @@ func (t *{{modelName}}) TableName() string {
 	ModelTPL string = `package {{modelName}}Model
 
 import (
-	"strings"
-	{{timePkg}}
+	"context"
+	{{responsePkg}}
+
 	TableStructs "{{pkgPath}}/models/table-structs"
+	"github.com/jinzhu/gorm"
     "github.com/yimishiji/bee/pkg/db"
+    "github.com/yimishiji/bee/pkg/filters"
 )
 
+// connection is the name of the [db.<name>] connection this model reads and writes,
+// empty for the default [db] connection
+const connection = "{{connection}}"
+{{hideColumns}}
 type Model struct {
 	TableStructs.{{modelName}}
 }
 
+// Response is the {{modelName}} returned to clients{{hiddenComment}}
+type Response struct {
+	{{responseFields}}
+}
+
+// NewResponse maps a {{modelName}} to its response
+func NewResponse(m Model) Response {
+	r := Response{
+		{{responseValues}}
+	}
+	{{responseFkValues}}return r
+}
+
+// NewResponses maps a list of {{modelName}} to responses
+func NewResponses(l []Model) []Response {
+	rl := make([]Response, 0, len(l))
+	for _, m := range l {
+		rl = append(rl, NewResponse(m))
+	}
+	return rl
+}
+
+// Conn returns the primary database connection of {{modelName}}
+func Conn() *gorm.DB {
+	return db.Use(connection)
+}
+
+// ReadConn returns the connection for reads: a healthy replica, or the primary
+// when primary is true (read your own writes) or no replica is available
+func ReadConn(primary bool) *gorm.DB {
+	return db.ReadFor(connection, primary)
+}
+
+// relations are the tables referenced by foreign keys, joined when query, filter or sortby
+// use their columns, e.g. Member.mobile
+var relations = []db.Relation{
+{{relations}}}
+
+// query starts a {{modelName}} query on conn, with the relations usable in filters and sorting
+func query(conn *gorm.DB) *gorm.DB {
+	return db.WithRelations(conn.Model(&Model{}), relations...)
+}
+
+// cache reads {{modelName}} through redis once [cache] sets a ttl for {{tableName}}, e.g. {{tableName}} = 300.
+// Lists are tagged with the related tables too, so writes to them expire the cached lists
+var cache = db.NewModelCache("{{tableName}}", db.RelationTables(relations)...)
+
+// CacheConn returns the connection cache misses are loaded from: the primary while the cache is on,
+// so that a replica lagging behind an invalidation can't put stale data back into the cache
+func CacheConn() *gorm.DB {
+	return ReadConn(cache.Enabled())
+}
+
 // Add insert a new {{modelName}} into database and returns
 // last inserted Id on success.
 func Add(m *Model) (err error) {
-    return db.Conn.Create(m).Error
+	return AddOn(Conn(), m)
+}
+
+// AddOn inserts a new {{modelName}} on the given connection or transaction
+func AddOn(conn *gorm.DB, m *Model) (err error) {
+	if err = conn.Create(m).Error; err != nil {
+		return err
+	}
+	cache.Invalidate(conn)
+	return nil
 }
 
 // GetById retrieves {{modelName}} by Id. Returns error if
 // Id doesn't exist
 // relations relations data keys
 func GetById(id int, relations ...string) (v Model, err error) {
-	gormQuery := db.Conn.Where(id)
+	if len(relations) == 0 {
+		return GetByIdCachedOn(CacheConn(), id)
+	}
+	return GetByIdOn(ReadConn(false), id, relations...)
+}
+
+// GetByIdCachedOn retrieves {{modelName}} by Id through the cache, loading it from conn on a miss, see CacheConn
+func GetByIdCachedOn(conn *gorm.DB, id int) (v Model, err error) {
+	err = cache.Get(id, &v, func() error {
+		v, err = GetByIdOn(conn, id)
+		return err
+	})
+	return v, err
+}
+
+// GetByIdOn retrieves {{modelName}} by Id on the given connection or transaction
+func GetByIdOn(conn *gorm.DB, id int, relations ...string) (v Model, err error) {
+	gormQuery := conn.Where(id)
 
 	//载入关连关系
 	for _, rel := range relations {
@@ -1587,59 +2047,195 @@ func GetById(id int, relations ...string) (v Model, err error) {
 // GetAll retrieves all {{modelName}} matches certain condition. Returns empty list if
 // no records exist
 func GetAll(query map[string]string, relations []string, fields []string, sortFields []string, offset int64, limit int64) (ml []Model, total int64, err error) {
-    //过虑条件
-    gormQuery := db.NewGormQuery(query)
+	p := &filters.PageCommonParams{
+		Querys:     query,
+		Rels:       relations,
+		Field:      fields,
+		SortFields: sortFields,
+		Offsets:    offset,
+		Limits:     limit,
+		Count:      true,
+	}
+	ml, page, err := GetAllCachedOn(CacheConn(), p)
+	return ml, page.TotalCount, err
+}
+
+// GetAllCachedOn retrieves the {{modelName}} page through the cache, keyed by the hash of the list params.
+// Misses are loaded from conn, see CacheConn
+func GetAllCachedOn(conn *gorm.DB, p *filters.PageCommonParams) (ml []Model, page filters.PageResult, err error) {
+	var cached struct {
+		Items []Model
+		Page  filters.PageResult
+	}
+	err = cache.List(p, &cached, func() (err error) {
+		cached.Items, cached.Page, err = GetAllOn(conn, p)
+		return err
+	})
+	return cached.Items, cached.Page, err
+}
+
+// GetAllOn retrieves all {{modelName}} matches the list params on the given connection or transaction.
+// With p.Keyset it pages by cursor: ordered by the sort fields plus the primary key, starting after p.Cursor
+func GetAllOn(conn *gorm.DB, p *filters.PageCommonParams) (ml []Model, page filters.PageResult, err error) {
+    //过虑条件, query=k:v 与 filter= 同时使用时取交集, 值按模型字段类型转换
+    gormQuery := db.NewConnGormQuery(query(conn), p.Querys)
+    gormQuery = db.ApplyFilter(gormQuery, p.Filter)
 
-    //排序
-    for _, v := range sortFields {
-        gormQuery = gormQuery.Order(v)
+    //获取总数, count=0 时不统计
+    page.TotalCount = -1
+    if p.Count {
+        if err = gormQuery.Count(&page.TotalCount).Error; err != nil {
+            return nil, page, err
+        }
     }
 
-    //获取总页数
-    var itemCount int64
-    gormQuery.Model(Model{}).Count(&itemCount)
+    //排序, 游标分页时按排序字段加主键排序并从游标之后开始
+    if p.Keyset {
+        if gormQuery, err = db.ApplyKeyset(gormQuery, p.SortFields, p.Cursor); err != nil {
+            return nil, page, err
+        }
+    } else {
+        gormQuery = db.ApplySort(gormQuery, p.SortFields).Offset(p.Offsets)
+    }
 
-    //select
-    if len(fields) > 0 {
-        gormQuery = gormQuery.Select(strings.Join(fields, ","))
+    //select 只允许模型中的字段并加上表名, 游标分页时加上排序字段和主键, 下一页的游标由这些字段的值生成
+    if len(p.Field) > 0 {
+        fields := p.Field
+        if p.Keyset {
+            if fields, err = db.KeysetFields(gormQuery, p.Field, p.SortFields); err != nil {
+                return nil, page, err
+            }
+        }
+        gormQuery = db.SelectColumns(gormQuery, fields)
     }
 
 	//载入关连关系
-	for _, rel := range relations {
+	for _, rel := range p.Rels {
 		gormQuery = gormQuery.Preload(rel)
 	}
 
-    //查询
+    //查询, 多取一条判断是否有下一页
 	var l []Model
-    err = gormQuery.Limit(limit).Offset(offset).Find(&l).Error
+    limit := p.Limits
+    if limit >= 0 {
+        limit++
+    }
+    err = gormQuery.Limit(limit).Find(&l).Error
     if err != nil {
-        return nil, itemCount, err
+        return nil, page, err
+    }
+    if p.Limits >= 0 && int64(len(l)) > p.Limits {
+        l = l[:p.Limits]
+        page.HasMore = true
+    }
+    if p.Keyset && page.HasMore && len(l) > 0 {
+        if page.NextCursor, err = db.NextCursor(gormQuery, &l[len(l)-1], p.SortFields); err != nil {
+            return nil, page, err
+        }
     }
 
     // 如果需要精简返回值，将返回列表类型设为 []interface{}，再调用以下函数
     //ml = db.SelectField(l, fields)
 
-	return l, itemCount, err
+	return l, page, err
+}
+
+// Stats aggregates {{modelName}} by p.GroupBy, p.Aggregates and p.Having, filtered like GetAll
+func Stats(p *filters.PageCommonParams) ([]map[string]interface{}, filters.PageResult, error) {
+	return StatsOn(ReadConn(false), p)
+}
+
+// StatsOn aggregates {{modelName}} on the given connection or transaction.
+// Each row holds the group_by fields and the aggregates by their aliases, e.g. status, count, sum_amount.
+// It returns at most p.AggregateLimit() rows, page.HasMore tells whether there are more groups
+func StatsOn(conn *gorm.DB, p *filters.PageCommonParams) (l []map[string]interface{}, page filters.PageResult, err error) {
+    page.TotalCount = -1
+    gormQuery := db.NewConnGormQuery(query(conn), p.Querys)
+    gormQuery = db.ApplyFilter(gormQuery, p.Filter)
+
+    if gormQuery.Error != nil {
+        return nil, page, gormQuery.Error
+    }
+
+    gormQuery, err = db.ApplyAggregate(gormQuery, p.GroupBy, p.Aggregates, p.Having, p.SortFields)
+    if err != nil {
+        return nil, page, err
+    }
+
+    //多取一行判断是否还有分组
+    limit := p.AggregateLimit()
+    if l, err = db.ScanAggregate(gormQuery.Limit(limit + 1).Offset(p.Offsets)); err != nil {
+        return nil, page, err
+    }
+    if int64(len(l)) > limit {
+        l = l[:limit]
+        page.HasMore = true
+    }
+    return l, page, nil
 }
 
 // Update updates {{modelName}} by Id and returns error if
 // the record to be updated doesn't exist
 func Update(m *Model) (err error) {
-	return db.Conn.Save(m).Error
+	return UpdateOn(Conn(), m)
+}
+
+// UpdateOn updates {{modelName}} by Id on the given connection or transaction
+func UpdateOn(conn *gorm.DB, m *Model) (err error) {
+	if err = conn.Save(m).Error; err != nil {
+		return err
+	}
+	cache.Invalidate(conn, conn.NewScope(m).PrimaryKeyValue())
+	return nil
 }
 
 // Delete deletes {{modelName}} by Id and returns error if
 // the record to be deleted doesn't exist
 func Delete(id int) (err error) {
+	return DeleteOn(Conn(), id)
+}
+
+// DeleteOn deletes {{modelName}} by Id on the given connection or transaction
+func DeleteOn(conn *gorm.DB, id int) (err error) {
 	v := new(Model)
 
 	// ascertain id exists in the database
-	err = db.Conn.Where(id).First(&v).Error
+	err = conn.Where(id).First(&v).Error
 	if err != nil {
 		return err
 	}
 
-	return  db.Conn.Delete(&v).Error
+	if err = conn.Delete(&v).Error; err != nil {
+		return err
+	}
+	cache.Invalidate(conn, id)
+	return nil
+}
+
+// AddCtx inserts a new {{modelName}} in the transaction carried by ctx, see db.TransactionContext.
+// The *Ctx functions abort their queries once ctx is cancelled or times out
+func AddCtx(ctx context.Context, m *Model) (err error) {
+	return AddOn(db.ConnFromContext(ctx, Conn()), m)
+}
+
+// GetByIdCtx retrieves {{modelName}} by Id in the transaction carried by ctx, or from a replica
+func GetByIdCtx(ctx context.Context, id int, relations ...string) (v Model, err error) {
+	return GetByIdOn(db.ConnFromContext(ctx, ReadConn(false)), id, relations...)
+}
+
+// GetAllCtx retrieves {{modelName}} matches the list params in the transaction carried by ctx, or from a replica
+func GetAllCtx(ctx context.Context, p *filters.PageCommonParams) (ml []Model, page filters.PageResult, err error) {
+	return GetAllOn(db.ConnFromContext(ctx, ReadConn(false)), p)
+}
+
+// UpdateCtx saves {{modelName}} in the transaction carried by ctx
+func UpdateCtx(ctx context.Context, m *Model) (err error) {
+	return UpdateOn(db.ConnFromContext(ctx, Conn()), m)
+}
+
+// DeleteCtx deletes {{modelName}} by Id in the transaction carried by ctx
+func DeleteCtx(ctx context.Context, id int) (err error) {
+	return DeleteOn(db.ConnFromContext(ctx, Conn()), id)
 }
 
 // BeforeCreate hook
@@ -1653,6 +2249,7 @@ func Delete(id int) (err error) {
 import (
 	{{ctrlName}}Model "{{pkgPath}}/models/{{subPath}}"
     {{ctrlName}}Filter "{{pkgPath}}/filters/{{subPath}}"
+    {{ctrlName}}Service "{{pkgPath}}/service-logics/{{subPath}}"
 	{{pkg}}	
 
     "github.com/yimishiji/bee/pkg/base"
@@ -1670,6 +2267,7 @@ func (c *{{ctrlName}}Controller) URLMapping() {
 	c.Mapping("Post", c.Post)
 	c.Mapping("GetOne", c.GetOne)
 	c.Mapping("GetAll", c.GetAll)
+	c.Mapping("Stats", c.Stats)
 	c.Mapping("Put", c.Put)
 	c.Mapping("Delete", c.Delete)
 }
@@ -1679,23 +2277,30 @@ func (c *{{ctrlName}}Controller) Prepare() {
     c.filter = {{ctrlName}}Filter.NewFilter(c.Ctx.Input)
 }
 
+// service returns the {{ctrlName}} service acting for the current user
+func (c *{{ctrlName}}Controller) service() *{{ctrlName}}Service.Service {
+	svc := {{ctrlName}}Service.New(&c.User, nil)
+	svc.ReadPrimary = c.ReadPrimary
+	svc.Ctx = c.Context()
+	return svc
+}
+
 // Post ...
 // @Title Post
 // @Description create {{ctrlName}}
 // @Param	body		body 	models.{{ctrlName}}	true		"body for {{ctrlName}} content"
-// @Success 201 {int} models.{{ctrlName}}Model.{{ctrlName}}
+// @Success 201 {object} {{ctrlName}}Model.Response
 // @Failure 403 body is empty
 // @router / [post]
 func (c *{{ctrlName}}Controller) Post() {
     var v {{ctrlName}}Model.Model
     if f, err := c.filter.GetPost(); err == nil {
         structs.StructMerge(&v, f)
-		{{createAuto}}
-		if err := {{ctrlName}}Model.Add(&v); err == nil {
+		if err := c.service().Create(&v); err == nil {
 			c.Ctx.Output.SetStatus(201)
-			c.Data["json"] = c.Resp(base.ApiCode_SUCC, "ok", v)
+			c.Data["json"] = c.Resp(base.ApiCode_SUCC, "ok", {{ctrlName}}Model.NewResponse(v))
 		} else {
-			c.Data["json"] = c.Resp(base.ApiCode_SYS_ERROR, "system error", err.Error())
+			c.Data["json"] = c.ErrResp(base.ApiCode_SYS_ERROR, "system error", err)
 		}
 	} else {
 		c.Data["json"] = c.Resp(base.ApiCode_VALIDATE_ERROR, "invalid:"+err.Error(), err.Error())
@@ -1708,7 +2313,7 @@ func (c *{{ctrlName}}Controller) Post() {
 // @Description get {{ctrlName}} by id
 // @Param	id		path 	string	true		"The key for staticblock"
 // @Param	rels	query 	string	false		"Many are separated by commas."
-// @Success 200 {object} models.{{ctrlName}}Model.{{ctrlName}}
+// @Success 200 {object} {{ctrlName}}Model.Response
 // @Failure 403 :id is empty
 // @router /:id [get]
 func (c *{{ctrlName}}Controller) GetOne() {
@@ -1720,11 +2325,11 @@ func (c *{{ctrlName}}Controller) GetOne() {
 		rels = strings.Split(relsStr, ",")
 	}
 
-	v, err := {{ctrlName}}Model.GetById(id, rels...)
+	v, err := c.service().Get(id, rels...)
 	if err != nil {
-		c.Data["json"] = c.Resp(base.ApiCode_VALIDATE_ERROR, "not find", err.Error())
+		c.Data["json"] = c.ErrResp(base.ApiCode_VALIDATE_ERROR, "not find", err)
 	} else {
-		c.Data["json"] = c.Resp(base.ApiCode_SUCC, "ok", v)
+		c.Data["json"] = c.Resp(base.ApiCode_SUCC, "ok", {{ctrlName}}Model.NewResponse(v))
 	}
 	c.ServeJSON()
 }
@@ -1733,13 +2338,16 @@ func (c *{{ctrlName}}Controller) GetOne() {
 // @Title Get All
 // @Description get {{ctrlName}}
 // @Param	query	query	string	false	"Filter. e.g. col1:v1,col2:v2,col-isnull:,col:>50,col:like-adc,col:between-10-20 ..."
+// @Param	filter	query	string	false	"Filter in RSQL or json, and/or/not groups. e.g. status==1;(type=in=(a,b),price=between=(10,20));!(name=like='a,b')"
 // @Param	rels	query 	string	false	"Associated data identifiers,  Many are separated by commas. e.g. User,User.Info,User.Address"
 // @Param	fields	query	string	false	"Fields returned. e.g. col1,col2 ..."
 // @Param	sortby	query	string	false	"Sorted-by fields. e.g. col1,col2 ..."
 // @Param	order	query	string	false	"Order corresponding to each sortby field, if single value, apply to all sortby fields. e.g. desc,asc ..."
 // @Param	limit	query	string	false	"Limit the size of result set. Must be an integer"
 // @Param	offset	query	string	false	"Start position of result set. Must be an integer"
-// @Success 200 {object} models.{{ctrlName}}Model.{{ctrlName}}
+// @Param	cursor	query	string	false	"Cursor pagination. Empty for the first page, then next_cursor of the previous page (alias: after)"
+// @Param	count	query	string	false	"Count the total. 0 or 1, default 1, or 0 with cursor"
+// @Success 200 {array} {{ctrlName}}Model.Response
 // @Failure 403
 // @router / [get]
 func (c *{{ctrlName}}Controller) GetAll() {
@@ -1750,40 +2358,77 @@ func (c *{{ctrlName}}Controller) GetAll() {
 		return
 	}
 
-    l, itemCount, err := {{ctrlName}}Model.GetAll(pageParams.Querys, pageParams.Rels, pageParams.Field, pageParams.SortFields, pageParams.Offsets, pageParams.Limits)
+    l, page, err := c.service().List(pageParams)
 	if err != nil {
-		c.Data["json"] = c.Resp(base.ApiCode_ILLEGAL_ERROR, "not find", err.Error())
+		c.Data["json"] = c.ErrResp(base.ApiCode_ILLEGAL_ERROR, "not find", err)
+	} else if pageParams.Keyset {
+        list := base.NewCursorPageData(pageParams.Limits, page.TotalCount, page.NextCursor, page.HasMore, {{ctrlName}}Model.NewResponses(l))
+        c.Data["json"] = c.Resp(base.ApiCode_SUCC, "ok", list)
 	} else {
-        list := base.NewListPageData(pageParams.Limits, pageParams.Offsets, itemCount, l)
+        list := base.NewListPageData(pageParams.Limits, pageParams.Offsets, page.TotalCount, {{ctrlName}}Model.NewResponses(l))
+        list.HasMore = page.HasMore
         c.Data["json"] = c.Resp(base.ApiCode_SUCC, "ok", list)
 	}
 	c.ServeJSON()
 }
 
+// Stats ...
+// @Title Stats
+// @Description aggregate {{ctrlName}} by group, filtered like GetAll
+// @Param	group_by	query	string	false	"Grouped-by fields, date fields may be bucketed by hour, day, month or year. e.g. status,created_at:day"
+// @Param	aggregate	query	string	false	"Aggregates, count, sum, avg, min or max. e.g. count:*,sum:amount,avg:price (default count:*)"
+// @Param	having	query	string	false	"Filter on the result fields, written like filter. e.g. count>10;sum_amount=between=(100,200)"
+// @Param	query	query	string	false	"Filter. e.g. col1:v1,col2:v2 ..."
+// @Param	filter	query	string	false	"Filter in RSQL or json, and/or/not groups"
+// @Param	sortby	query	string	false	"Sorted-by result fields. e.g. count,status ..."
+// @Param	order	query	string	false	"Order corresponding to each sortby field. e.g. desc,asc ..."
+// @Param	limit	query	string	false	"Limit the number of groups, at most 10000 (default 10000). Must be an integer"
+// @Param	offset	query	string	false	"Start position of result set. Must be an integer"
+// @Success 200 {object} base.ListPageData
+// @Failure 403
+// @router /stats [get]
+func (c *{{ctrlName}}Controller) Stats() {
+    pageParams, err := c.filter.GetListPrams()
+	if err != nil {
+		c.Data["json"] = c.Resp(base.ApiCode_ILLEGAL_ERROR, "illegal operation", err.Error())
+		c.ServeJSON()
+		return
+	}
+
+    rows, page, err := c.service().Stats(pageParams)
+	if err != nil {
+		c.Data["json"] = c.ErrResp(base.ApiCode_ILLEGAL_ERROR, "not find", err)
+	} else {
+        list := base.NewListPageData(pageParams.AggregateLimit(), pageParams.Offsets, page.TotalCount, rows)
+        list.HasMore = page.HasMore
+		c.Data["json"] = c.Resp(base.ApiCode_SUCC, "ok", list)
+	}
+	c.ServeJSON()
+}
+
 // Put ...
 // @Title Put
 // @Description update the {{ctrlName}}
 // @Param	id		path 	string	true		"The id you want to update"
 // @Param	body		body 	models.{{ctrlName}}	true		"body for {{ctrlName}} content"
-// @Success 200 {object} models.{{ctrlName}}
+// @Success 200 {object} {{ctrlName}}Model.Response
 // @Failure 403 :id is not int
 // @router /:id [put]
 func (c *{{ctrlName}}Controller) Put() {
     id := c.filter.GetId(":id")
-    v, err := {{ctrlName}}Model.GetById(id)
+    v, err := c.service().GetForUpdate(id)
     if err != nil {
-        c.Data["json"] = c.Resp(base.ApiCode_VALIDATE_ERROR, "invalid:"+err.Error(), err.Error())
+        c.Data["json"] = c.ErrResp(base.ApiCode_VALIDATE_ERROR, "invalid:"+err.Error(), err)
         c.ServeJSON()
         return
     }
 
     if f, err := c.filter.GetPut(); err == nil {
         structs.StructMerge(&v, f)
-		{{updateAuto}}
-		if err := {{ctrlName}}Model.Update(&v); err == nil {
+		if err := c.service().Update(&v); err == nil {
 			c.Data["json"] = c.Resp(base.ApiCode_SUCC, "ok")
 		} else {
-			c.Data["json"] = c.Resp(base.ApiCode_SYS_ERROR, "system error", err.Error())
+			c.Data["json"] = c.ErrResp(base.ApiCode_SYS_ERROR, "system error", err)
 		}
 	} else {
 		c.Data["json"] = c.Resp(base.ApiCode_VALIDATE_ERROR, "invalid:"+err.Error(), err.Error())
@@ -1800,13 +2445,123 @@ func (c *{{ctrlName}}Controller) Put() {
 // @router /:id [delete]
 func (c *{{ctrlName}}Controller) Delete() {
     id := c.filter.GetId(":id")
-	if err := {{ctrlName}}Model.Delete(id); err == nil {
+	if err := c.service().Delete(id); err == nil {
 		c.Data["json"] = c.Resp(base.ApiCode_SUCC, "ok")
 	} else {
-		c.Data["json"] = c.Resp(base.ApiCode_ILLEGAL_ERROR, "illegal operation", err.Error())
+		c.Data["json"] = c.ErrResp(base.ApiCode_ILLEGAL_ERROR, "illegal operation", err)
 	}
 	c.ServeJSON()
 }
+`
+	ServiceTPL = `package {{modelName}}Service
+
+import (
+	"context"
+
+	{{modelName}}Model "{{pkgPath}}/models/{{subPath}}"
+	{{pkg}}
+	"github.com/jinzhu/gorm"
+	"github.com/yimishiji/bee/pkg/base"
+	"github.com/yimishiji/bee/pkg/db"
+	"github.com/yimishiji/bee/pkg/filters"
+)
+
+// Service holds the business logic of {{modelName}}, acting for User.
+// Put the domain rules of {{modelName}} here instead of in the controller.
+type Service struct {
+	User *base.User
+
+	// Tx runs every read and write of the service in the transaction when set
+	Tx *gorm.DB
+
+	// ReadPrimary reads from the primary database instead of a replica
+	ReadPrimary bool
+
+	// Ctx bounds every query of the service, which is aborted once Ctx is cancelled or times out
+	Ctx context.Context
+}
+
+// New returns a {{modelName}} service acting for user, tx may be nil
+func New(user *base.User, tx *gorm.DB) *Service {
+	return &Service{User: user, Tx: tx}
+}
+
+// readConn returns the connection reads go to, bound to s.Ctx
+func (s *Service) readConn() *gorm.DB {
+	if s.Tx != nil {
+		return db.WithContext(s.Ctx, s.Tx)
+	}
+	return db.WithContext(s.Ctx, {{modelName}}Model.ReadConn(s.ReadPrimary))
+}
+
+
+// writeConn returns the connection writes go to, bound to s.Ctx
+func (s *Service) writeConn() *gorm.DB {
+	if s.Tx != nil {
+		return db.WithContext(s.Ctx, s.Tx)
+	}
+	return db.WithContext(s.Ctx, {{modelName}}Model.Conn())
+}
+
+// Transaction runs fn with a copy of the service whose reads and writes share one transaction,
+// committed when fn returns nil. Inside s.Tx it nests by a savepoint instead.
+// Pass tx.Tx to the services of other models to make their writes part of it
+func (s *Service) Transaction(fn func(tx *Service) error) error {
+	return db.TransactionOn(s.writeConn(), func(conn *gorm.DB) error {
+		tx := *s
+		tx.Tx = conn
+		return fn(&tx)
+	})
+}
+
+// Create fills the created fields and inserts a new {{modelName}}
+func (s *Service) Create(v *{{modelName}}Model.Model) error {
+	{{createAuto}}
+	return {{modelName}}Model.AddOn(s.writeConn(), v)
+}
+
+// cacheable reports whether reads may be served from the model cache:
+// not inside a transaction and not pinned to the primary
+func (s *Service) cacheable() bool {
+	return s.Tx == nil && !s.ReadPrimary
+}
+
+// Get retrieves {{modelName}} by Id with the given relations
+func (s *Service) Get(id int, relations ...string) ({{modelName}}Model.Model, error) {
+	if len(relations) == 0 && s.cacheable() {
+		return {{modelName}}Model.GetByIdCachedOn(db.WithContext(s.Ctx, {{modelName}}Model.CacheConn()), id)
+	}
+	return {{modelName}}Model.GetByIdOn(s.readConn(), id, relations...)
+}
+
+// GetForUpdate retrieves {{modelName}} by Id from the primary, to be changed and passed to Update
+func (s *Service) GetForUpdate(id int) ({{modelName}}Model.Model, error) {
+	return {{modelName}}Model.GetByIdOn(s.writeConn(), id)
+}
+
+// List retrieves the {{modelName}} page matching the list params and the total count
+func (s *Service) List(p *filters.PageCommonParams) ([]{{modelName}}Model.Model, filters.PageResult, error) {
+	if s.cacheable() {
+		return {{modelName}}Model.GetAllCachedOn(db.WithContext(s.Ctx, {{modelName}}Model.CacheConn()), p)
+	}
+	return {{modelName}}Model.GetAllOn(s.readConn(), p)
+}
+
+// Stats aggregates {{modelName}} by the group and aggregate params
+func (s *Service) Stats(p *filters.PageCommonParams) ([]map[string]interface{}, filters.PageResult, error) {
+	return {{modelName}}Model.StatsOn(s.readConn(), p)
+}
+
+// Update fills the updated fields and saves {{modelName}}
+func (s *Service) Update(v *{{modelName}}Model.Model) error {
+	{{updateAuto}}
+	return {{modelName}}Model.UpdateOn(s.writeConn(), v)
+}
+
+// Delete deletes {{modelName}} by Id
+func (s *Service) Delete(id int) error {
+	return {{modelName}}Model.DeleteOn(s.writeConn(), id)
+}
 `
 	FilterTPL = `
 package {{modelName}}Filter
@@ -1815,6 +2570,7 @@ import (
 	"encoding/json"
 	"errors"
 
+	{{pkg}}
 	"github.com/astaxie/beego/context"
 	"github.com/astaxie/beego/validation"
 	"github.com/yimishiji/bee/pkg/filters"
@@ -1895,6 +2651,8 @@ func (this *Filter) GetListPrams() (params *filters.PageCommonParams, err error)
 `
 	FilterValidRuleTPL = `
 		valid.{{validFunc}}(v.{{colName}}, "{{colColumn}}").Message("{{msg}}")`
+	FilterDecimalRuleTPL = `
+		valid.Check(v.{{colName}}, filters.DecimalPrecision{Digits: {{digits}}, Decimals: {{decimals}}, Key: "{{colColumn}}"}).Message("{{colColumn}} must have at most {{digits}} digits and {{decimals}} decimals")`
 	RouterTPL = `// @APIVersion 1.0.0
 // @Title beego Test API
 // @Description beego has a very cool tools to autogenerate documents for your API
@@ -0,0 +1,48 @@
+package generate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagComment(t *testing.T) {
+	tests := []struct {
+		comment, want string
+	}{
+		{"plain", "plain"},
+		{`say "hi"`, `say "hi"`},
+		{"use `code`", "use 'code'"},
+		{`C:\path`, `C:\path`},
+		{"line1\r\nline2\nline3", "line1 line2 line3"},
+	}
+	for _, tt := range tests {
+		tag := reflect.StructTag(`json:"a" description:"` + tagComment(tt.comment) + `"`)
+		if got := tag.Get("description"); got != tt.want {
+			t.Errorf("description of %q = %q, want %q", tt.comment, got, tt.want)
+		}
+		if got := tag.Get("json"); got != "a" {
+			t.Errorf("json tag after %q = %q, want a", tt.comment, got)
+		}
+	}
+}
+
+func TestIsHiddenColumn(t *testing.T) {
+	defer func(old []string) { HiddenColumns = old }(HiddenColumns)
+	HiddenColumns = []string{"member.mobile", "*_code"}
+	tests := []struct {
+		table, column string
+		want          bool
+	}{
+		{"member", "password", true},
+		{"member", "api_token", true},
+		{"member", "mobile", true},
+		{"order", "mobile", false},
+		{"order", "invite_code", true},
+		{"order", "title", false},
+	}
+	for _, tt := range tests {
+		if got := isHiddenColumn(tt.table, tt.column); got != tt.want {
+			t.Errorf("isHiddenColumn(%q, %q) = %v, want %v", tt.table, tt.column, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	beeLogger "github.com/yimishiji/bee/logger"
+	"github.com/yimishiji/bee/logger/colors"
+	"github.com/yimishiji/bee/utils"
+)
+
+// GenerateErd reverse engineers the database schema into ER diagrams
+// (Mermaid, Graphviz DOT, PlantUML) and a Markdown/HTML data dictionary
+// under docs/database
+func GenerateErd(driver, connStr, tables, currpath string) {
+	var selectedTables map[string]bool
+	if tables != "" {
+		selectedTables = make(map[string]bool)
+		for _, v := range strings.Split(tables, ",") {
+			selectedTables[v] = true
+		}
+	}
+	trans, ok := dbDriver[driver]
+	if !ok {
+		beeLogger.Log.Fatalf("Generating ER diagram from '%s' database is not supported yet.", driver)
+	}
+
+	db, err := sql.Open(driver, connStr)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not connect to '%s' database using '%s': %s", driver, connStr, err)
+	}
+	defer db.Close()
+
+	beeLogger.Log.Info("Analyzing database tables...")
+	tableNames := getSelectedTableNames(selectedTables, db, trans)
+	sort.Strings(tableNames)
+	tableObjects := getTableObjects(tableNames, db, trans)
+
+	docPath := path.Join(currpath, "docs", "database")
+	if err := os.MkdirAll(docPath, 0777); err != nil {
+		beeLogger.Log.Fatalf("Could not create '%s': %s", docPath, err)
+	}
+
+	beeLogger.Log.Info("Creating ER diagram files...")
+	writeErdFile(path.Join(docPath, "erd.mmd"), erdMermaid(tableObjects))
+	writeErdFile(path.Join(docPath, "erd.dot"), erdDot(tableObjects))
+	writeErdFile(path.Join(docPath, "erd.puml"), erdPlantUML(tableObjects))
+
+	beeLogger.Log.Info("Creating data dictionary files...")
+	writeErdFile(path.Join(docPath, "data-dictionary.md"), dataDictionaryMarkdown(tableObjects))
+	writeErdFile(path.Join(docPath, "data-dictionary.html"), dataDictionaryHTML(tableObjects))
+}
+
+// writeErdFile writes a generated document, asking before overwriting it
+func writeErdFile(fpath string, content string) {
+	w := colors.NewColorWriter(os.Stdout)
+
+	if utils.IsExist(fpath) {
+		beeLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
+		if !utils.AskForConfirmation() {
+			beeLogger.Log.Warnf("Skipped create file '%s'", fpath)
+			return
+		}
+	}
+	utils.WriteToFile(fpath, content)
+	fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
+}
+
+// columnKeys returns the PK/FK/UK markers of a column
+func columnKeys(tb *Table, col *Column) (keys []string) {
+	if tb.Pk != "" && tb.Pk == col.Tag.Column {
+		keys = append(keys, "PK")
+	}
+	if _, ok := tb.Fk[col.Tag.Column]; ok {
+		keys = append(keys, "FK")
+	}
+	for _, uk := range tb.Uk {
+		if uk == col.Tag.Column {
+			keys = append(keys, "UK")
+			break
+		}
+	}
+	return
+}
+
+// sortedForeignKeys returns the foreign keys of a table ordered by column name
+func sortedForeignKeys(tb *Table) (fks []*ForeignKey) {
+	var names []string
+	for name := range tb.Fk {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fks = append(fks, tb.Fk[name])
+	}
+	return
+}
+
+var mermaidIdentRegex = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// mermaidIdent makes an identifier safe to use as a mermaid entity or type name
+func mermaidIdent(s string) string {
+	return strings.Trim(mermaidIdentRegex.ReplaceAllString(s, "_"), "_")
+}
+
+// erdMermaid renders the tables as a mermaid erDiagram
+func erdMermaid(tables []*Table) string {
+	var buf bytes.Buffer
+	buf.WriteString("erDiagram\n")
+	for _, tb := range tables {
+		fmt.Fprintf(&buf, "    %s {\n", mermaidIdent(tb.Name))
+		for _, col := range tb.Columns {
+			fmt.Fprintf(&buf, "        %s %s", mermaidIdent(col.DataType), mermaidIdent(col.Tag.Column))
+			if keys := columnKeys(tb, col); len(keys) > 0 {
+				fmt.Fprintf(&buf, " %s", strings.Join(keys, ","))
+			}
+			if col.Tag.Comment != "" {
+				fmt.Fprintf(&buf, " %q", strings.Replace(col.Tag.Comment, "\"", "'", -1))
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("    }\n")
+	}
+	for _, tb := range tables {
+		for _, fk := range sortedForeignKeys(tb) {
+			fmt.Fprintf(&buf, "    %s ||--o{ %s : \"%s\"\n", mermaidIdent(fk.RefTable), mermaidIdent(tb.Name), fk.Name)
+		}
+	}
+	return buf.String()
+}
+
+var dotRecordReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `{`, `\{`, `}`, `\}`, `|`, `\|`, `<`, `\<`, `>`, `\>`)
+
+// erdDot renders the tables as a graphviz digraph of record nodes
+func erdDot(tables []*Table) string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph erd {\n")
+	buf.WriteString("    graph [rankdir=LR];\n")
+	buf.WriteString("    node [shape=record, fontsize=10];\n")
+	buf.WriteString("    edge [arrowhead=crow, arrowtail=none];\n\n")
+	for _, tb := range tables {
+		var fields []string
+		for _, col := range tb.Columns {
+			field := col.Tag.Column + " : " + col.ColumnType
+			if keys := columnKeys(tb, col); len(keys) > 0 {
+				field += " [" + strings.Join(keys, ",") + "]"
+			}
+			fields = append(fields, dotRecordReplacer.Replace(field)+`\l`)
+		}
+		fmt.Fprintf(&buf, "    \"%s\" [label=\"{%s|%s}\"];\n", tb.Name, dotRecordReplacer.Replace(tb.Name), strings.Join(fields, ""))
+	}
+	buf.WriteString("\n")
+	for _, tb := range tables {
+		for _, fk := range sortedForeignKeys(tb) {
+			fmt.Fprintf(&buf, "    \"%s\" -> \"%s\" [label=\"%s\"];\n", fk.RefTable, tb.Name, fk.Name)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// erdPlantUML renders the tables as plantuml entities
+func erdPlantUML(tables []*Table) string {
+	var buf bytes.Buffer
+	buf.WriteString("@startuml\n")
+	buf.WriteString("hide circle\n")
+	buf.WriteString("skinparam linetype ortho\n\n")
+	for _, tb := range tables {
+		fmt.Fprintf(&buf, "entity \"%s\" as %s {\n", tb.Name, mermaidIdent(tb.Name))
+		var pkLines, colLines []string
+		for _, col := range tb.Columns {
+			line := "  "
+			if !col.Nullable {
+				line += "* "
+			}
+			line += col.Tag.Column + " : " + col.ColumnType
+			for _, key := range columnKeys(tb, col) {
+				line += " <<" + key + ">>"
+			}
+			if col.Tag.Comment != "" {
+				line += " -- " + col.Tag.Comment
+			}
+			if tb.Pk != "" && tb.Pk == col.Tag.Column {
+				pkLines = append(pkLines, line)
+			} else {
+				colLines = append(colLines, line)
+			}
+		}
+		for _, line := range pkLines {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString("  --\n")
+		for _, line := range colLines {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString("}\n\n")
+	}
+	for _, tb := range tables {
+		for _, fk := range sortedForeignKeys(tb) {
+			fmt.Fprintf(&buf, "%s }o--|| %s : %s\n", mermaidIdent(tb.Name), mermaidIdent(fk.RefTable), fk.Name)
+		}
+	}
+	buf.WriteString("@enduml\n")
+	return buf.String()
+}
+
+var markdownCellReplacer = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+// dataDictionaryMarkdown renders every table and column as markdown tables
+func dataDictionaryMarkdown(tables []*Table) string {
+	var buf bytes.Buffer
+	buf.WriteString("# Data Dictionary\n\n")
+	for _, tb := range tables {
+		fmt.Fprintf(&buf, "- [%s](#%s)\n", tb.Name, strings.ToLower(tb.Name))
+	}
+	for _, tb := range tables {
+		fmt.Fprintf(&buf, "\n## %s\n\n", tb.Name)
+		buf.WriteString("| Column | Type | Null | Default | Key | Comment |\n")
+		buf.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+		for _, col := range tb.Columns {
+			fmt.Fprintf(&buf, "| %s | %s | %s | %s | %s | %s |\n",
+				markdownCellReplacer.Replace(col.Tag.Column),
+				markdownCellReplacer.Replace(col.ColumnType),
+				yesNo(col.Nullable),
+				markdownCellReplacer.Replace(col.ColumnDefault),
+				strings.Join(columnKeys(tb, col), ","),
+				markdownCellReplacer.Replace(col.Tag.Comment))
+		}
+		if fks := sortedForeignKeys(tb); len(fks) > 0 {
+			buf.WriteString("\nForeign keys:\n\n")
+			for _, fk := range fks {
+				fmt.Fprintf(&buf, "- `%s` → `%s.%s`\n", fk.Name, fk.RefTable, fk.RefColumn)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// dataDictionaryHTML renders every table and column as a standalone html page
+func dataDictionaryHTML(tables []*Table) string {
+	var buf bytes.Buffer
+	buf.WriteString(dataDictionaryHTMLHeadTPL)
+	buf.WriteString("<ul>\n")
+	for _, tb := range tables {
+		fmt.Fprintf(&buf, "<li><a href=\"#%s\">%s</a></li>\n", html.EscapeString(tb.Name), html.EscapeString(tb.Name))
+	}
+	buf.WriteString("</ul>\n")
+	for _, tb := range tables {
+		fmt.Fprintf(&buf, "<h2 id=\"%s\">%s</h2>\n", html.EscapeString(tb.Name), html.EscapeString(tb.Name))
+		buf.WriteString("<table>\n<tr><th>Column</th><th>Type</th><th>Null</th><th>Default</th><th>Key</th><th>Comment</th></tr>\n")
+		for _, col := range tb.Columns {
+			fmt.Fprintf(&buf, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+				html.EscapeString(col.Tag.Column),
+				html.EscapeString(col.ColumnType),
+				yesNo(col.Nullable),
+				html.EscapeString(col.ColumnDefault),
+				strings.Join(columnKeys(tb, col), ","),
+				html.EscapeString(col.Tag.Comment))
+		}
+		buf.WriteString("</table>\n")
+		if fks := sortedForeignKeys(tb); len(fks) > 0 {
+			buf.WriteString("<p>Foreign keys:</p>\n<ul>\n")
+			for _, fk := range fks {
+				fmt.Fprintf(&buf, "<li><code>%s</code> &rarr; <a href=\"#%s\"><code>%s.%s</code></a></li>\n",
+					html.EscapeString(fk.Name), html.EscapeString(fk.RefTable), html.EscapeString(fk.RefTable), html.EscapeString(fk.RefColumn))
+			}
+			buf.WriteString("</ul>\n")
+		}
+	}
+	buf.WriteString("</body>\n</html>\n")
+	return buf.String()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
+
+const dataDictionaryHTMLHeadTPL = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Data Dictionary</title>
+<style>
+    body { font-family: -apple-system, "Helvetica Neue", Arial, sans-serif; margin: 20px; }
+    table { border-collapse: collapse; margin-bottom: 10px; }
+    th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; font-size: 13px; }
+    th { background: #f5f5f5; }
+</style>
+</head>
+<body>
+<h1>Data Dictionary</h1>
+`
@@ -0,0 +1,108 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+)
+
+// erdTestTables returns member and member_coupon, the coupon referencing the member
+func erdTestTables() []*Table {
+	column := func(name, dataType, columnType string, nullable bool, comment string) *Column {
+		return &Column{
+			Tag:        &OrmTag{Column: name, Comment: comment},
+			DataType:   dataType,
+			ColumnType: columnType,
+			Nullable:   nullable,
+		}
+	}
+	member := &Table{
+		Name: "member",
+		Pk:   "id",
+		Uk:   []string{"mobile"},
+		Columns: []*Column{
+			column("id", "int", "int(11)", false, ""),
+			column("mobile", "varchar", "varchar(20)", false, `mobile "login" | <name>`),
+		},
+	}
+	coupon := &Table{
+		Name: "member_coupon",
+		Pk:   "id",
+		Fk:   map[string]*ForeignKey{"member_id": {Name: "member_id", RefTable: "member", RefColumn: "id"}},
+		Columns: []*Column{
+			column("id", "int", "int(11)", false, ""),
+			column("member_id", "int", "int(11)", false, ""),
+			column("amount", "decimal", "decimal(10,2)", true, "amount\nin yuan"),
+		},
+	}
+	return []*Table{member, coupon}
+}
+
+func assertContains(t *testing.T, format, out string, wants ...string) {
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("%s output missing %q:\n%s", format, want, out)
+		}
+	}
+}
+
+func TestErdMermaid(t *testing.T) {
+	out := erdMermaid(erdTestTables())
+	assertContains(t, "mermaid", out,
+		"erDiagram\n",
+		"    member {\n",
+		"        int id PK\n",
+		`        varchar mobile UK "mobile 'login' | <name>"`,
+		"        int member_id FK\n",
+		`    member ||--o{ member_coupon : "member_id"`,
+	)
+}
+
+func TestErdDot(t *testing.T) {
+	out := erdDot(erdTestTables())
+	assertContains(t, "dot", out,
+		"digraph erd {\n",
+		`    "member" [label="{member|id : int(11) [PK]\lmobile : varchar(20) [UK]\l}"];`,
+		`member_id : int(11) [FK]\l`,
+		`    "member" -> "member_coupon" [label="member_id"];`,
+	)
+	if !strings.HasSuffix(out, "}\n") {
+		t.Errorf("dot output not closed:\n%s", out)
+	}
+}
+
+func TestErdPlantUML(t *testing.T) {
+	out := erdPlantUML(erdTestTables())
+	assertContains(t, "plantuml", out,
+		"@startuml\n",
+		"entity \"member\" as member {\n  * id : int(11) <<PK>>\n  --\n",
+		"  * mobile : varchar(20) <<UK>> -- mobile \"login\" | <name>\n",
+		"  amount : decimal(10,2)",
+		"member_coupon }o--|| member : member_id\n",
+		"@enduml\n",
+	)
+}
+
+func TestDataDictionaryMarkdown(t *testing.T) {
+	out := dataDictionaryMarkdown(erdTestTables())
+	assertContains(t, "markdown", out,
+		"- [member_coupon](#member_coupon)\n",
+		"## member\n",
+		`| mobile | varchar(20) | NO |  | UK | mobile "login" \| <name> |`,
+		"| amount | decimal(10,2) | YES |  |  | amount<br>in yuan |",
+		"- `member_id` → `member.id`\n",
+	)
+}
+
+func TestDataDictionaryHTML(t *testing.T) {
+	out := dataDictionaryHTML(erdTestTables())
+	assertContains(t, "html", out,
+		`<li><a href="#member">member</a></li>`,
+		`<h2 id="member_coupon">member_coupon</h2>`,
+		"<td>mobile &#34;login&#34; | &lt;name&gt;</td>",
+		`<a href="#member"><code>member.id</code></a>`,
+		"</html>\n",
+	)
+	if strings.Contains(out, "<name>") {
+		t.Errorf("html output has unescaped comment:\n%s", out)
+	}
+}
@@ -378,15 +378,10 @@ func writeHproseModelFiles(tables []*Table, mPath string, selectedTables map[str
 		}
 		fileStr := strings.Replace(template, "{{modelStruct}}", tb.String(), 1)
 		fileStr = strings.Replace(fileStr, "{{modelName}}", utils.CamelCase(tb.Name), -1)
-		// if table contains time field, import time.Time package
-		timePkg := ""
-		importTimePkg := ""
-		if tb.ImportTimePkg {
-			timePkg = "\"time\"\n"
-			importTimePkg = "import \"time\"\n"
-		}
-		fileStr = strings.Replace(fileStr, "{{timePkg}}", timePkg, -1)
-		fileStr = strings.Replace(fileStr, "{{importTimePkg}}", importTimePkg, -1)
+		// import the packages of time, json... field types
+		pkgs := importPkgs(tb.Columns)
+		fileStr = strings.Replace(fileStr, "{{timePkg}}", importSpecs(pkgs), -1)
+		fileStr = strings.Replace(fileStr, "{{importTimePkg}}", importDecl(pkgs), -1)
 		if _, err := f.WriteString(fileStr); err != nil {
 			beeLogger.Log.Fatalf("Could not write model file to '%s'", fpath)
 		}
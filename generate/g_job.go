@@ -0,0 +1,114 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	beeLogger "github.com/yimishiji/bee/logger"
+	"github.com/yimishiji/bee/logger/colors"
+	"github.com/yimishiji/bee/utils"
+)
+
+// GenerateJob writes a queue job skeleton to commands/jobs/<name>/job.go.
+// The name may be given as send-sms, send_sms or SendSms.
+func GenerateJob(jname, currpath string) {
+	w := colors.NewColorWriter(os.Stdout)
+
+	snakeName := utils.SnakeString(strings.Replace(jname, "-", "_", -1))
+	jobName := utils.CamelCase(snakeName)
+	dirName := strings.Replace(snakeName, "_", "-", -1)
+
+	beeLogger.Log.Infof("Using '%s' as job name", jobName)
+	beeLogger.Log.Infof("Using '%s' as queue name", snakeName)
+
+	fp := path.Join(currpath, "commands", "jobs", dirName)
+	if _, err := os.Stat(fp); os.IsNotExist(err) {
+		if err := os.MkdirAll(fp, 0777); err != nil {
+			beeLogger.Log.Fatalf("Could not create jobs directory: %s", err)
+		}
+	}
+
+	fpath := path.Join(fp, "job.go")
+	f, err := os.OpenFile(fpath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not create job file: %s", err)
+	}
+	defer utils.CloseFile(f)
+
+	content := strings.Replace(jobTpl, "{{jobName}}", jobName, -1)
+	content = strings.Replace(content, "{{queueName}}", snakeName, -1)
+	f.WriteString(content)
+
+	// Run 'gofmt' on the generated source code
+	utils.FormatSourceCode(fpath)
+	fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
+}
+
+var jobTpl = `package {{jobName}}Job
+
+import (
+	"context"
+	"errors"
+
+	"github.com/astaxie/beego"
+	"github.com/yimishiji/bee/pkg/queue"
+)
+
+// Queue is the name of the queue the job is pushed to.
+const Queue = "{{queueName}}"
+
+// Concurrency is the number of jobs handled at the same time by one process.
+var Concurrency = 4
+
+// Payload is the data carried by the job, stored as JSON.
+type Payload struct {
+	Id int ` + "`json:\"id\"`" + `
+}
+
+// Enqueue pushes a job, e.g. Enqueue(Payload{Id: 1}, queue.Delay(time.Minute)).
+// Jobs are delivered at least once, so Handle should be idempotent.
+func Enqueue(p Payload, opts ...queue.Option) error {
+	_, err := queue.Enqueue(Queue, p, opts...)
+	return err
+}
+
+// Handle processes one job. A returned error retries the job with backoff;
+// wrap it with queue.Permanent to move the job to the dead-letter queue instead.
+func Handle(ctx context.Context, job *queue.Job) error {
+	var p Payload
+	if err := job.Bind(&p); err != nil {
+		return queue.Permanent(err)
+	}
+	if p.Id == 0 {
+		return queue.Permanent(errors.New("{{queueName}}: empty id"))
+	}
+
+	// Long running handlers should return when ctx is cancelled on shutdown.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	beego.Info("{{queueName}} job", job.ID, p.Id, "attempt", job.Attempts+1)
+	return nil
+}
+
+// Register registers the consumer, started by queue.StartAll.
+func Register() {
+	queue.Register(Queue, Handle, Concurrency)
+}
+`
@@ -15,11 +15,15 @@
 package base
 
 import (
+	stdcontext "context"
+	"crypto/rand"
+	"encoding/hex"
 	"strings"
 	"time"
 
 	"github.com/astaxie/beego"
 	"github.com/astaxie/beego/context"
+	"github.com/yimishiji/bee/pkg/db"
 )
 
 type beeController struct {
@@ -31,8 +35,18 @@ type Controller struct {
 	beeController
 	accessToken string
 	User        User
+
+	//本次请求的查询读主库, 用于读取刚写入的数据
+	ReadPrimary bool
+
+	requestId string
+	ctx       stdcontext.Context
+	cancel    stdcontext.CancelFunc
 }
 
+//请求id的请求头和响应头
+const RequestIdHeader = "X-Request-Id"
+
 // Init generates default values of controller operations.
 func (c *Controller) Init(ctx *context.Context, controllerName, actionName string, app interface{}) {
 	c.beeController.Init(ctx, controllerName, actionName, app)
@@ -41,6 +55,56 @@ func (c *Controller) Init(ctx *context.Context, controllerName, actionName strin
 	token := c.Ctx.Input.Header("Authorization")
 	token = strings.Replace(token, "Bearer ", "", 1)
 	c.User.AccessToken = token
+
+	//请求id, 沿用上游传入的, 否则生成
+	c.requestId = c.Ctx.Input.Header(RequestIdHeader)
+	if c.requestId == "" || len(c.requestId) > 64 {
+		c.requestId = newRequestId()
+	}
+	c.Ctx.Output.Header(RequestIdHeader, c.requestId)
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//本次请求的id, 同时写在响应头 X-Request-Id 和sql日志中
+func (c *Controller) RequestId() string {
+	return c.requestId
+}
+
+//本次请求的 context, 携带请求id, 客户端断开时取消; [db] 配置了 query_timeout 时加上超时
+//传给 service 或 model 的 *Ctx 函数, 请求结束后查询随之中止
+func (c *Controller) Context() stdcontext.Context {
+	if c.ctx == nil {
+		c.ctx, c.cancel = db.TimeoutContext(db.WithRequestId(c.Ctx.Request.Context(), c.requestId), "")
+	}
+	return c.ctx
+}
+
+// Finish 请求结束时释放 context
+func (c *Controller) Finish() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+//本次请求之后的查询改读主库
+func (c *Controller) ReadYourWrites() {
+	c.ReadPrimary = true
+}
+
+//错误输出, 查询因请求取消或超时中止时使用 ApiCode_CANCELED、ApiCode_TIMEOUT
+func (c *Controller) ErrResp(appCode ApiCode, msg string, err error) *Resp {
+	switch {
+	case db.IsCanceled(err):
+		return c.Resp(ApiCode_CANCELED, "request canceled", err.Error())
+	case db.IsTimeout(err):
+		return c.Resp(ApiCode_TIMEOUT, "query timeout", err.Error())
+	}
+	return c.Resp(appCode, msg, err.Error())
 }
 
 //输出格式统一处理
@@ -11,6 +11,9 @@ type ApiCode int32
 // PARAM_ERROR    		= -2;// 请求的参数错误或者未通过验证
 // VALIDATE_ERROR 		= -3;// 验证失败
 // ILLEGAL_ERROR  		= -4;// 非法操作
+// CANCELED       		= -5;// 请求已取消，一般为客户端断开
+// TIMEOUT        		= -6;// 查询超时
+// RATE_LIMITED   		= -7;// 请求过于频繁，被限流
 // ApiCode_OAUTH_ERROR  = -20001;// 认证失败
 const (
 	ApiCode_SUCC_11        ApiCode = 11
@@ -21,6 +24,9 @@ const (
 	ApiCode_PARAM_ERROR    ApiCode = -2
 	ApiCode_VALIDATE_ERROR ApiCode = -3
 	ApiCode_ILLEGAL_ERROR  ApiCode = -4
+	ApiCode_CANCELED       ApiCode = -5
+	ApiCode_TIMEOUT        ApiCode = -6
+	ApiCode_RATE_LIMITED   ApiCode = -7
 	ApiCode_OAUTH_ERROR    ApiCode = -20001
 	ApiCode_OAUTH_FAIL     ApiCode = -10003
 )
@@ -41,6 +47,8 @@ type ListPageData struct {
 	Offset     int64       `json:"offset"`
 	TotalCount int64       `json:"totalCount"`
 	List       interface{} `json:"list"`
+	NextCursor string      `json:"next_cursor,omitempty"` //游标分页的下一页游标
+	HasMore    bool        `json:"has_more"`
 }
 
 // ListPageDataFormat
@@ -70,3 +78,14 @@ func NewListPageData(limit int64, offset int64, totalCount int64, data interface
 	}
 	//}
 }
+
+//生成游标分页数据结构, totalCount 为 -1 时表示未统计
+func NewCursorPageData(limit int64, totalCount int64, nextCursor string, hasMore bool, data interface{}) *ListPageData {
+	return &ListPageData{
+		Limit:      limit,
+		TotalCount: totalCount,
+		List:       data,
+		NextCursor: nextCursor,
+		HasMore:    hasMore,
+	}
+}
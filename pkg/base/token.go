@@ -0,0 +1,169 @@
+package base
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+//token 不存在或已过期
+var ErrTokenNotFound = errors.New("token not found")
+
+//登录信息的存储, 按 access token 保存用户信息和操作权限
+type TokenStore interface {
+	//用户信息, token 不存在时返回 ErrTokenNotFound
+	UserInfo(token string) (*UserInfo, error)
+	//操作权限, 如 [GET]/object
+	Rights(token string) ([]string, error)
+	//保存登录信息, ttl 后过期
+	Save(token string, info *UserInfo, rights []string, ttl time.Duration) error
+	//删除登录信息(退出登录)
+	Delete(token string) error
+}
+
+//登录信息的存储, 默认使用 db.Redis; 测试中可替换为 NewMemoryTokenStore()
+var Tokens TokenStore = RedisTokenStore{}
+
+//权限在 redis 中的格式
+type tokenRight struct {
+	RightAction string
+}
+
+//redis 中的登录信息, 键为 <token>:info 和 <token>:right
+type RedisTokenStore struct{}
+
+func (RedisTokenStore) get(key string, v interface{}) error {
+	if db.Redis == nil {
+		return ErrTokenNotFound
+	}
+	data, err := db.Redis.Get(key).Result()
+	if err == redis.Nil {
+		return ErrTokenNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), v)
+}
+
+func (s RedisTokenStore) UserInfo(token string) (*UserInfo, error) {
+	info := new(UserInfo)
+	if err := s.get(token+":info", info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s RedisTokenStore) Rights(token string) ([]string, error) {
+	var list []tokenRight
+	if err := s.get(token+":right", &list); err != nil {
+		return nil, err
+	}
+	rights := make([]string, len(list))
+	for i, r := range list {
+		rights[i] = r.RightAction
+	}
+	return rights, nil
+}
+
+func (RedisTokenStore) Save(token string, info *UserInfo, rights []string, ttl time.Duration) error {
+	if db.Redis == nil {
+		return errors.New("redis not connected")
+	}
+	list := make([]tokenRight, len(rights))
+	for i, r := range rights {
+		list[i] = tokenRight{RightAction: r}
+	}
+	_, err := db.Redis.Pipelined(func(pipe db.RedisPipeliner) error {
+		pipe.Set(token+":info", info, ttl)
+		pipe.Set(token+":right", list, ttl)
+		return nil
+	})
+	return err
+}
+
+func (RedisTokenStore) Delete(token string) error {
+	if db.Redis == nil {
+		return nil
+	}
+	//逐个删除, cluster 模式下多个键可能不在同一个节点
+	_, err := db.Redis.Pipelined(func(pipe db.RedisPipeliner) error {
+		for _, suffix := range []string{":info", ":right", ":token"} {
+			pipe.Del(token + suffix)
+		}
+		return nil
+	})
+	return err
+}
+
+//内存中的登录信息, 用于测试
+//	base.Tokens = base.NewMemoryTokenStore()
+//	base.Tokens.Save("test-token", &base.UserInfo{UserID: 1}, []string{"[GET]/object"}, time.Hour)
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]memoryToken
+}
+
+type memoryToken struct {
+	info     UserInfo
+	rights   []string
+	expireAt time.Time
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: map[string]memoryToken{}}
+}
+
+func (s *MemoryTokenStore) get(token string) (memoryToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[token]
+	if !ok {
+		return t, ErrTokenNotFound
+	}
+	if !t.expireAt.IsZero() && !time.Now().Before(t.expireAt) {
+		delete(s.tokens, token)
+		return t, ErrTokenNotFound
+	}
+	return t, nil
+}
+
+func (s *MemoryTokenStore) UserInfo(token string) (*UserInfo, error) {
+	t, err := s.get(token)
+	if err != nil {
+		return nil, err
+	}
+	info := t.info
+	return &info, nil
+}
+
+func (s *MemoryTokenStore) Rights(token string) ([]string, error) {
+	t, err := s.get(token)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), t.rights...), nil
+}
+
+//ttl 为0时不过期
+func (s *MemoryTokenStore) Save(token string, info *UserInfo, rights []string, ttl time.Duration) error {
+	t := memoryToken{info: *info, rights: append([]string(nil), rights...)}
+	if ttl > 0 {
+		t.expireAt = time.Now().Add(ttl)
+	}
+	s.mu.Lock()
+	s.tokens[token] = t
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryTokenStore) Delete(token string) error {
+	s.mu.Lock()
+	delete(s.tokens, token)
+	s.mu.Unlock()
+	return nil
+}
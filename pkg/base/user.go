@@ -1,11 +1,7 @@
 package base
 
 import (
-	"encoding/json"
-
 	"strconv"
-
-	"github.com/yimishiji/bee/pkg/db"
 )
 
 // 会员基本信息
@@ -82,20 +78,13 @@ type Token struct {
 	UserID       string `json:"user_id"`
 }
 
-//登录
+//登录, 从 Tokens 中读取 token 对应的用户信息
 func (u *User) Login() {
 	if u.AccessToken == "" || u.Id != "" {
 		return
 	}
 
-	userInfoKey := u.AccessToken + ":info"
-	userToken, err := db.Redis.Get(userInfoKey).Result()
-	if err != nil {
-		return
-	}
-
-	userInfo := new(UserInfo)
-	err = json.Unmarshal([]byte(userToken), &userInfo)
+	userInfo, err := Tokens.UserInfo(u.AccessToken)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,73 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+//命令行中查看和执行任务, args 为:
+//	list            列出任务及最近一次执行结果
+//	run <name>      立即执行任务
+//	history <name>  任务最近的执行记录
+func Command(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		PrintList(w)
+		return nil
+	case "run":
+		if len(args) < 2 {
+			return fmt.Errorf("cron: usage: run <name>")
+		}
+		start := time.Now()
+		err := Trigger(context.Background(), args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s done in %s\n", args[1], time.Since(start))
+		return nil
+	case "history":
+		if len(args) < 2 {
+			return fmt.Errorf("cron: usage: history <name>")
+		}
+		return PrintHistory(w, args[1], 20)
+	}
+	return fmt.Errorf("cron: unknown command %q, use list, run or history", args[0])
+}
+
+//以表格输出任务列表
+func PrintList(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSPEC\tRUNNING\tLAST RUN\tDURATION\tNEXT\tERROR\tDESCRIPTION")
+	for _, st := range List() {
+		fmt.Fprintf(tw, "%s\t%s\t%v\t%s\t%s\t%s\t%s\t%s\n",
+			st.Name, st.Spec, st.Running, formatTime(st.LastRun), st.Duration, formatTime(st.Next), st.Error, st.Description)
+	}
+	tw.Flush()
+}
+
+//以表格输出任务最近 limit 次执行记录
+func PrintHistory(w io.Writer, name string, limit int64) error {
+	runs, err := History(name, limit)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "START\tDURATION\tERROR")
+	for _, run := range runs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", formatTime(run.Start), time.Duration(run.DurationMs)*time.Millisecond, run.Error)
+	}
+	return tw.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,262 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/astaxie/beego/toolbox"
+	"github.com/yimishiji/bee/pkg/db"
+	"github.com/yimishiji/bee/pkg/lock"
+)
+
+var (
+	//任务不存在
+	ErrNotFound = errors.New("cron: job not found")
+	//上一次执行未结束, 本次跳过
+	ErrRunning = errors.New("cron: job is still running")
+	//执行超时
+	ErrTimeout = errors.New("cron: job timeout")
+)
+
+//单节点执行时锁的过期时间, 执行期间自动续期
+const lockTTL = time.Minute
+
+//定时任务
+//	cron.Register(cron.Job{
+//		Name:    "close-orders",
+//		Spec:    "0 */5 * * * *",
+//		Timeout: 4 * time.Minute,
+//		Single:  true,
+//		Run:     OrderTask.CloseExpired,
+//	})
+type Job struct {
+	Name string
+	//执行时间, 格式同 toolbox: 秒 分 时 日 月 周, 或 @hourly、@daily 等
+	Spec string
+	Run  func(ctx context.Context) error
+	//执行超时, 超时后 ctx 取消并记为失败; 0 不限制
+	Timeout time.Duration
+	//上一次未结束时是否仍然执行, 默认跳过
+	AllowOverlap bool
+	//多节点时只在一个节点执行, 需要 redis
+	Single bool
+	//说明, 用于列表显示
+	Description string
+}
+
+type entry struct {
+	job Job
+	//本进程中执行中的次数, AllowOverlap 时可大于1
+	running int32
+	mu      sync.Mutex
+	//本进程中未记录结果的执行次数, 见 setRunning、finish
+	active int
+	last   Status
+}
+
+var (
+	mu      sync.RWMutex
+	entries = map[string]*entry{}
+	running sync.WaitGroup
+)
+
+//注册定时任务, 名称重复或执行时间格式错误时返回错误
+func Register(jobs ...Job) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, job := range jobs {
+		if job.Name == "" || job.Run == nil {
+			return fmt.Errorf("cron: job %q: name and run are required", job.Name)
+		}
+		if _, ok := entries[job.Name]; ok {
+			return fmt.Errorf("cron: job %q already registered", job.Name)
+		}
+		if err := checkSpec(job.Spec); err != nil {
+			return fmt.Errorf("cron: job %q: %s", job.Name, err)
+		}
+		entries[job.Name] = &entry{job: job, last: Status{Name: job.Name}}
+	}
+	return nil
+}
+
+//注册定时任务, 出错时 panic, 用于 init 中
+func MustRegister(jobs ...Job) {
+	if err := Register(jobs...); err != nil {
+		panic(err)
+	}
+}
+
+//toolbox 遇到格式错误的执行时间会 panic
+func checkSpec(spec string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid spec %q: %v", spec, r)
+		}
+	}()
+	if toolbox.NewTask("", spec, nil).Spec == nil {
+		return fmt.Errorf("invalid spec %q", spec)
+	}
+	return nil
+}
+
+//开始按时间执行已注册的任务; 有 Single 任务而未连接 redis 时返回错误, 不执行任何任务
+func Start() error {
+	mu.RLock()
+	defer mu.RUnlock()
+	if db.Redis == nil {
+		for name, e := range entries {
+			if e.job.Single {
+				return fmt.Errorf("cron: job %q is single but redis is not connected", name)
+			}
+		}
+	}
+	for name, e := range entries {
+		e := e
+		toolbox.AddTask(name, toolbox.NewTask(name, e.job.Spec, func() error {
+			err := e.run(context.Background())
+			if err == ErrRunning {
+				return nil
+			}
+			return err
+		}))
+	}
+	toolbox.StartTask()
+	return nil
+}
+
+//停止定时执行, 等待执行中的任务最多 timeout
+func Stop(timeout time.Duration) {
+	toolbox.StopTask()
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logs.Warn("cron: stop timeout, some jobs are still running")
+	}
+}
+
+//立即执行一次任务并等待结束, 同样记录执行结果
+func Trigger(ctx context.Context, name string) error {
+	mu.RLock()
+	e, ok := entries[name]
+	mu.RUnlock()
+	if !ok {
+		return ErrNotFound
+	}
+	return e.run(ctx)
+}
+
+func (e *entry) run(ctx context.Context) error {
+	//任务函数返回后依次释放, 超时返回时任务可能仍在执行, 这期间同样不重复执行
+	var releases []func()
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	if atomic.AddInt32(&e.running, 1) > 1 && !e.job.AllowOverlap {
+		atomic.AddInt32(&e.running, -1)
+		logs.Warn(fmt.Sprintf("cron %s: still running, skipped", e.job.Name))
+		return ErrRunning
+	}
+	releases = append(releases, func() { atomic.AddInt32(&e.running, -1) })
+
+	if e.job.Single {
+		l, err := lock.Obtain("cron:"+e.job.Name, lockTTL)
+		switch err {
+		case nil:
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(ctx)
+			lost := l.KeepAlive(lockTTL / 3)
+			go func() {
+				//锁丢失时其它节点可能开始执行, 取消本节点的任务
+				select {
+				case <-lost:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			releases = append(releases, func() { l.Release() }, cancel)
+		case lock.ErrNoRedis:
+			//Start 时已检查, 之后 redis 被断开时按单机执行, 每次记录警告
+			logs.Warn(fmt.Sprintf("cron %s: single job but redis is not connected, running on this node", e.job.Name))
+		case lock.ErrNotObtained:
+			//其它节点正在执行
+			release()
+			return nil
+		default:
+			release()
+			return err
+		}
+	}
+
+	running.Add(1)
+	releases = append(releases, running.Done)
+
+	start := time.Now()
+	stop := e.setRunning(start)
+	err := e.call(ctx, release)
+	stop()
+	e.finish(start, time.Since(start), err)
+	if err != nil {
+		logs.Error(fmt.Sprintf("cron %s: %s", e.job.Name, err))
+	}
+	return err
+}
+
+//执行任务, 超时后返回 ErrTimeout, 任务函数应在 ctx 取消后尽快返回; 任务函数返回后调用 release
+func (e *entry) call(ctx context.Context, release func()) error {
+	var cancel context.CancelFunc
+	if e.job.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, e.job.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer release()
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- e.job.Run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return ErrTimeout
+		}
+		return ctx.Err()
+	}
+}
+
+//已注册的任务及最近一次执行结果, 按名称排序
+func List() []Status {
+	mu.RLock()
+	list := make([]Status, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e.status())
+	}
+	mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
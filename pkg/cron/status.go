@@ -0,0 +1,182 @@
+package cron
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/astaxie/beego/toolbox"
+	"github.com/go-redis/redis"
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+//每个任务保留的执行记录数
+const historyLimit = 100
+
+//任务状态, 连接 redis 时为所有节点中最近一次执行的结果
+type Status struct {
+	Name        string        `json:"name"`
+	Spec        string        `json:"spec"`
+	Description string        `json:"description,omitempty"`
+	Running     bool          `json:"running"`
+	LastRun     time.Time     `json:"last_run"`
+	Duration    time.Duration `json:"duration"`
+	Error       string        `json:"error,omitempty"`
+	Next        time.Time     `json:"next"`
+}
+
+//一次执行记录
+type Run struct {
+	Start      time.Time `json:"start"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+//redis 中的键: cron:status:<name> 最近一次执行, cron:history:<name> 执行记录
+func statusKey(name string) string {
+	return "cron:status:" + name
+}
+
+func historyKey(name string) string {
+	return "cron:history:" + name
+}
+
+//记录开始执行, 返回的函数在执行结束时调用
+//running 为所有节点执行中的次数, AllowOverlap 时同时执行的每次分别计数, 都结束后才为0
+//执行期间状态的过期时间为 lockTTL 并定时续期, 进程退出后 running 不会一直保留
+func (e *entry) setRunning(start time.Time) (stop func()) {
+	e.mu.Lock()
+	e.active++
+	e.last.Running = true
+	e.last.LastRun = start
+	e.mu.Unlock()
+
+	if db.Redis == nil {
+		return func() {}
+	}
+	key := statusKey(e.job.Name)
+	db.Redis.Pipelined(func(pipe db.RedisPipeliner) error {
+		pipe.HIncrBy(key, "running", 1)
+		pipe.HSet(key, "last_run", start.UnixNano()/int64(time.Millisecond))
+		pipe.PExpire(key, lockTTL)
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if db.Redis != nil {
+					db.Redis.PExpire(key, lockTTL)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (e *entry) finish(start time.Time, d time.Duration, err error) {
+	run := Run{Start: start, DurationMs: int64(d / time.Millisecond)}
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	e.mu.Lock()
+	if e.active > 0 {
+		e.active--
+	}
+	e.last.Running = e.active > 0
+	e.last.Duration = d
+	e.last.Error = run.Error
+	e.mu.Unlock()
+
+	if db.Redis == nil {
+		return
+	}
+	key := statusKey(e.job.Name)
+	data, _ := json.Marshal(run)
+	var running *redis.IntCmd
+	db.Redis.Pipelined(func(pipe db.RedisPipeliner) error {
+		running = pipe.HIncrBy(key, "running", -1)
+		pipe.HMSet(key, map[string]interface{}{
+			"duration_ms": run.DurationMs,
+			"error":       run.Error,
+		})
+		pipe.LPush(historyKey(e.job.Name), string(data))
+		pipe.LTrim(historyKey(e.job.Name), 0, historyLimit-1)
+		return nil
+	})
+	//最后一次执行结束后不再过期; 状态过期后重新计数可能为负数, 按0记录
+	if running.Err() == nil && running.Val() <= 0 {
+		db.Redis.Pipelined(func(pipe db.RedisPipeliner) error {
+			pipe.HSet(key, "running", 0)
+			pipe.Persist(key)
+			return nil
+		})
+	}
+}
+
+func (e *entry) status() Status {
+	e.mu.Lock()
+	st := e.last
+	e.mu.Unlock()
+
+	st.Spec = e.job.Spec
+	st.Description = e.job.Description
+	if sched := toolbox.NewTask("", e.job.Spec, nil).Spec; sched != nil {
+		st.Next = sched.Next(time.Now())
+	}
+	if db.Redis == nil {
+		return st
+	}
+
+	fields, err := db.Redis.HGetAll(statusKey(e.job.Name)).Result()
+	if err != nil || len(fields) == 0 {
+		return st
+	}
+	n, _ := strconv.Atoi(fields["running"])
+	st.Running = n > 0
+	if ms, err := strconv.ParseInt(fields["last_run"], 10, 64); err == nil {
+		st.LastRun = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	if ms, err := strconv.ParseInt(fields["duration_ms"], 10, 64); err == nil {
+		st.Duration = time.Duration(ms) * time.Millisecond
+	}
+	st.Error = fields["error"]
+	return st
+}
+
+//任务最近的执行记录, 需要 redis, 未连接时只返回本进程最近一次的结果
+func History(name string, limit int64) ([]Run, error) {
+	mu.RLock()
+	e, ok := entries[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if db.Redis == nil {
+		st := e.status()
+		if st.LastRun.IsZero() {
+			return nil, nil
+		}
+		return []Run{{Start: st.LastRun, DurationMs: int64(st.Duration / time.Millisecond), Error: st.Error}}, nil
+	}
+
+	list, err := db.Redis.LRange(historyKey(name), 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]Run, 0, len(list))
+	for _, data := range list {
+		var run Run
+		if json.Unmarshal([]byte(data), &run) == nil {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
@@ -0,0 +1,121 @@
+package cron
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+func TestRunningStatusExpires(t *testing.T) {
+	mem := db.NewMemoryRedis()
+	db.Redis = mem
+	defer func() { db.Redis = nil }()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := Register(Job{Name: "status-ttl", Spec: "0 0 * * * *", Run: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- Trigger(context.Background(), "status-ttl") }()
+	<-started
+
+	key := statusKey("status-ttl")
+	if ttl := mem.PTTL(key).Val(); ttl <= 0 || ttl > lockTTL {
+		t.Errorf("running status ttl = %s, want (0, %s]", ttl, lockTTL)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Trigger error: %v", err)
+	}
+	if ttl := mem.PTTL(key).Val(); ttl > 0 {
+		t.Errorf("finished status ttl = %s, want no expiry", ttl)
+	}
+	if v := mem.HGet(key, "running").Val(); v != "0" {
+		t.Errorf("finished status running = %q, want 0", v)
+	}
+
+	//执行中的进程退出: 不再续期, lockTTL 后其它节点看到的状态不再是执行中
+	e := entries["status-ttl"]
+	stop := e.setRunning(time.Now())
+	defer stop()
+	e.mu.Lock()
+	e.last.Running = false
+	e.mu.Unlock()
+	if !e.status().Running {
+		t.Fatalf("status not running after setRunning")
+	}
+	mem.FastForward(lockTTL + time.Second)
+	if e.status().Running {
+		t.Errorf("status still running after the process exited")
+	}
+}
+
+func TestOverlapRunningCount(t *testing.T) {
+	mem := db.NewMemoryRedis()
+	db.Redis = mem
+	defer func() { db.Redis = nil }()
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{}, 2)
+	if err := Register(Job{Name: "overlap", Spec: "0 0 * * * *", AllowOverlap: true, Run: func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- Trigger(context.Background(), "overlap") }()
+		<-started
+	}
+	key := statusKey("overlap")
+	if v := mem.HGet(key, "running").Val(); v != "2" {
+		t.Fatalf("running = %q, want 2", v)
+	}
+
+	//第一次结束后另一次仍在执行, 状态仍为执行中并保留过期时间
+	release <- struct{}{}
+	<-done
+	if v := mem.HGet(key, "running").Val(); v != "1" {
+		t.Errorf("running after one finished = %q, want 1", v)
+	}
+	if ttl := mem.PTTL(key).Val(); ttl <= 0 {
+		t.Errorf("status ttl after one finished = %s, want expiry", ttl)
+	}
+	if st := entries["overlap"].status(); !st.Running {
+		t.Errorf("status not running while a run is still going")
+	}
+
+	release <- struct{}{}
+	<-done
+	if v := mem.HGet(key, "running").Val(); v != "0" {
+		t.Errorf("running after all finished = %q, want 0", v)
+	}
+	if ttl := mem.PTTL(key).Val(); ttl > 0 {
+		t.Errorf("finished status ttl = %s, want no expiry", ttl)
+	}
+	if st := entries["overlap"].status(); st.Running {
+		t.Errorf("status running after all runs finished")
+	}
+}
+
+func TestStartSingleWithoutRedis(t *testing.T) {
+	if err := Register(Job{Name: "single-no-redis", Spec: "0 0 * * * *", Single: true, Run: func(ctx context.Context) error {
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Start(); err == nil {
+		t.Errorf("Start with a single job and no redis want error")
+	}
+}
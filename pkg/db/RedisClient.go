@@ -1,69 +1,191 @@
 package db
 
 import (
+	"strings"
+	"sync"
 	"time"
 
-	"encoding/json"
-
 	"github.com/astaxie/beego"
 	"github.com/go-redis/redis"
 )
 
+//带前缀的客户端, 底层可以是单机、哨兵(*redis.Client)或集群(*redis.ClusterClient)
 type redisClient struct {
-	baseRedisClient *redis.Client
-	prefix          string
+	redisCmdable
+	baseRedisClient redis.UniversalClient
 }
 
 // NewClient returns a client to the Redis Server specified by Options.
 func NewClient(redisOption *redis.Options) *redisClient {
-	client := redis.NewClient(redisOption)
+	return newRedisClient(redis.NewClient(redisOption), beego.AppConfig.String("redis::prefix"))
+}
+
+func newRedisClient(client redis.UniversalClient, prefix string) *redisClient {
 	c := redisClient{
+		redisCmdable: redisCmdable{
+			cmd:    client,
+			prefix: prefix,
+		},
 		baseRedisClient: client,
-		prefix:          beego.AppConfig.String("redis::prefix"),
 	}
 	return &c
 }
 
-// Redis `GET key` command. It returns redis.Nil error when key does not exist.
-func (c *redisClient) Get(key string) *redis.StringCmd {
-	key = c.BuildKey(key)
-	return c.baseRedisClient.Get(key)
+//集群模式下在每个主节点上执行 fn, 单机和哨兵模式直接执行
+func (c *redisClient) eachMaster(fn func(client redis.Cmdable) error) error {
+	if cluster, ok := c.baseRedisClient.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(func(client *redis.Client) error {
+			return fn(client)
+		})
+	}
+	return fn(c.baseRedisClient)
+}
+
+func (c *redisClient) Ping() error {
+	return c.baseRedisClient.Ping().Err()
 }
 
-func (c *redisClient) GetSet(key string, value interface{}) *redis.StringCmd {
-	key = c.BuildKey(key)
-	return c.baseRedisClient.GetSet(key, value)
+//去掉主键前缀, 用于 Keys、Scan 以外返回的键名, 如订阅消息的 Channel
+func (c *redisClient) StripKey(key string) string {
+	return strings.TrimPrefix(key, c.prefix)
 }
 
-// Redis `SET key value [expiration]` command.
-//
-// Use expiration for `SETEX`-like behavior.
-// Zero expiration means the key has no expiration time.
-func (c *redisClient) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
-	key = c.BuildKey(key)
+func (c *redisClient) stripKeys(keys []string) []string {
+	for i := range keys {
+		keys[i] = c.StripKey(keys[i])
+	}
+	return keys
+}
 
-	if _, isstring := value.(string); !isstring {
-		if listStr, err := json.Marshal(value); err == nil {
-			return c.baseRedisClient.Set(key, listStr, expiration)
+//匹配 pattern 的键, 返回的键名不带前缀; 会阻塞 redis, 数据多时用 Scan
+//集群模式下合并所有主节点的结果
+func (c *redisClient) Keys(pattern string) ([]string, error) {
+	var mu sync.Mutex
+	var all []string
+	err := c.eachMaster(func(client redis.Cmdable) error {
+		keys, err := client.Keys(c.BuildKey(pattern)).Result()
+		mu.Lock()
+		all = append(all, keys...)
+		mu.Unlock()
+		return err
+	})
+	return c.stripKeys(all), err
+}
+
+//Redis `SCAN cursor MATCH match COUNT count`, 只扫描带前缀的键, 返回的键名不带前缀
+//cursor 为0时扫描结束; 集群模式下只扫描一个节点, 扫描全部用 ScanKeys
+func (c *redisClient) Scan(cursor uint64, match string, count int64) ([]string, uint64, error) {
+	if match == "" {
+		match = "*"
+	}
+	keys, cursor, err := c.baseRedisClient.Scan(cursor, c.BuildKey(match), count).Result()
+	return c.stripKeys(keys), cursor, err
+}
+
+//扫描全部匹配 match 的键, 返回的键名不带前缀; 集群模式下扫描所有主节点
+func (c *redisClient) ScanKeys(match string, count int64) ([]string, error) {
+	if match == "" {
+		match = "*"
+	}
+	var mu sync.Mutex
+	var all []string
+	err := c.eachMaster(func(client redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(cursor, c.BuildKey(match), count).Result()
+			mu.Lock()
+			all = append(all, c.stripKeys(keys)...)
+			mu.Unlock()
+			if err != nil || next == 0 {
+				return err
+			}
+			cursor = next
 		}
+	})
+	return all, err
+}
+
+//Redis `BLPOP`, 返回 [键名(不带前缀), 值]
+func (c *redisClient) BLPop(timeout time.Duration, keys ...string) ([]string, error) {
+	res, err := c.baseRedisClient.BLPop(timeout, c.buildKeys(keys)...).Result()
+	if len(res) > 0 {
+		res[0] = c.StripKey(res[0])
+	}
+	return res, err
+}
+
+//Redis `BRPOP`, 返回 [键名(不带前缀), 值]
+func (c *redisClient) BRPop(timeout time.Duration, keys ...string) ([]string, error) {
+	res, err := c.baseRedisClient.BRPop(timeout, c.buildKeys(keys)...).Result()
+	if len(res) > 0 {
+		res[0] = c.StripKey(res[0])
 	}
+	return res, err
+}
+
+//订阅频道, 频道名加前缀; 收到的 Message.Channel 带前缀, 可用 StripKey 去掉
+func (c *redisClient) Subscribe(channels ...string) *redis.PubSub {
+	return c.baseRedisClient.Subscribe(c.buildKeys(channels)...)
+}
 
-	return c.baseRedisClient.Set(key, value, expiration)
+//按模式订阅频道, 模式加前缀
+func (c *redisClient) PSubscribe(patterns ...string) *redis.PubSub {
+	return c.baseRedisClient.PSubscribe(c.buildKeys(patterns)...)
 }
 
-//删除缓存
-func (c *redisClient) Del(key ...string) {
-	for i, _ := range key {
-		key[i] = c.BuildKey(key[i])
+//管道, 命令的键同样加前缀, 调用 Exec 后一次发送
+func (c *redisClient) Pipeline() RedisPipeliner {
+	return newRedisPipeline(c.baseRedisClient.Pipeline(), c.prefix)
+}
+
+//MULTI/EXEC 包裹的管道
+func (c *redisClient) TxPipeline() RedisPipeliner {
+	return newRedisPipeline(c.baseRedisClient.TxPipeline(), c.prefix)
+}
+
+//在管道中执行 fn 中的命令
+//	cmds, err := db.Redis.Pipelined(func(pipe db.RedisPipeliner) error {
+//		pipe.Incr("counter")
+//		pipe.Expire("counter", time.Hour)
+//		return nil
+//	})
+func (c *redisClient) Pipelined(fn func(pipe RedisPipeliner) error) ([]redis.Cmder, error) {
+	return c.baseRedisClient.Pipelined(func(pipe redis.Pipeliner) error {
+		return fn(newRedisPipeline(pipe, c.prefix))
+	})
+}
+
+//在 MULTI/EXEC 事务中执行 fn 中的命令
+func (c *redisClient) TxPipelined(fn func(pipe RedisPipeliner) error) ([]redis.Cmder, error) {
+	return c.baseRedisClient.TxPipelined(func(pipe redis.Pipeliner) error {
+		return fn(newRedisPipeline(pipe, c.prefix))
+	})
+}
+
+//关闭连接
+func (c *redisClient) Close() error {
+	return c.baseRedisClient.Close()
+}
+
+//管道, 命令与 redisClient 相同, 键加前缀
+type RedisPipeline struct {
+	redisCmdable
+	pipe redis.Pipeliner
+}
+
+func newRedisPipeline(pipe redis.Pipeliner, prefix string) *RedisPipeline {
+	return &RedisPipeline{
+		redisCmdable: redisCmdable{cmd: pipe, prefix: prefix},
+		pipe:         pipe,
 	}
-	c.baseRedisClient.Del(key...)
 }
 
-//主键加前缀
-func (c *redisClient) BuildKey(key string) string {
-	return c.prefix + key
+//发送管道中的命令
+func (p *RedisPipeline) Exec() ([]redis.Cmder, error) {
+	return p.pipe.Exec()
 }
 
-func (c *redisClient) Ping() error {
-	return c.baseRedisClient.Ping().Err()
+//丢弃管道中未发送的命令
+func (p *RedisPipeline) Discard() error {
+	return p.pipe.Discard()
 }
@@ -0,0 +1,483 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//redis 命令, 键名统一加前缀, redisClient 和 RedisPipeline 共用
+type redisCmdable struct {
+	cmd    redis.Cmdable
+	prefix string
+}
+
+//主键加前缀
+func (c *redisCmdable) BuildKey(key string) string {
+	return c.prefix + key
+}
+
+//多个键加前缀, 不修改传入的切片
+func (c *redisCmdable) buildKeys(keys []string) []string {
+	built := make([]string, len(keys))
+	for i, key := range keys {
+		built[i] = c.BuildKey(key)
+	}
+	return built
+}
+
+//非字符串的值转为json
+func jsonValue(value interface{}) interface{} {
+	if _, isstring := value.(string); isstring {
+		return value
+	}
+	if b, err := json.Marshal(value); err == nil {
+		return b
+	}
+	return value
+}
+
+//-------- 键 --------
+
+//删除缓存
+func (c *redisCmdable) Del(keys ...string) *redis.IntCmd {
+	return c.cmd.Del(c.buildKeys(keys)...)
+}
+
+//异步删除
+func (c *redisCmdable) Unlink(keys ...string) *redis.IntCmd {
+	return c.cmd.Unlink(c.buildKeys(keys)...)
+}
+
+//存在的键的个数
+func (c *redisCmdable) Exists(keys ...string) *redis.IntCmd {
+	return c.cmd.Exists(c.buildKeys(keys)...)
+}
+
+func (c *redisCmdable) Expire(key string, expiration time.Duration) *redis.BoolCmd {
+	return c.cmd.Expire(c.BuildKey(key), expiration)
+}
+
+func (c *redisCmdable) ExpireAt(key string, tm time.Time) *redis.BoolCmd {
+	return c.cmd.ExpireAt(c.BuildKey(key), tm)
+}
+
+func (c *redisCmdable) PExpire(key string, expiration time.Duration) *redis.BoolCmd {
+	return c.cmd.PExpire(c.BuildKey(key), expiration)
+}
+
+//去掉过期时间
+func (c *redisCmdable) Persist(key string) *redis.BoolCmd {
+	return c.cmd.Persist(c.BuildKey(key))
+}
+
+//剩余过期时间, 不存在返回 -2s, 没有过期时间返回 -1s
+func (c *redisCmdable) TTL(key string) *redis.DurationCmd {
+	return c.cmd.TTL(c.BuildKey(key))
+}
+
+func (c *redisCmdable) PTTL(key string) *redis.DurationCmd {
+	return c.cmd.PTTL(c.BuildKey(key))
+}
+
+func (c *redisCmdable) Type(key string) *redis.StatusCmd {
+	return c.cmd.Type(c.BuildKey(key))
+}
+
+func (c *redisCmdable) Rename(key, newkey string) *redis.StatusCmd {
+	return c.cmd.Rename(c.BuildKey(key), c.BuildKey(newkey))
+}
+
+func (c *redisCmdable) RenameNX(key, newkey string) *redis.BoolCmd {
+	return c.cmd.RenameNX(c.BuildKey(key), c.BuildKey(newkey))
+}
+
+//-------- 字符串 --------
+
+// Redis `GET key` command. It returns redis.Nil error when key does not exist.
+func (c *redisCmdable) Get(key string) *redis.StringCmd {
+	return c.cmd.Get(c.BuildKey(key))
+}
+
+func (c *redisCmdable) GetSet(key string, value interface{}) *redis.StringCmd {
+	return c.cmd.GetSet(c.BuildKey(key), value)
+}
+
+// Redis `SET key value [expiration]` command.
+//
+// Use expiration for `SETEX`-like behavior.
+// Zero expiration means the key has no expiration time.
+//非字符串的值转为json
+func (c *redisCmdable) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	return c.cmd.Set(c.BuildKey(key), jsonValue(value), expiration)
+}
+
+//键不存在时设置, 非字符串的值转为json
+func (c *redisCmdable) SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return c.cmd.SetNX(c.BuildKey(key), jsonValue(value), expiration)
+}
+
+//键存在时设置, 非字符串的值转为json
+func (c *redisCmdable) SetXX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return c.cmd.SetXX(c.BuildKey(key), jsonValue(value), expiration)
+}
+
+func (c *redisCmdable) MGet(keys ...string) *redis.SliceCmd {
+	return c.cmd.MGet(c.buildKeys(keys)...)
+}
+
+//pairs 为 键, 值, 键, 值...
+func (c *redisCmdable) MSet(pairs ...interface{}) *redis.StatusCmd {
+	return c.cmd.MSet(c.buildPairs(pairs)...)
+}
+
+func (c *redisCmdable) MSetNX(pairs ...interface{}) *redis.BoolCmd {
+	return c.cmd.MSetNX(c.buildPairs(pairs)...)
+}
+
+func (c *redisCmdable) buildPairs(pairs []interface{}) []interface{} {
+	built := make([]interface{}, len(pairs))
+	for i, v := range pairs {
+		if key, ok := v.(string); ok && i%2 == 0 {
+			v = c.BuildKey(key)
+		}
+		built[i] = v
+	}
+	return built
+}
+
+func (c *redisCmdable) Incr(key string) *redis.IntCmd {
+	return c.cmd.Incr(c.BuildKey(key))
+}
+
+func (c *redisCmdable) IncrBy(key string, value int64) *redis.IntCmd {
+	return c.cmd.IncrBy(c.BuildKey(key), value)
+}
+
+func (c *redisCmdable) IncrByFloat(key string, value float64) *redis.FloatCmd {
+	return c.cmd.IncrByFloat(c.BuildKey(key), value)
+}
+
+func (c *redisCmdable) Decr(key string) *redis.IntCmd {
+	return c.cmd.Decr(c.BuildKey(key))
+}
+
+func (c *redisCmdable) DecrBy(key string, decrement int64) *redis.IntCmd {
+	return c.cmd.DecrBy(c.BuildKey(key), decrement)
+}
+
+func (c *redisCmdable) Append(key, value string) *redis.IntCmd {
+	return c.cmd.Append(c.BuildKey(key), value)
+}
+
+func (c *redisCmdable) StrLen(key string) *redis.IntCmd {
+	return c.cmd.StrLen(c.BuildKey(key))
+}
+
+//-------- 哈希 --------
+
+func (c *redisCmdable) HDel(key string, fields ...string) *redis.IntCmd {
+	return c.cmd.HDel(c.BuildKey(key), fields...)
+}
+
+func (c *redisCmdable) HExists(key, field string) *redis.BoolCmd {
+	return c.cmd.HExists(c.BuildKey(key), field)
+}
+
+func (c *redisCmdable) HGet(key, field string) *redis.StringCmd {
+	return c.cmd.HGet(c.BuildKey(key), field)
+}
+
+func (c *redisCmdable) HGetAll(key string) *redis.StringStringMapCmd {
+	return c.cmd.HGetAll(c.BuildKey(key))
+}
+
+func (c *redisCmdable) HIncrBy(key, field string, incr int64) *redis.IntCmd {
+	return c.cmd.HIncrBy(c.BuildKey(key), field, incr)
+}
+
+func (c *redisCmdable) HIncrByFloat(key, field string, incr float64) *redis.FloatCmd {
+	return c.cmd.HIncrByFloat(c.BuildKey(key), field, incr)
+}
+
+func (c *redisCmdable) HKeys(key string) *redis.StringSliceCmd {
+	return c.cmd.HKeys(c.BuildKey(key))
+}
+
+func (c *redisCmdable) HLen(key string) *redis.IntCmd {
+	return c.cmd.HLen(c.BuildKey(key))
+}
+
+func (c *redisCmdable) HMGet(key string, fields ...string) *redis.SliceCmd {
+	return c.cmd.HMGet(c.BuildKey(key), fields...)
+}
+
+func (c *redisCmdable) HMSet(key string, fields map[string]interface{}) *redis.StatusCmd {
+	return c.cmd.HMSet(c.BuildKey(key), fields)
+}
+
+func (c *redisCmdable) HSet(key, field string, value interface{}) *redis.BoolCmd {
+	return c.cmd.HSet(c.BuildKey(key), field, value)
+}
+
+func (c *redisCmdable) HSetNX(key, field string, value interface{}) *redis.BoolCmd {
+	return c.cmd.HSetNX(c.BuildKey(key), field, value)
+}
+
+func (c *redisCmdable) HVals(key string) *redis.StringSliceCmd {
+	return c.cmd.HVals(c.BuildKey(key))
+}
+
+//扫描哈希的字段
+func (c *redisCmdable) HScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return c.cmd.HScan(c.BuildKey(key), cursor, match, count)
+}
+
+//-------- 列表 --------
+
+func (c *redisCmdable) LIndex(key string, index int64) *redis.StringCmd {
+	return c.cmd.LIndex(c.BuildKey(key), index)
+}
+
+func (c *redisCmdable) LInsertBefore(key string, pivot, value interface{}) *redis.IntCmd {
+	return c.cmd.LInsertBefore(c.BuildKey(key), pivot, value)
+}
+
+func (c *redisCmdable) LInsertAfter(key string, pivot, value interface{}) *redis.IntCmd {
+	return c.cmd.LInsertAfter(c.BuildKey(key), pivot, value)
+}
+
+func (c *redisCmdable) LLen(key string) *redis.IntCmd {
+	return c.cmd.LLen(c.BuildKey(key))
+}
+
+func (c *redisCmdable) LPop(key string) *redis.StringCmd {
+	return c.cmd.LPop(c.BuildKey(key))
+}
+
+func (c *redisCmdable) LPush(key string, values ...interface{}) *redis.IntCmd {
+	return c.cmd.LPush(c.BuildKey(key), values...)
+}
+
+func (c *redisCmdable) LPushX(key string, value interface{}) *redis.IntCmd {
+	return c.cmd.LPushX(c.BuildKey(key), value)
+}
+
+func (c *redisCmdable) LRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return c.cmd.LRange(c.BuildKey(key), start, stop)
+}
+
+func (c *redisCmdable) LRem(key string, count int64, value interface{}) *redis.IntCmd {
+	return c.cmd.LRem(c.BuildKey(key), count, value)
+}
+
+func (c *redisCmdable) LSet(key string, index int64, value interface{}) *redis.StatusCmd {
+	return c.cmd.LSet(c.BuildKey(key), index, value)
+}
+
+func (c *redisCmdable) LTrim(key string, start, stop int64) *redis.StatusCmd {
+	return c.cmd.LTrim(c.BuildKey(key), start, stop)
+}
+
+func (c *redisCmdable) RPop(key string) *redis.StringCmd {
+	return c.cmd.RPop(c.BuildKey(key))
+}
+
+func (c *redisCmdable) RPopLPush(source, destination string) *redis.StringCmd {
+	return c.cmd.RPopLPush(c.BuildKey(source), c.BuildKey(destination))
+}
+
+func (c *redisCmdable) BRPopLPush(source, destination string, timeout time.Duration) *redis.StringCmd {
+	return c.cmd.BRPopLPush(c.BuildKey(source), c.BuildKey(destination), timeout)
+}
+
+func (c *redisCmdable) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return c.cmd.RPush(c.BuildKey(key), values...)
+}
+
+func (c *redisCmdable) RPushX(key string, value interface{}) *redis.IntCmd {
+	return c.cmd.RPushX(c.BuildKey(key), value)
+}
+
+//-------- 集合 --------
+
+func (c *redisCmdable) SAdd(key string, members ...interface{}) *redis.IntCmd {
+	return c.cmd.SAdd(c.BuildKey(key), members...)
+}
+
+func (c *redisCmdable) SCard(key string) *redis.IntCmd {
+	return c.cmd.SCard(c.BuildKey(key))
+}
+
+func (c *redisCmdable) SDiff(keys ...string) *redis.StringSliceCmd {
+	return c.cmd.SDiff(c.buildKeys(keys)...)
+}
+
+func (c *redisCmdable) SDiffStore(destination string, keys ...string) *redis.IntCmd {
+	return c.cmd.SDiffStore(c.BuildKey(destination), c.buildKeys(keys)...)
+}
+
+func (c *redisCmdable) SInter(keys ...string) *redis.StringSliceCmd {
+	return c.cmd.SInter(c.buildKeys(keys)...)
+}
+
+func (c *redisCmdable) SInterStore(destination string, keys ...string) *redis.IntCmd {
+	return c.cmd.SInterStore(c.BuildKey(destination), c.buildKeys(keys)...)
+}
+
+func (c *redisCmdable) SIsMember(key string, member interface{}) *redis.BoolCmd {
+	return c.cmd.SIsMember(c.BuildKey(key), member)
+}
+
+func (c *redisCmdable) SMembers(key string) *redis.StringSliceCmd {
+	return c.cmd.SMembers(c.BuildKey(key))
+}
+
+func (c *redisCmdable) SMove(source, destination string, member interface{}) *redis.BoolCmd {
+	return c.cmd.SMove(c.BuildKey(source), c.BuildKey(destination), member)
+}
+
+func (c *redisCmdable) SPop(key string) *redis.StringCmd {
+	return c.cmd.SPop(c.BuildKey(key))
+}
+
+func (c *redisCmdable) SPopN(key string, count int64) *redis.StringSliceCmd {
+	return c.cmd.SPopN(c.BuildKey(key), count)
+}
+
+func (c *redisCmdable) SRandMember(key string) *redis.StringCmd {
+	return c.cmd.SRandMember(c.BuildKey(key))
+}
+
+func (c *redisCmdable) SRandMemberN(key string, count int64) *redis.StringSliceCmd {
+	return c.cmd.SRandMemberN(c.BuildKey(key), count)
+}
+
+func (c *redisCmdable) SRem(key string, members ...interface{}) *redis.IntCmd {
+	return c.cmd.SRem(c.BuildKey(key), members...)
+}
+
+func (c *redisCmdable) SUnion(keys ...string) *redis.StringSliceCmd {
+	return c.cmd.SUnion(c.buildKeys(keys)...)
+}
+
+func (c *redisCmdable) SUnionStore(destination string, keys ...string) *redis.IntCmd {
+	return c.cmd.SUnionStore(c.BuildKey(destination), c.buildKeys(keys)...)
+}
+
+//扫描集合的成员
+func (c *redisCmdable) SScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return c.cmd.SScan(c.BuildKey(key), cursor, match, count)
+}
+
+//-------- 有序集合 --------
+
+func (c *redisCmdable) ZAdd(key string, members ...redis.Z) *redis.IntCmd {
+	return c.cmd.ZAdd(c.BuildKey(key), members...)
+}
+
+func (c *redisCmdable) ZAddNX(key string, members ...redis.Z) *redis.IntCmd {
+	return c.cmd.ZAddNX(c.BuildKey(key), members...)
+}
+
+func (c *redisCmdable) ZAddXX(key string, members ...redis.Z) *redis.IntCmd {
+	return c.cmd.ZAddXX(c.BuildKey(key), members...)
+}
+
+func (c *redisCmdable) ZIncrBy(key string, increment float64, member string) *redis.FloatCmd {
+	return c.cmd.ZIncrBy(c.BuildKey(key), increment, member)
+}
+
+func (c *redisCmdable) ZCard(key string) *redis.IntCmd {
+	return c.cmd.ZCard(c.BuildKey(key))
+}
+
+func (c *redisCmdable) ZCount(key, min, max string) *redis.IntCmd {
+	return c.cmd.ZCount(c.BuildKey(key), min, max)
+}
+
+func (c *redisCmdable) ZRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return c.cmd.ZRange(c.BuildKey(key), start, stop)
+}
+
+func (c *redisCmdable) ZRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd {
+	return c.cmd.ZRangeWithScores(c.BuildKey(key), start, stop)
+}
+
+func (c *redisCmdable) ZRangeByScore(key string, opt redis.ZRangeBy) *redis.StringSliceCmd {
+	return c.cmd.ZRangeByScore(c.BuildKey(key), opt)
+}
+
+func (c *redisCmdable) ZRangeByScoreWithScores(key string, opt redis.ZRangeBy) *redis.ZSliceCmd {
+	return c.cmd.ZRangeByScoreWithScores(c.BuildKey(key), opt)
+}
+
+func (c *redisCmdable) ZRevRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return c.cmd.ZRevRange(c.BuildKey(key), start, stop)
+}
+
+func (c *redisCmdable) ZRevRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd {
+	return c.cmd.ZRevRangeWithScores(c.BuildKey(key), start, stop)
+}
+
+func (c *redisCmdable) ZRevRangeByScore(key string, opt redis.ZRangeBy) *redis.StringSliceCmd {
+	return c.cmd.ZRevRangeByScore(c.BuildKey(key), opt)
+}
+
+func (c *redisCmdable) ZRevRangeByScoreWithScores(key string, opt redis.ZRangeBy) *redis.ZSliceCmd {
+	return c.cmd.ZRevRangeByScoreWithScores(c.BuildKey(key), opt)
+}
+
+func (c *redisCmdable) ZRank(key, member string) *redis.IntCmd {
+	return c.cmd.ZRank(c.BuildKey(key), member)
+}
+
+func (c *redisCmdable) ZRevRank(key, member string) *redis.IntCmd {
+	return c.cmd.ZRevRank(c.BuildKey(key), member)
+}
+
+func (c *redisCmdable) ZRem(key string, members ...interface{}) *redis.IntCmd {
+	return c.cmd.ZRem(c.BuildKey(key), members...)
+}
+
+func (c *redisCmdable) ZRemRangeByRank(key string, start, stop int64) *redis.IntCmd {
+	return c.cmd.ZRemRangeByRank(c.BuildKey(key), start, stop)
+}
+
+func (c *redisCmdable) ZRemRangeByScore(key, min, max string) *redis.IntCmd {
+	return c.cmd.ZRemRangeByScore(c.BuildKey(key), min, max)
+}
+
+func (c *redisCmdable) ZScore(key, member string) *redis.FloatCmd {
+	return c.cmd.ZScore(c.BuildKey(key), member)
+}
+
+func (c *redisCmdable) ZInterStore(destination string, store redis.ZStore, keys ...string) *redis.IntCmd {
+	return c.cmd.ZInterStore(c.BuildKey(destination), store, c.buildKeys(keys)...)
+}
+
+func (c *redisCmdable) ZUnionStore(destination string, store redis.ZStore, keys ...string) *redis.IntCmd {
+	return c.cmd.ZUnionStore(c.BuildKey(destination), store, c.buildKeys(keys)...)
+}
+
+//扫描有序集合的成员
+func (c *redisCmdable) ZScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return c.cmd.ZScan(c.BuildKey(key), cursor, match, count)
+}
+
+//-------- 脚本、发布 --------
+
+//执行 lua 脚本, keys 加前缀
+func (c *redisCmdable) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	return c.cmd.Eval(script, c.buildKeys(keys), args...)
+}
+
+func (c *redisCmdable) EvalSha(sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	return c.cmd.EvalSha(sha1, c.buildKeys(keys), args...)
+}
+
+//发布消息, 频道名加前缀
+func (c *redisCmdable) Publish(channel string, message interface{}) *redis.IntCmd {
+	return c.cmd.Publish(c.BuildKey(channel), message)
+}
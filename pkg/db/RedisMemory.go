@@ -0,0 +1,2006 @@
+package db
+
+import (
+	"crypto/sha1"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	//内存实现不支持的命令, 如 lua 脚本和订阅
+	ErrMemoryRedisUnsupported = errors.New("redis: command not supported by memory redis")
+
+	errWrongType  = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInteger = errors.New("ERR value is not an integer or out of range")
+	errNotFloat   = errors.New("ERR value is not a valid float")
+	errNoSuchKey  = errors.New("ERR no such key")
+	errOutOfRange = errors.New("ERR index out of range")
+)
+
+//值的类型, 与 redis TYPE 命令的返回相同
+const (
+	memoryString = "string"
+	memoryHash   = "hash"
+	memoryList   = "list"
+	memorySet    = "set"
+	memoryZSet   = "zset"
+)
+
+//阻塞命令的轮询间隔
+const memoryPollInterval = 10 * time.Millisecond
+
+type memoryValue struct {
+	kind     string
+	str      string
+	hash     map[string]string
+	list     []string
+	set      map[string]struct{}
+	zset     map[string]float64
+	expireAt time.Time
+}
+
+func (v *memoryValue) clone() *memoryValue {
+	c := *v
+	if v.hash != nil {
+		c.hash = make(map[string]string, len(v.hash))
+		for k, s := range v.hash {
+			c.hash[k] = s
+		}
+	}
+	if v.list != nil {
+		c.list = append([]string(nil), v.list...)
+	}
+	if v.set != nil {
+		c.set = make(map[string]struct{}, len(v.set))
+		for k := range v.set {
+			c.set[k] = struct{}{}
+		}
+	}
+	if v.zset != nil {
+		c.zset = make(map[string]float64, len(v.zset))
+		for k, f := range v.zset {
+			c.zset[k] = f
+		}
+	}
+	return &c
+}
+
+func (v *memoryValue) len() int {
+	switch v.kind {
+	case memoryHash:
+		return len(v.hash)
+	case memoryList:
+		return len(v.list)
+	case memorySet:
+		return len(v.set)
+	case memoryZSet:
+		return len(v.zset)
+	}
+	return len(v.str)
+}
+
+//内存中的 redis, 用于测试, 不需要 redis 服务
+//	db.Redis = db.NewMemoryRedis()
+//支持过期时间和键、字符串、哈希、列表、集合、有序集合命令, 键名不加前缀
+//lua 脚本需先用 RegisterMemoryScript 注册 Go 实现, 未注册的脚本和订阅返回 ErrMemoryRedisUnsupported
+//管道中的命令立即执行, 见 memoryPipeline
+type MemoryRedis struct {
+	mu     sync.Mutex
+	data   map[string]*memoryValue
+	offset time.Duration
+}
+
+var _ RedisClient = (*MemoryRedis)(nil)
+var _ RedisClient = (*redisClient)(nil)
+
+func NewMemoryRedis() *MemoryRedis {
+	return &MemoryRedis{data: map[string]*memoryValue{}}
+}
+
+//时间前进 d, 用于测试过期
+func (m *MemoryRedis) FastForward(d time.Duration) {
+	m.mu.Lock()
+	m.offset += d
+	m.mu.Unlock()
+}
+
+//清空数据
+func (m *MemoryRedis) FlushAll() {
+	m.mu.Lock()
+	m.data = map[string]*memoryValue{}
+	m.mu.Unlock()
+}
+
+func (m *MemoryRedis) now() time.Time {
+	return time.Now().Add(m.offset)
+}
+
+//取未过期的值, 调用方持有锁
+func (m *MemoryRedis) lookup(key string) *memoryValue {
+	v, ok := m.data[key]
+	if !ok {
+		return nil
+	}
+	if !v.expireAt.IsZero() && !m.now().Before(v.expireAt) {
+		delete(m.data, key)
+		return nil
+	}
+	return v
+}
+
+func (m *MemoryRedis) lookupKind(key, kind string) (*memoryValue, error) {
+	v := m.lookup(key)
+	if v != nil && v.kind != kind {
+		return nil, errWrongType
+	}
+	return v, nil
+}
+
+//取值, 不存在时创建
+func (m *MemoryRedis) create(key, kind string) (*memoryValue, error) {
+	v, err := m.lookupKind(key, kind)
+	if err != nil || v != nil {
+		return v, err
+	}
+	v = &memoryValue{kind: kind}
+	switch kind {
+	case memoryHash:
+		v.hash = map[string]string{}
+	case memorySet:
+		v.set = map[string]struct{}{}
+	case memoryZSet:
+		v.zset = map[string]float64{}
+	}
+	m.data[key] = v
+	return v, nil
+}
+
+//哈希、列表、集合为空时删除键
+func (m *MemoryRedis) cleanup(key string, v *memoryValue) {
+	if v != nil && v.kind != memoryString && v.len() == 0 {
+		delete(m.data, key)
+	}
+}
+
+func (m *MemoryRedis) setString(key, value string, expiration time.Duration) {
+	v := &memoryValue{kind: memoryString, str: value}
+	if expiration > 0 {
+		v.expireAt = m.now().Add(expiration)
+	}
+	m.data[key] = v
+}
+
+//参数转为字符串, 与 go-redis 发送参数的方式相同
+func memoryArg(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return formatFloat(float64(v)), nil
+	case float64:
+		return formatFloat(v), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case encoding.BinaryMarshaler:
+		b, err := v.MarshalBinary()
+		return string(b), err
+	}
+	return "", fmt.Errorf("redis: can't marshal %T (implement encoding.BinaryMarshaler)", v)
+}
+
+func memoryArgs(values []interface{}) ([]string, error) {
+	list := make([]string, len(values))
+	for i, v := range values {
+		s, err := memoryArg(v)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = s
+	}
+	return list, nil
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "inf"
+	}
+	if math.IsInf(f, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+//redis 的 glob 匹配: * ? [abc] [a-z] [^a] 和 \ 转义
+func memoryMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if memoryMatch(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		case '[':
+			if s == "" {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				if s[0] != '[' {
+					return false
+				}
+				pattern, s = pattern[1:], s[1:]
+				continue
+			}
+			class := pattern[1 : end+1]
+			negate := strings.HasPrefix(class, "^")
+			if negate {
+				class = class[1:]
+			}
+			matched := false
+			for i := 0; i < len(class); i++ {
+				if class[i] == '\\' && i+1 < len(class) {
+					i++
+					matched = matched || class[i] == s[0]
+				} else if i+2 < len(class) && class[i+1] == '-' {
+					matched = matched || (class[i] <= s[0] && s[0] <= class[i+2])
+					i += 2
+				} else {
+					matched = matched || class[i] == s[0]
+				}
+			}
+			if matched == negate {
+				return false
+			}
+			pattern, s = pattern[end+2:], s[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return s == ""
+}
+
+//负数下标转为正数并限制在范围内, 范围为空时返回 false
+func memoryRange(n int, start, stop int64) (int, int, bool) {
+	if start < 0 {
+		start += int64(n)
+	}
+	if stop < 0 {
+		stop += int64(n)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= int64(n) {
+		stop = int64(n) - 1
+	}
+	if start > stop || start >= int64(n) {
+		return 0, 0, false
+	}
+	return int(start), int(stop), true
+}
+
+//-------- 客户端 --------
+
+func (m *MemoryRedis) Ping() error {
+	return nil
+}
+
+func (m *MemoryRedis) Close() error {
+	return nil
+}
+
+func (m *MemoryRedis) BuildKey(key string) string {
+	return key
+}
+
+func (m *MemoryRedis) StripKey(key string) string {
+	return key
+}
+
+func (m *MemoryRedis) Keys(pattern string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.data {
+		if m.lookup(key) != nil && memoryMatch(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+//一次返回全部匹配的键, cursor 为0
+func (m *MemoryRedis) Scan(cursor uint64, match string, count int64) ([]string, uint64, error) {
+	if match == "" {
+		match = "*"
+	}
+	keys, err := m.Keys(match)
+	return keys, 0, err
+}
+
+func (m *MemoryRedis) ScanKeys(match string, count int64) ([]string, error) {
+	keys, _, err := m.Scan(0, match, count)
+	return keys, err
+}
+
+func (m *MemoryRedis) BLPop(timeout time.Duration, keys ...string) ([]string, error) {
+	return m.blockingPop(timeout, keys, true)
+}
+
+func (m *MemoryRedis) BRPop(timeout time.Duration, keys ...string) ([]string, error) {
+	return m.blockingPop(timeout, keys, false)
+}
+
+//轮询直到有值或超时, timeout 为0时一直等待
+func (m *MemoryRedis) blockingPop(timeout time.Duration, keys []string, left bool) ([]string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		m.mu.Lock()
+		for _, key := range keys {
+			value, ok, err := m.pop(key, left)
+			if err != nil || ok {
+				m.mu.Unlock()
+				return []string{key, value}, err
+			}
+		}
+		m.mu.Unlock()
+		if timeout > 0 && time.Now().After(deadline) {
+			return nil, redis.Nil
+		}
+		time.Sleep(memoryPollInterval)
+	}
+}
+
+func (m *MemoryRedis) unsupportedClient() *redis.Client {
+	return redis.NewClient(&redis.Options{Dialer: func() (net.Conn, error) {
+		return nil, ErrMemoryRedisUnsupported
+	}})
+}
+
+//不支持, 返回的 PubSub 在接收时返回 ErrMemoryRedisUnsupported
+func (m *MemoryRedis) Subscribe(channels ...string) *redis.PubSub {
+	return m.unsupportedClient().Subscribe(channels...)
+}
+
+//不支持, 同 Subscribe
+func (m *MemoryRedis) PSubscribe(patterns ...string) *redis.PubSub {
+	return m.unsupportedClient().PSubscribe(patterns...)
+}
+
+//管道中的命令立即执行, Exec 返回执行过的命令
+func (m *MemoryRedis) Pipeline() RedisPipeliner {
+	return &memoryPipeline{m: m}
+}
+
+//同 Pipeline, 手动 Exec 时不保证原子执行, 需要时用 TxPipelined
+func (m *MemoryRedis) TxPipeline() RedisPipeliner {
+	return &memoryPipeline{m: m}
+}
+
+func (m *MemoryRedis) Pipelined(fn func(pipe RedisPipeliner) error) ([]redis.Cmder, error) {
+	pipe := &memoryPipeline{m: m}
+	if err := fn(pipe); err != nil {
+		return nil, err
+	}
+	return pipe.Exec()
+}
+
+//原子执行: 持有锁在数据副本上执行, fn 返回 nil 后替换数据; fn 中只能使用 pipe, 调用 db.Redis 会死锁
+func (m *MemoryRedis) TxPipelined(fn func(pipe RedisPipeliner) error) ([]redis.Cmder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data := make(map[string]*memoryValue, len(m.data))
+	for key, v := range m.data {
+		data[key] = v.clone()
+	}
+	pipe := &memoryPipeline{m: m.view(data)}
+	if err := fn(pipe); err != nil {
+		return nil, err
+	}
+	m.data = data
+	return pipe.Exec()
+}
+
+//共用 data 的实例, 调用方持有 m.mu, 用于在锁内执行多个命令
+func (m *MemoryRedis) view(data map[string]*memoryValue) *MemoryRedis {
+	return &MemoryRedis{data: data, offset: m.offset}
+}
+
+//-------- 键 --------
+
+func (m *MemoryRedis) Del(keys ...string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		if m.lookup(key) != nil {
+			delete(m.data, key)
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryRedis) Unlink(keys ...string) *redis.IntCmd {
+	return m.Del(keys...)
+}
+
+func (m *MemoryRedis) Exists(keys ...string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		if m.lookup(key) != nil {
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryRedis) Expire(key string, expiration time.Duration) *redis.BoolCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return redis.NewBoolResult(m.expireAt(key, m.now().Add(expiration)), nil)
+}
+
+//tm 按真实时间计算, FastForward 后同样前移
+func (m *MemoryRedis) ExpireAt(key string, tm time.Time) *redis.BoolCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return redis.NewBoolResult(m.expireAt(key, tm.Add(m.offset)), nil)
+}
+
+func (m *MemoryRedis) PExpire(key string, expiration time.Duration) *redis.BoolCmd {
+	return m.Expire(key, expiration)
+}
+
+func (m *MemoryRedis) expireAt(key string, at time.Time) bool {
+	v := m.lookup(key)
+	if v == nil {
+		return false
+	}
+	if !at.After(m.now()) {
+		delete(m.data, key)
+		return true
+	}
+	v.expireAt = at
+	return true
+}
+
+func (m *MemoryRedis) Persist(key string) *redis.BoolCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := m.lookup(key)
+	if v == nil || v.expireAt.IsZero() {
+		return redis.NewBoolResult(false, nil)
+	}
+	v.expireAt = time.Time{}
+	return redis.NewBoolResult(true, nil)
+}
+
+func (m *MemoryRedis) TTL(key string) *redis.DurationCmd {
+	return m.ttl(key, time.Second)
+}
+
+func (m *MemoryRedis) PTTL(key string) *redis.DurationCmd {
+	return m.ttl(key, time.Millisecond)
+}
+
+func (m *MemoryRedis) ttl(key string, precision time.Duration) *redis.DurationCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := m.lookup(key)
+	switch {
+	case v == nil:
+		return redis.NewDurationResult(-2*precision, nil)
+	case v.expireAt.IsZero():
+		return redis.NewDurationResult(-1*precision, nil)
+	}
+	d := v.expireAt.Sub(m.now())
+	return redis.NewDurationResult((d+precision/2)/precision*precision, nil)
+}
+
+func (m *MemoryRedis) Type(key string) *redis.StatusCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v := m.lookup(key); v != nil {
+		return redis.NewStatusResult(v.kind, nil)
+	}
+	return redis.NewStatusResult("none", nil)
+}
+
+func (m *MemoryRedis) Rename(key, newkey string) *redis.StatusCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := m.lookup(key)
+	if v == nil {
+		return redis.NewStatusResult("", errNoSuchKey)
+	}
+	delete(m.data, key)
+	m.data[newkey] = v
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (m *MemoryRedis) RenameNX(key, newkey string) *redis.BoolCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := m.lookup(key)
+	if v == nil {
+		return redis.NewBoolResult(false, errNoSuchKey)
+	}
+	if m.lookup(newkey) != nil {
+		return redis.NewBoolResult(false, nil)
+	}
+	delete(m.data, key)
+	m.data[newkey] = v
+	return redis.NewBoolResult(true, nil)
+}
+
+//-------- 字符串 --------
+
+func (m *MemoryRedis) Get(key string) *redis.StringCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryString)
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	if v == nil {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(v.str, nil)
+}
+
+func (m *MemoryRedis) GetSet(key string, value interface{}) *redis.StringCmd {
+	s, err := memoryArg(value)
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryString)
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	m.setString(key, s, 0)
+	if v == nil {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(v.str, nil)
+}
+
+//非字符串的值转为json, 同 redis 客户端
+func (m *MemoryRedis) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	s, err := memoryArg(jsonValue(value))
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setString(key, s, expiration)
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (m *MemoryRedis) SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return m.setIf(key, value, expiration, false)
+}
+
+func (m *MemoryRedis) SetXX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return m.setIf(key, value, expiration, true)
+}
+
+func (m *MemoryRedis) setIf(key string, value interface{}, expiration time.Duration, exists bool) *redis.BoolCmd {
+	s, err := memoryArg(jsonValue(value))
+	if err != nil {
+		return redis.NewBoolResult(false, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if (m.lookup(key) != nil) != exists {
+		return redis.NewBoolResult(false, nil)
+	}
+	m.setString(key, s, expiration)
+	return redis.NewBoolResult(true, nil)
+}
+
+func (m *MemoryRedis) MGet(keys ...string) *redis.SliceCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if v := m.lookup(key); v != nil && v.kind == memoryString {
+			values[i] = v.str
+		}
+	}
+	return redis.NewSliceResult(values, nil)
+}
+
+func (m *MemoryRedis) MSet(pairs ...interface{}) *redis.StatusCmd {
+	kv, err := memoryArgs(pairs)
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		m.setString(kv[i], kv[i+1], 0)
+	}
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (m *MemoryRedis) MSetNX(pairs ...interface{}) *redis.BoolCmd {
+	kv, err := memoryArgs(pairs)
+	if err != nil {
+		return redis.NewBoolResult(false, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		if m.lookup(kv[i]) != nil {
+			return redis.NewBoolResult(false, nil)
+		}
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		m.setString(kv[i], kv[i+1], 0)
+	}
+	return redis.NewBoolResult(true, nil)
+}
+
+func (m *MemoryRedis) Incr(key string) *redis.IntCmd {
+	return m.IncrBy(key, 1)
+}
+
+func (m *MemoryRedis) Decr(key string) *redis.IntCmd {
+	return m.IncrBy(key, -1)
+}
+
+func (m *MemoryRedis) DecrBy(key string, decrement int64) *redis.IntCmd {
+	return m.IncrBy(key, -decrement)
+}
+
+func (m *MemoryRedis) IncrBy(key string, value int64) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.create(key, memoryString)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	n := int64(0)
+	if v.str != "" {
+		if n, err = strconv.ParseInt(v.str, 10, 64); err != nil {
+			return redis.NewIntResult(0, errNotInteger)
+		}
+	}
+	n += value
+	v.str = strconv.FormatInt(n, 10)
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryRedis) IncrByFloat(key string, value float64) *redis.FloatCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.create(key, memoryString)
+	if err != nil {
+		return redis.NewFloatResult(0, err)
+	}
+	f := float64(0)
+	if v.str != "" {
+		if f, err = strconv.ParseFloat(v.str, 64); err != nil {
+			return redis.NewFloatResult(0, errNotFloat)
+		}
+	}
+	f += value
+	v.str = formatFloat(f)
+	return redis.NewFloatResult(f, nil)
+}
+
+func (m *MemoryRedis) Append(key, value string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.create(key, memoryString)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	v.str += value
+	return redis.NewIntResult(int64(len(v.str)), nil)
+}
+
+func (m *MemoryRedis) StrLen(key string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryString)
+	if err != nil || v == nil {
+		return redis.NewIntResult(0, err)
+	}
+	return redis.NewIntResult(int64(len(v.str)), nil)
+}
+
+//-------- 哈希 --------
+
+func (m *MemoryRedis) HDel(key string, fields ...string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryHash)
+	if err != nil || v == nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	for _, field := range fields {
+		if _, ok := v.hash[field]; ok {
+			delete(v.hash, field)
+			n++
+		}
+	}
+	m.cleanup(key, v)
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryRedis) HExists(key, field string) *redis.BoolCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryHash)
+	if err != nil || v == nil {
+		return redis.NewBoolResult(false, err)
+	}
+	_, ok := v.hash[field]
+	return redis.NewBoolResult(ok, nil)
+}
+
+func (m *MemoryRedis) HGet(key, field string) *redis.StringCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryHash)
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	if v == nil {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	s, ok := v.hash[field]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(s, nil)
+}
+
+func (m *MemoryRedis) HGetAll(key string) *redis.StringStringMapCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryHash)
+	all := map[string]string{}
+	if v != nil {
+		for field, s := range v.hash {
+			all[field] = s
+		}
+	}
+	return redis.NewStringStringMapResult(all, err)
+}
+
+func (m *MemoryRedis) HIncrBy(key, field string, incr int64) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.create(key, memoryHash)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	n := int64(0)
+	if s, ok := v.hash[field]; ok {
+		if n, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return redis.NewIntResult(0, errNotInteger)
+		}
+	}
+	n += incr
+	v.hash[field] = strconv.FormatInt(n, 10)
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryRedis) HIncrByFloat(key, field string, incr float64) *redis.FloatCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.create(key, memoryHash)
+	if err != nil {
+		return redis.NewFloatResult(0, err)
+	}
+	f := float64(0)
+	if s, ok := v.hash[field]; ok {
+		if f, err = strconv.ParseFloat(s, 64); err != nil {
+			return redis.NewFloatResult(0, errNotFloat)
+		}
+	}
+	f += incr
+	v.hash[field] = formatFloat(f)
+	return redis.NewFloatResult(f, nil)
+}
+
+func (m *MemoryRedis) HKeys(key string) *redis.StringSliceCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryHash)
+	keys := []string{}
+	if v != nil {
+		for field := range v.hash {
+			keys = append(keys, field)
+		}
+		sort.Strings(keys)
+	}
+	return redis.NewStringSliceResult(keys, err)
+}
+
+func (m *MemoryRedis) HLen(key string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryHash)
+	if err != nil || v == nil {
+		return redis.NewIntResult(0, err)
+	}
+	return redis.NewIntResult(int64(len(v.hash)), nil)
+}
+
+func (m *MemoryRedis) HMGet(key string, fields ...string) *redis.SliceCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryHash)
+	values := make([]interface{}, len(fields))
+	if v != nil {
+		for i, field := range fields {
+			if s, ok := v.hash[field]; ok {
+				values[i] = s
+			}
+		}
+	}
+	return redis.NewSliceResult(values, err)
+}
+
+func (m *MemoryRedis) HMSet(key string, fields map[string]interface{}) *redis.StatusCmd {
+	values := make(map[string]string, len(fields))
+	for field, value := range fields {
+		s, err := memoryArg(value)
+		if err != nil {
+			return redis.NewStatusResult("", err)
+		}
+		values[field] = s
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.create(key, memoryHash)
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+	for field, s := range values {
+		v.hash[field] = s
+	}
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (m *MemoryRedis) HSet(key, field string, value interface{}) *redis.BoolCmd {
+	return m.hset(key, field, value, true)
+}
+
+func (m *MemoryRedis) HSetNX(key, field string, value interface{}) *redis.BoolCmd {
+	return m.hset(key, field, value, false)
+}
+
+func (m *MemoryRedis) hset(key, field string, value interface{}, overwrite bool) *redis.BoolCmd {
+	s, err := memoryArg(value)
+	if err != nil {
+		return redis.NewBoolResult(false, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.create(key, memoryHash)
+	if err != nil {
+		return redis.NewBoolResult(false, err)
+	}
+	_, exists := v.hash[field]
+	if !exists || overwrite {
+		v.hash[field] = s
+	}
+	return redis.NewBoolResult(!exists, nil)
+}
+
+func (m *MemoryRedis) HVals(key string) *redis.StringSliceCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryHash)
+	values := []string{}
+	if v != nil {
+		fields := make([]string, 0, len(v.hash))
+		for field := range v.hash {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			values = append(values, v.hash[field])
+		}
+	}
+	return redis.NewStringSliceResult(values, err)
+}
+
+//一次返回全部匹配的字段和值, cursor 为0
+func (m *MemoryRedis) HScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryHash)
+	var list []string
+	if v != nil {
+		fields := make([]string, 0, len(v.hash))
+		for field := range v.hash {
+			if match == "" || memoryMatch(match, field) {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			list = append(list, field, v.hash[field])
+		}
+	}
+	return redis.NewScanCmdResult(list, 0, err)
+}
+
+//-------- 列表 --------
+
+func (m *MemoryRedis) LIndex(key string, index int64) *redis.StringCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryList)
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	if v == nil {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	if index < 0 {
+		index += int64(len(v.list))
+	}
+	if index < 0 || index >= int64(len(v.list)) {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(v.list[index], nil)
+}
+
+func (m *MemoryRedis) LInsertBefore(key string, pivot, value interface{}) *redis.IntCmd {
+	return m.linsert(key, pivot, value, 0)
+}
+
+func (m *MemoryRedis) LInsertAfter(key string, pivot, value interface{}) *redis.IntCmd {
+	return m.linsert(key, pivot, value, 1)
+}
+
+func (m *MemoryRedis) linsert(key string, pivot, value interface{}, offset int) *redis.IntCmd {
+	args, err := memoryArgs([]interface{}{pivot, value})
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryList)
+	if err != nil || v == nil {
+		return redis.NewIntResult(0, err)
+	}
+	for i, s := range v.list {
+		if s == args[0] {
+			i += offset
+			v.list = append(v.list[:i], append([]string{args[1]}, v.list[i:]...)...)
+			return redis.NewIntResult(int64(len(v.list)), nil)
+		}
+	}
+	return redis.NewIntResult(-1, nil)
+}
+
+func (m *MemoryRedis) LLen(key string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryList)
+	if err != nil || v == nil {
+		return redis.NewIntResult(0, err)
+	}
+	return redis.NewIntResult(int64(len(v.list)), nil)
+}
+
+func (m *MemoryRedis) LPop(key string) *redis.StringCmd {
+	return m.popResult(key, true)
+}
+
+func (m *MemoryRedis) RPop(key string) *redis.StringCmd {
+	return m.popResult(key, false)
+}
+
+func (m *MemoryRedis) popResult(key string, left bool) *redis.StringCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok, err := m.pop(key, left)
+	if err == nil && !ok {
+		err = redis.Nil
+	}
+	return redis.NewStringResult(s, err)
+}
+
+//从列表头部或尾部取出一个值, 调用方持有锁
+func (m *MemoryRedis) pop(key string, left bool) (string, bool, error) {
+	v, err := m.lookupKind(key, memoryList)
+	if err != nil || v == nil || len(v.list) == 0 {
+		return "", false, err
+	}
+	var s string
+	if left {
+		s, v.list = v.list[0], v.list[1:]
+	} else {
+		s, v.list = v.list[len(v.list)-1], v.list[:len(v.list)-1]
+	}
+	m.cleanup(key, v)
+	return s, true, nil
+}
+
+func (m *MemoryRedis) LPush(key string, values ...interface{}) *redis.IntCmd {
+	return m.push(key, values, true, false)
+}
+
+func (m *MemoryRedis) LPushX(key string, value interface{}) *redis.IntCmd {
+	return m.push(key, []interface{}{value}, true, true)
+}
+
+func (m *MemoryRedis) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return m.push(key, values, false, false)
+}
+
+func (m *MemoryRedis) RPushX(key string, value interface{}) *redis.IntCmd {
+	return m.push(key, []interface{}{value}, false, true)
+}
+
+func (m *MemoryRedis) push(key string, values []interface{}, left, exists bool) *redis.IntCmd {
+	list, err := memoryArgs(values)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if exists {
+		if v, err := m.lookupKind(key, memoryList); err != nil || v == nil {
+			return redis.NewIntResult(0, err)
+		}
+	}
+	v, err := m.create(key, memoryList)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	for _, s := range list {
+		if left {
+			v.list = append([]string{s}, v.list...)
+		} else {
+			v.list = append(v.list, s)
+		}
+	}
+	return redis.NewIntResult(int64(len(v.list)), nil)
+}
+
+func (m *MemoryRedis) LRange(key string, start, stop int64) *redis.StringSliceCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryList)
+	list := []string{}
+	if v != nil {
+		if i, j, ok := memoryRange(len(v.list), start, stop); ok {
+			list = append(list, v.list[i:j+1]...)
+		}
+	}
+	return redis.NewStringSliceResult(list, err)
+}
+
+//count 大于0从头部删除, 小于0从尾部删除, 等于0删除全部
+func (m *MemoryRedis) LRem(key string, count int64, value interface{}) *redis.IntCmd {
+	s, err := memoryArg(value)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryList)
+	if err != nil || v == nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	keep := make([]bool, len(v.list))
+	for k := range v.list {
+		i := k
+		if count < 0 {
+			i = len(v.list) - 1 - k
+		}
+		if v.list[i] == s && (limit == 0 || n < limit) {
+			n++
+			continue
+		}
+		keep[i] = true
+	}
+	list := v.list[:0:0]
+	for i, item := range v.list {
+		if keep[i] {
+			list = append(list, item)
+		}
+	}
+	v.list = list
+	m.cleanup(key, v)
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryRedis) LSet(key string, index int64, value interface{}) *redis.StatusCmd {
+	s, err := memoryArg(value)
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryList)
+	if err != nil {
+		return redis.NewStatusResult("", err)
+	}
+	if v == nil {
+		return redis.NewStatusResult("", errNoSuchKey)
+	}
+	if index < 0 {
+		index += int64(len(v.list))
+	}
+	if index < 0 || index >= int64(len(v.list)) {
+		return redis.NewStatusResult("", errOutOfRange)
+	}
+	v.list[index] = s
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (m *MemoryRedis) LTrim(key string, start, stop int64) *redis.StatusCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryList)
+	if err != nil || v == nil {
+		return redis.NewStatusResult("OK", err)
+	}
+	if i, j, ok := memoryRange(len(v.list), start, stop); ok {
+		v.list = append([]string(nil), v.list[i:j+1]...)
+	} else {
+		v.list = nil
+	}
+	m.cleanup(key, v)
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (m *MemoryRedis) RPopLPush(source, destination string) *redis.StringCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.lookupKind(destination, memoryList); err != nil {
+		return redis.NewStringResult("", err)
+	}
+	s, ok, err := m.pop(source, false)
+	if err != nil || !ok {
+		if err == nil {
+			err = redis.Nil
+		}
+		return redis.NewStringResult("", err)
+	}
+	v, _ := m.create(destination, memoryList)
+	v.list = append([]string{s}, v.list...)
+	return redis.NewStringResult(s, nil)
+}
+
+//轮询直到有值或超时, timeout 为0时一直等待
+func (m *MemoryRedis) BRPopLPush(source, destination string, timeout time.Duration) *redis.StringCmd {
+	deadline := time.Now().Add(timeout)
+	for {
+		cmd := m.RPopLPush(source, destination)
+		if cmd.Err() != redis.Nil || (timeout > 0 && time.Now().After(deadline)) {
+			return cmd
+		}
+		time.Sleep(memoryPollInterval)
+	}
+}
+
+//-------- 集合 --------
+
+func (m *MemoryRedis) SAdd(key string, members ...interface{}) *redis.IntCmd {
+	list, err := memoryArgs(members)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.create(key, memorySet)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	for _, s := range list {
+		if _, ok := v.set[s]; !ok {
+			v.set[s] = struct{}{}
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryRedis) SCard(key string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memorySet)
+	if err != nil || v == nil {
+		return redis.NewIntResult(0, err)
+	}
+	return redis.NewIntResult(int64(len(v.set)), nil)
+}
+
+//集合的成员, 调用方持有锁
+func (m *MemoryRedis) members(key string) (map[string]struct{}, error) {
+	v, err := m.lookupKind(key, memorySet)
+	if err != nil || v == nil {
+		return map[string]struct{}{}, err
+	}
+	return v.set, nil
+}
+
+//多个集合的差集、交集或并集, 调用方持有锁
+func (m *MemoryRedis) combine(op string, keys []string) (map[string]struct{}, error) {
+	result := map[string]struct{}{}
+	for i, key := range keys {
+		set, err := m.members(key)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case i == 0 || op == "union":
+			for s := range set {
+				result[s] = struct{}{}
+			}
+		case op == "diff":
+			for s := range set {
+				delete(result, s)
+			}
+		case op == "inter":
+			for s := range result {
+				if _, ok := set[s]; !ok {
+					delete(result, s)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func sortedMembers(set map[string]struct{}) []string {
+	list := make([]string, 0, len(set))
+	for s := range set {
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (m *MemoryRedis) setOp(op string, keys []string) *redis.StringSliceCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	set, err := m.combine(op, keys)
+	if err != nil {
+		return redis.NewStringSliceResult(nil, err)
+	}
+	return redis.NewStringSliceResult(sortedMembers(set), nil)
+}
+
+func (m *MemoryRedis) setOpStore(op, destination string, keys []string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	set, err := m.combine(op, keys)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	delete(m.data, destination)
+	if len(set) > 0 {
+		m.data[destination] = &memoryValue{kind: memorySet, set: set}
+	}
+	return redis.NewIntResult(int64(len(set)), nil)
+}
+
+func (m *MemoryRedis) SDiff(keys ...string) *redis.StringSliceCmd {
+	return m.setOp("diff", keys)
+}
+
+func (m *MemoryRedis) SDiffStore(destination string, keys ...string) *redis.IntCmd {
+	return m.setOpStore("diff", destination, keys)
+}
+
+func (m *MemoryRedis) SInter(keys ...string) *redis.StringSliceCmd {
+	return m.setOp("inter", keys)
+}
+
+func (m *MemoryRedis) SInterStore(destination string, keys ...string) *redis.IntCmd {
+	return m.setOpStore("inter", destination, keys)
+}
+
+func (m *MemoryRedis) SUnion(keys ...string) *redis.StringSliceCmd {
+	return m.setOp("union", keys)
+}
+
+func (m *MemoryRedis) SUnionStore(destination string, keys ...string) *redis.IntCmd {
+	return m.setOpStore("union", destination, keys)
+}
+
+func (m *MemoryRedis) SIsMember(key string, member interface{}) *redis.BoolCmd {
+	s, err := memoryArg(member)
+	if err != nil {
+		return redis.NewBoolResult(false, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	set, err := m.members(key)
+	_, ok := set[s]
+	return redis.NewBoolResult(ok, err)
+}
+
+func (m *MemoryRedis) SMembers(key string) *redis.StringSliceCmd {
+	return m.setOp("union", []string{key})
+}
+
+func (m *MemoryRedis) SMove(source, destination string, member interface{}) *redis.BoolCmd {
+	s, err := memoryArg(member)
+	if err != nil {
+		return redis.NewBoolResult(false, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	src, err := m.lookupKind(source, memorySet)
+	if err != nil || src == nil {
+		return redis.NewBoolResult(false, err)
+	}
+	if _, ok := src.set[s]; !ok {
+		return redis.NewBoolResult(false, nil)
+	}
+	dst, err := m.create(destination, memorySet)
+	if err != nil {
+		return redis.NewBoolResult(false, err)
+	}
+	delete(src.set, s)
+	dst.set[s] = struct{}{}
+	m.cleanup(source, src)
+	return redis.NewBoolResult(true, nil)
+}
+
+func (m *MemoryRedis) SPop(key string) *redis.StringCmd {
+	cmd := m.SPopN(key, 1)
+	list, err := cmd.Result()
+	if err == nil && len(list) == 0 {
+		err = redis.Nil
+	}
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	return redis.NewStringResult(list[0], nil)
+}
+
+func (m *MemoryRedis) SPopN(key string, count int64) *redis.StringSliceCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memorySet)
+	if err != nil || v == nil {
+		return redis.NewStringSliceResult([]string{}, err)
+	}
+	list := sortedMembers(v.set)
+	rand.Shuffle(len(list), func(i, j int) {
+		list[i], list[j] = list[j], list[i]
+	})
+	if int64(len(list)) > count {
+		list = list[:count]
+	}
+	for _, s := range list {
+		delete(v.set, s)
+	}
+	m.cleanup(key, v)
+	return redis.NewStringSliceResult(list, nil)
+}
+
+func (m *MemoryRedis) SRandMember(key string) *redis.StringCmd {
+	list, err := m.SRandMemberN(key, 1).Result()
+	if err == nil && len(list) == 0 {
+		err = redis.Nil
+	}
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	return redis.NewStringResult(list[0], nil)
+}
+
+//count 大于0时返回不重复的成员, 小于0时可能重复
+func (m *MemoryRedis) SRandMemberN(key string, count int64) *redis.StringSliceCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	set, err := m.members(key)
+	if err != nil || len(set) == 0 {
+		return redis.NewStringSliceResult([]string{}, err)
+	}
+	members := sortedMembers(set)
+	if count < 0 {
+		list := make([]string, -count)
+		for i := range list {
+			list[i] = members[rand.Intn(len(members))]
+		}
+		return redis.NewStringSliceResult(list, nil)
+	}
+	rand.Shuffle(len(members), func(i, j int) {
+		members[i], members[j] = members[j], members[i]
+	})
+	if int64(len(members)) > count {
+		members = members[:count]
+	}
+	return redis.NewStringSliceResult(members, nil)
+}
+
+func (m *MemoryRedis) SRem(key string, members ...interface{}) *redis.IntCmd {
+	list, err := memoryArgs(members)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memorySet)
+	if err != nil || v == nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	for _, s := range list {
+		if _, ok := v.set[s]; ok {
+			delete(v.set, s)
+			n++
+		}
+	}
+	m.cleanup(key, v)
+	return redis.NewIntResult(n, nil)
+}
+
+//一次返回全部匹配的成员, cursor 为0
+func (m *MemoryRedis) SScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	set, err := m.members(key)
+	var list []string
+	for _, s := range sortedMembers(set) {
+		if match == "" || memoryMatch(match, s) {
+			list = append(list, s)
+		}
+	}
+	return redis.NewScanCmdResult(list, 0, err)
+}
+
+//-------- 有序集合 --------
+
+//按分数、成员排序的有序集合, 调用方持有锁
+func (m *MemoryRedis) sortedZ(key string) ([]redis.Z, error) {
+	v, err := m.lookupKind(key, memoryZSet)
+	if err != nil || v == nil {
+		return []redis.Z{}, err
+	}
+	list := make([]redis.Z, 0, len(v.zset))
+	for member, score := range v.zset {
+		list = append(list, redis.Z{Score: score, Member: member})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score < list[j].Score
+		}
+		return list[i].Member.(string) < list[j].Member.(string)
+	})
+	return list, nil
+}
+
+func reverseZ(list []redis.Z) []redis.Z {
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return list
+}
+
+func zMembers(list []redis.Z) []string {
+	members := make([]string, len(list))
+	for i, z := range list {
+		members[i] = z.Member.(string)
+	}
+	return members
+}
+
+//分数区间的边界, 如 1、(1、-inf、+inf
+func parseScoreBound(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	switch s {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false, errors.New("ERR min or max is not a float")
+	}
+	return f, exclusive, nil
+}
+
+//分数在 min、max 之间的成员
+func filterScore(list []redis.Z, min, max string) ([]redis.Z, error) {
+	lo, loEx, err := parseScoreBound(min)
+	if err != nil {
+		return nil, err
+	}
+	hi, hiEx, err := parseScoreBound(max)
+	if err != nil {
+		return nil, err
+	}
+	result := []redis.Z{}
+	for _, z := range list {
+		if (z.Score > lo || (!loEx && z.Score == lo)) && (z.Score < hi || (!hiEx && z.Score == hi)) {
+			result = append(result, z)
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryRedis) zadd(key string, members []redis.Z, mode string) *redis.IntCmd {
+	values := make([]string, len(members))
+	for i, z := range members {
+		s, err := memoryArg(z.Member)
+		if err != nil {
+			return redis.NewIntResult(0, err)
+		}
+		values[i] = s
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mode == "xx" {
+		if v, err := m.lookupKind(key, memoryZSet); err != nil || v == nil {
+			return redis.NewIntResult(0, err)
+		}
+	}
+	v, err := m.create(key, memoryZSet)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	for i, z := range members {
+		_, exists := v.zset[values[i]]
+		if (mode == "nx" && exists) || (mode == "xx" && !exists) {
+			continue
+		}
+		if !exists {
+			n++
+		}
+		v.zset[values[i]] = z.Score
+	}
+	m.cleanup(key, v)
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryRedis) ZAdd(key string, members ...redis.Z) *redis.IntCmd {
+	return m.zadd(key, members, "")
+}
+
+func (m *MemoryRedis) ZAddNX(key string, members ...redis.Z) *redis.IntCmd {
+	return m.zadd(key, members, "nx")
+}
+
+func (m *MemoryRedis) ZAddXX(key string, members ...redis.Z) *redis.IntCmd {
+	return m.zadd(key, members, "xx")
+}
+
+func (m *MemoryRedis) ZIncrBy(key string, increment float64, member string) *redis.FloatCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.create(key, memoryZSet)
+	if err != nil {
+		return redis.NewFloatResult(0, err)
+	}
+	v.zset[member] += increment
+	return redis.NewFloatResult(v.zset[member], nil)
+}
+
+func (m *MemoryRedis) ZCard(key string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryZSet)
+	if err != nil || v == nil {
+		return redis.NewIntResult(0, err)
+	}
+	return redis.NewIntResult(int64(len(v.zset)), nil)
+}
+
+func (m *MemoryRedis) ZCount(key, min, max string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.sortedZ(key)
+	if err == nil {
+		list, err = filterScore(list, min, max)
+	}
+	return redis.NewIntResult(int64(len(list)), err)
+}
+
+func (m *MemoryRedis) zrange(key string, start, stop int64, reverse bool) ([]redis.Z, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.sortedZ(key)
+	if err != nil {
+		return nil, err
+	}
+	if reverse {
+		list = reverseZ(list)
+	}
+	i, j, ok := memoryRange(len(list), start, stop)
+	if !ok {
+		return []redis.Z{}, nil
+	}
+	return list[i : j+1], nil
+}
+
+func (m *MemoryRedis) ZRange(key string, start, stop int64) *redis.StringSliceCmd {
+	list, err := m.zrange(key, start, stop, false)
+	return redis.NewStringSliceResult(zMembers(list), err)
+}
+
+func (m *MemoryRedis) ZRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd {
+	list, err := m.zrange(key, start, stop, false)
+	return redis.NewZSliceCmdResult(list, err)
+}
+
+func (m *MemoryRedis) ZRevRange(key string, start, stop int64) *redis.StringSliceCmd {
+	list, err := m.zrange(key, start, stop, true)
+	return redis.NewStringSliceResult(zMembers(list), err)
+}
+
+func (m *MemoryRedis) ZRevRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd {
+	list, err := m.zrange(key, start, stop, true)
+	return redis.NewZSliceCmdResult(list, err)
+}
+
+func (m *MemoryRedis) zrangeByScore(key string, opt redis.ZRangeBy, reverse bool) ([]redis.Z, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.sortedZ(key)
+	if err == nil {
+		list, err = filterScore(list, opt.Min, opt.Max)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reverse {
+		list = reverseZ(list)
+	}
+	//与 go-redis 相同, Offset、Count 都为0时不限制
+	if opt.Offset != 0 || opt.Count != 0 {
+		if opt.Offset >= int64(len(list)) || opt.Offset < 0 {
+			return []redis.Z{}, nil
+		}
+		list = list[opt.Offset:]
+		if opt.Count >= 0 && opt.Count < int64(len(list)) {
+			list = list[:opt.Count]
+		}
+	}
+	return list, nil
+}
+
+func (m *MemoryRedis) ZRangeByScore(key string, opt redis.ZRangeBy) *redis.StringSliceCmd {
+	list, err := m.zrangeByScore(key, opt, false)
+	return redis.NewStringSliceResult(zMembers(list), err)
+}
+
+func (m *MemoryRedis) ZRangeByScoreWithScores(key string, opt redis.ZRangeBy) *redis.ZSliceCmd {
+	list, err := m.zrangeByScore(key, opt, false)
+	return redis.NewZSliceCmdResult(list, err)
+}
+
+func (m *MemoryRedis) ZRevRangeByScore(key string, opt redis.ZRangeBy) *redis.StringSliceCmd {
+	list, err := m.zrangeByScore(key, opt, true)
+	return redis.NewStringSliceResult(zMembers(list), err)
+}
+
+func (m *MemoryRedis) ZRevRangeByScoreWithScores(key string, opt redis.ZRangeBy) *redis.ZSliceCmd {
+	list, err := m.zrangeByScore(key, opt, true)
+	return redis.NewZSliceCmdResult(list, err)
+}
+
+func (m *MemoryRedis) zrank(key, member string, reverse bool) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.sortedZ(key)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	if reverse {
+		list = reverseZ(list)
+	}
+	for i, z := range list {
+		if z.Member.(string) == member {
+			return redis.NewIntResult(int64(i), nil)
+		}
+	}
+	return redis.NewIntResult(0, redis.Nil)
+}
+
+func (m *MemoryRedis) ZRank(key, member string) *redis.IntCmd {
+	return m.zrank(key, member, false)
+}
+
+func (m *MemoryRedis) ZRevRank(key, member string) *redis.IntCmd {
+	return m.zrank(key, member, true)
+}
+
+func (m *MemoryRedis) ZRem(key string, members ...interface{}) *redis.IntCmd {
+	list, err := memoryArgs(members)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryZSet)
+	if err != nil || v == nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	for _, s := range list {
+		if _, ok := v.zset[s]; ok {
+			delete(v.zset, s)
+			n++
+		}
+	}
+	m.cleanup(key, v)
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryRedis) removeZ(key string, list []redis.Z) int64 {
+	v := m.lookup(key)
+	for _, z := range list {
+		delete(v.zset, z.Member.(string))
+	}
+	m.cleanup(key, v)
+	return int64(len(list))
+}
+
+func (m *MemoryRedis) ZRemRangeByRank(key string, start, stop int64) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.sortedZ(key)
+	if err != nil || len(list) == 0 {
+		return redis.NewIntResult(0, err)
+	}
+	i, j, ok := memoryRange(len(list), start, stop)
+	if !ok {
+		return redis.NewIntResult(0, nil)
+	}
+	return redis.NewIntResult(m.removeZ(key, list[i:j+1]), nil)
+}
+
+func (m *MemoryRedis) ZRemRangeByScore(key, min, max string) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.sortedZ(key)
+	if err == nil {
+		list, err = filterScore(list, min, max)
+	}
+	if err != nil || len(list) == 0 {
+		return redis.NewIntResult(0, err)
+	}
+	return redis.NewIntResult(m.removeZ(key, list), nil)
+}
+
+func (m *MemoryRedis) ZScore(key, member string) *redis.FloatCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, err := m.lookupKind(key, memoryZSet)
+	if err != nil {
+		return redis.NewFloatResult(0, err)
+	}
+	if v == nil {
+		return redis.NewFloatResult(0, redis.Nil)
+	}
+	score, ok := v.zset[member]
+	if !ok {
+		return redis.NewFloatResult(0, redis.Nil)
+	}
+	return redis.NewFloatResult(score, nil)
+}
+
+//有序集合或集合(分数为1)的成员, 调用方持有锁
+func (m *MemoryRedis) zscores(key string) (map[string]float64, error) {
+	v := m.lookup(key)
+	if v == nil {
+		return map[string]float64{}, nil
+	}
+	switch v.kind {
+	case memoryZSet:
+		return v.zset, nil
+	case memorySet:
+		scores := make(map[string]float64, len(v.set))
+		for s := range v.set {
+			scores[s] = 1
+		}
+		return scores, nil
+	}
+	return nil, errWrongType
+}
+
+func (m *MemoryRedis) zstore(destination string, store redis.ZStore, keys []string, inter bool) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := map[string]float64{}
+	for i, key := range keys {
+		scores, err := m.zscores(key)
+		if err != nil {
+			return redis.NewIntResult(0, err)
+		}
+		weight := float64(1)
+		if i < len(store.Weights) {
+			weight = store.Weights[i]
+		}
+		if inter && i > 0 {
+			for member := range result {
+				if _, ok := scores[member]; !ok {
+					delete(result, member)
+				}
+			}
+		}
+		for member, score := range scores {
+			score *= weight
+			old, ok := result[member]
+			switch {
+			case i == 0 || (!ok && !inter):
+				result[member] = score
+			case !ok:
+			case strings.EqualFold(store.Aggregate, "min"):
+				result[member] = math.Min(old, score)
+			case strings.EqualFold(store.Aggregate, "max"):
+				result[member] = math.Max(old, score)
+			default:
+				result[member] = old + score
+			}
+		}
+	}
+	delete(m.data, destination)
+	if len(result) > 0 {
+		m.data[destination] = &memoryValue{kind: memoryZSet, zset: result}
+	}
+	return redis.NewIntResult(int64(len(result)), nil)
+}
+
+func (m *MemoryRedis) ZInterStore(destination string, store redis.ZStore, keys ...string) *redis.IntCmd {
+	return m.zstore(destination, store, keys, true)
+}
+
+func (m *MemoryRedis) ZUnionStore(destination string, store redis.ZStore, keys ...string) *redis.IntCmd {
+	return m.zstore(destination, store, keys, false)
+}
+
+//一次返回全部匹配的成员和分数, cursor 为0
+func (m *MemoryRedis) ZScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.sortedZ(key)
+	var result []string
+	for _, z := range list {
+		member := z.Member.(string)
+		if match == "" || memoryMatch(match, member) {
+			result = append(result, member, formatFloat(z.Score))
+		}
+	}
+	return redis.NewScanCmdResult(result, 0, err)
+}
+
+//-------- 脚本、发布 --------
+
+//脚本的 Go 实现, keys、args 对应 KEYS、ARGV, r 上的命令在同一个锁内执行, 与脚本一样不会穿插其它命令
+//返回值同脚本的返回: int64、string、[]interface{}, nil 对应 lua 的 false 或 nil
+type MemoryScript func(r RedisCmdable, keys []string, args []string) (interface{}, error)
+
+var (
+	memoryScriptsMu sync.RWMutex
+	memoryScripts   = map[string]MemoryScript{}
+)
+
+//注册脚本的 Go 实现, MemoryRedis 执行 Eval、EvalSha 时按脚本的 sha1 查找; 在用到脚本的测试文件的 init 中注册
+//	db.RegisterMemoryScript(obtainScript, memoryObtain)
+func RegisterMemoryScript(script string, fn MemoryScript) {
+	memoryScriptsMu.Lock()
+	memoryScripts[scriptSha1(script)] = fn
+	memoryScriptsMu.Unlock()
+}
+
+func scriptSha1(script string) string {
+	sum := sha1.Sum([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+//执行 RegisterMemoryScript 注册的 Go 实现, 未注册时返回 ErrMemoryRedisUnsupported
+func (m *MemoryRedis) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	return m.EvalSha(scriptSha1(script), keys, args...)
+}
+
+func (m *MemoryRedis) EvalSha(sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	memoryScriptsMu.RLock()
+	fn, ok := memoryScripts[strings.ToLower(sha1)]
+	memoryScriptsMu.RUnlock()
+	if !ok {
+		return redis.NewCmdResult(nil, ErrMemoryRedisUnsupported)
+	}
+	argv, err := memoryArgs(args)
+	if err != nil {
+		return redis.NewCmdResult(nil, err)
+	}
+
+	m.mu.Lock()
+	v, err := fn(m.view(m.data), keys, argv)
+	m.mu.Unlock()
+	if err == nil && v == nil {
+		err = redis.Nil
+	}
+	return redis.NewCmdResult(v, err)
+}
+
+//没有订阅者, 返回0
+func (m *MemoryRedis) Publish(channel string, message interface{}) *redis.IntCmd {
+	return redis.NewIntResult(0, nil)
+}
@@ -0,0 +1,461 @@
+package db
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//内存 redis 的管道, 命令立即执行并记录, Exec 返回记录的命令和第一个错误, 同 go-redis
+//Pipeline()、Pipelined 中的命令直接在 MemoryRedis 上执行, 其它命令可能穿插其中
+//TxPipelined 在数据的副本上执行, fn 返回 nil 后一次替换, fn 出错时不生效, 同 MULTI/EXEC
+type memoryPipeline struct {
+	m    *MemoryRedis
+	cmds []redis.Cmder
+}
+
+func (p *memoryPipeline) add(cmd redis.Cmder) redis.Cmder {
+	p.cmds = append(p.cmds, cmd)
+	return cmd
+}
+
+func (p *memoryPipeline) Exec() ([]redis.Cmder, error) {
+	cmds := p.cmds
+	p.cmds = nil
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			return cmds, err
+		}
+	}
+	return cmds, nil
+}
+
+//命令已执行, 只清空记录
+func (p *memoryPipeline) Discard() error {
+	p.cmds = nil
+	return nil
+}
+
+func (p *memoryPipeline) BuildKey(key string) string {
+	return p.m.BuildKey(key)
+}
+
+//-------- 键 --------
+
+func (p *memoryPipeline) Del(keys ...string) *redis.IntCmd {
+	return p.add(p.m.Del(keys...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) Unlink(keys ...string) *redis.IntCmd {
+	return p.add(p.m.Unlink(keys...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) Exists(keys ...string) *redis.IntCmd {
+	return p.add(p.m.Exists(keys...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) Expire(key string, expiration time.Duration) *redis.BoolCmd {
+	return p.add(p.m.Expire(key, expiration)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) ExpireAt(key string, tm time.Time) *redis.BoolCmd {
+	return p.add(p.m.ExpireAt(key, tm)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) PExpire(key string, expiration time.Duration) *redis.BoolCmd {
+	return p.add(p.m.PExpire(key, expiration)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) Persist(key string) *redis.BoolCmd {
+	return p.add(p.m.Persist(key)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) TTL(key string) *redis.DurationCmd {
+	return p.add(p.m.TTL(key)).(*redis.DurationCmd)
+}
+
+func (p *memoryPipeline) PTTL(key string) *redis.DurationCmd {
+	return p.add(p.m.PTTL(key)).(*redis.DurationCmd)
+}
+
+func (p *memoryPipeline) Type(key string) *redis.StatusCmd {
+	return p.add(p.m.Type(key)).(*redis.StatusCmd)
+}
+
+func (p *memoryPipeline) Rename(key, newkey string) *redis.StatusCmd {
+	return p.add(p.m.Rename(key, newkey)).(*redis.StatusCmd)
+}
+
+func (p *memoryPipeline) RenameNX(key, newkey string) *redis.BoolCmd {
+	return p.add(p.m.RenameNX(key, newkey)).(*redis.BoolCmd)
+}
+
+
+//-------- 字符串 --------
+
+func (p *memoryPipeline) Get(key string) *redis.StringCmd {
+	return p.add(p.m.Get(key)).(*redis.StringCmd)
+}
+
+func (p *memoryPipeline) GetSet(key string, value interface{}) *redis.StringCmd {
+	return p.add(p.m.GetSet(key, value)).(*redis.StringCmd)
+}
+
+func (p *memoryPipeline) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	return p.add(p.m.Set(key, value, expiration)).(*redis.StatusCmd)
+}
+
+func (p *memoryPipeline) SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return p.add(p.m.SetNX(key, value, expiration)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) SetXX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return p.add(p.m.SetXX(key, value, expiration)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) MGet(keys ...string) *redis.SliceCmd {
+	return p.add(p.m.MGet(keys...)).(*redis.SliceCmd)
+}
+
+func (p *memoryPipeline) MSet(pairs ...interface{}) *redis.StatusCmd {
+	return p.add(p.m.MSet(pairs...)).(*redis.StatusCmd)
+}
+
+func (p *memoryPipeline) MSetNX(pairs ...interface{}) *redis.BoolCmd {
+	return p.add(p.m.MSetNX(pairs...)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) Incr(key string) *redis.IntCmd {
+	return p.add(p.m.Incr(key)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) IncrBy(key string, value int64) *redis.IntCmd {
+	return p.add(p.m.IncrBy(key, value)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) IncrByFloat(key string, value float64) *redis.FloatCmd {
+	return p.add(p.m.IncrByFloat(key, value)).(*redis.FloatCmd)
+}
+
+func (p *memoryPipeline) Decr(key string) *redis.IntCmd {
+	return p.add(p.m.Decr(key)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) DecrBy(key string, decrement int64) *redis.IntCmd {
+	return p.add(p.m.DecrBy(key, decrement)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) Append(key, value string) *redis.IntCmd {
+	return p.add(p.m.Append(key, value)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) StrLen(key string) *redis.IntCmd {
+	return p.add(p.m.StrLen(key)).(*redis.IntCmd)
+}
+
+
+//-------- 哈希 --------
+
+func (p *memoryPipeline) HDel(key string, fields ...string) *redis.IntCmd {
+	return p.add(p.m.HDel(key, fields...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) HExists(key, field string) *redis.BoolCmd {
+	return p.add(p.m.HExists(key, field)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) HGet(key, field string) *redis.StringCmd {
+	return p.add(p.m.HGet(key, field)).(*redis.StringCmd)
+}
+
+func (p *memoryPipeline) HGetAll(key string) *redis.StringStringMapCmd {
+	return p.add(p.m.HGetAll(key)).(*redis.StringStringMapCmd)
+}
+
+func (p *memoryPipeline) HIncrBy(key, field string, incr int64) *redis.IntCmd {
+	return p.add(p.m.HIncrBy(key, field, incr)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) HIncrByFloat(key, field string, incr float64) *redis.FloatCmd {
+	return p.add(p.m.HIncrByFloat(key, field, incr)).(*redis.FloatCmd)
+}
+
+func (p *memoryPipeline) HKeys(key string) *redis.StringSliceCmd {
+	return p.add(p.m.HKeys(key)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) HLen(key string) *redis.IntCmd {
+	return p.add(p.m.HLen(key)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) HMGet(key string, fields ...string) *redis.SliceCmd {
+	return p.add(p.m.HMGet(key, fields...)).(*redis.SliceCmd)
+}
+
+func (p *memoryPipeline) HMSet(key string, fields map[string]interface{}) *redis.StatusCmd {
+	return p.add(p.m.HMSet(key, fields)).(*redis.StatusCmd)
+}
+
+func (p *memoryPipeline) HSet(key, field string, value interface{}) *redis.BoolCmd {
+	return p.add(p.m.HSet(key, field, value)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) HSetNX(key, field string, value interface{}) *redis.BoolCmd {
+	return p.add(p.m.HSetNX(key, field, value)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) HVals(key string) *redis.StringSliceCmd {
+	return p.add(p.m.HVals(key)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) HScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return p.add(p.m.HScan(key, cursor, match, count)).(*redis.ScanCmd)
+}
+
+
+//-------- 列表 --------
+
+func (p *memoryPipeline) LIndex(key string, index int64) *redis.StringCmd {
+	return p.add(p.m.LIndex(key, index)).(*redis.StringCmd)
+}
+
+func (p *memoryPipeline) LInsertBefore(key string, pivot, value interface{}) *redis.IntCmd {
+	return p.add(p.m.LInsertBefore(key, pivot, value)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) LInsertAfter(key string, pivot, value interface{}) *redis.IntCmd {
+	return p.add(p.m.LInsertAfter(key, pivot, value)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) LLen(key string) *redis.IntCmd {
+	return p.add(p.m.LLen(key)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) LPop(key string) *redis.StringCmd {
+	return p.add(p.m.LPop(key)).(*redis.StringCmd)
+}
+
+func (p *memoryPipeline) LPush(key string, values ...interface{}) *redis.IntCmd {
+	return p.add(p.m.LPush(key, values...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) LPushX(key string, value interface{}) *redis.IntCmd {
+	return p.add(p.m.LPushX(key, value)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) LRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return p.add(p.m.LRange(key, start, stop)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) LRem(key string, count int64, value interface{}) *redis.IntCmd {
+	return p.add(p.m.LRem(key, count, value)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) LSet(key string, index int64, value interface{}) *redis.StatusCmd {
+	return p.add(p.m.LSet(key, index, value)).(*redis.StatusCmd)
+}
+
+func (p *memoryPipeline) LTrim(key string, start, stop int64) *redis.StatusCmd {
+	return p.add(p.m.LTrim(key, start, stop)).(*redis.StatusCmd)
+}
+
+func (p *memoryPipeline) RPop(key string) *redis.StringCmd {
+	return p.add(p.m.RPop(key)).(*redis.StringCmd)
+}
+
+func (p *memoryPipeline) RPopLPush(source, destination string) *redis.StringCmd {
+	return p.add(p.m.RPopLPush(source, destination)).(*redis.StringCmd)
+}
+
+func (p *memoryPipeline) BRPopLPush(source, destination string, timeout time.Duration) *redis.StringCmd {
+	return p.add(p.m.BRPopLPush(source, destination, timeout)).(*redis.StringCmd)
+}
+
+func (p *memoryPipeline) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return p.add(p.m.RPush(key, values...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) RPushX(key string, value interface{}) *redis.IntCmd {
+	return p.add(p.m.RPushX(key, value)).(*redis.IntCmd)
+}
+
+
+//-------- 集合 --------
+
+func (p *memoryPipeline) SAdd(key string, members ...interface{}) *redis.IntCmd {
+	return p.add(p.m.SAdd(key, members...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) SCard(key string) *redis.IntCmd {
+	return p.add(p.m.SCard(key)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) SDiff(keys ...string) *redis.StringSliceCmd {
+	return p.add(p.m.SDiff(keys...)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) SDiffStore(destination string, keys ...string) *redis.IntCmd {
+	return p.add(p.m.SDiffStore(destination, keys...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) SInter(keys ...string) *redis.StringSliceCmd {
+	return p.add(p.m.SInter(keys...)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) SInterStore(destination string, keys ...string) *redis.IntCmd {
+	return p.add(p.m.SInterStore(destination, keys...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) SIsMember(key string, member interface{}) *redis.BoolCmd {
+	return p.add(p.m.SIsMember(key, member)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) SMembers(key string) *redis.StringSliceCmd {
+	return p.add(p.m.SMembers(key)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) SMove(source, destination string, member interface{}) *redis.BoolCmd {
+	return p.add(p.m.SMove(source, destination, member)).(*redis.BoolCmd)
+}
+
+func (p *memoryPipeline) SPop(key string) *redis.StringCmd {
+	return p.add(p.m.SPop(key)).(*redis.StringCmd)
+}
+
+func (p *memoryPipeline) SPopN(key string, count int64) *redis.StringSliceCmd {
+	return p.add(p.m.SPopN(key, count)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) SRandMember(key string) *redis.StringCmd {
+	return p.add(p.m.SRandMember(key)).(*redis.StringCmd)
+}
+
+func (p *memoryPipeline) SRandMemberN(key string, count int64) *redis.StringSliceCmd {
+	return p.add(p.m.SRandMemberN(key, count)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) SRem(key string, members ...interface{}) *redis.IntCmd {
+	return p.add(p.m.SRem(key, members...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) SUnion(keys ...string) *redis.StringSliceCmd {
+	return p.add(p.m.SUnion(keys...)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) SUnionStore(destination string, keys ...string) *redis.IntCmd {
+	return p.add(p.m.SUnionStore(destination, keys...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) SScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return p.add(p.m.SScan(key, cursor, match, count)).(*redis.ScanCmd)
+}
+
+
+//-------- 有序集合 --------
+
+func (p *memoryPipeline) ZAdd(key string, members ...redis.Z) *redis.IntCmd {
+	return p.add(p.m.ZAdd(key, members...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZAddNX(key string, members ...redis.Z) *redis.IntCmd {
+	return p.add(p.m.ZAddNX(key, members...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZAddXX(key string, members ...redis.Z) *redis.IntCmd {
+	return p.add(p.m.ZAddXX(key, members...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZIncrBy(key string, increment float64, member string) *redis.FloatCmd {
+	return p.add(p.m.ZIncrBy(key, increment, member)).(*redis.FloatCmd)
+}
+
+func (p *memoryPipeline) ZCard(key string) *redis.IntCmd {
+	return p.add(p.m.ZCard(key)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZCount(key, min, max string) *redis.IntCmd {
+	return p.add(p.m.ZCount(key, min, max)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return p.add(p.m.ZRange(key, start, stop)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) ZRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd {
+	return p.add(p.m.ZRangeWithScores(key, start, stop)).(*redis.ZSliceCmd)
+}
+
+func (p *memoryPipeline) ZRangeByScore(key string, opt redis.ZRangeBy) *redis.StringSliceCmd {
+	return p.add(p.m.ZRangeByScore(key, opt)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) ZRangeByScoreWithScores(key string, opt redis.ZRangeBy) *redis.ZSliceCmd {
+	return p.add(p.m.ZRangeByScoreWithScores(key, opt)).(*redis.ZSliceCmd)
+}
+
+func (p *memoryPipeline) ZRevRange(key string, start, stop int64) *redis.StringSliceCmd {
+	return p.add(p.m.ZRevRange(key, start, stop)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) ZRevRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd {
+	return p.add(p.m.ZRevRangeWithScores(key, start, stop)).(*redis.ZSliceCmd)
+}
+
+func (p *memoryPipeline) ZRevRangeByScore(key string, opt redis.ZRangeBy) *redis.StringSliceCmd {
+	return p.add(p.m.ZRevRangeByScore(key, opt)).(*redis.StringSliceCmd)
+}
+
+func (p *memoryPipeline) ZRevRangeByScoreWithScores(key string, opt redis.ZRangeBy) *redis.ZSliceCmd {
+	return p.add(p.m.ZRevRangeByScoreWithScores(key, opt)).(*redis.ZSliceCmd)
+}
+
+func (p *memoryPipeline) ZRank(key, member string) *redis.IntCmd {
+	return p.add(p.m.ZRank(key, member)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZRevRank(key, member string) *redis.IntCmd {
+	return p.add(p.m.ZRevRank(key, member)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZRem(key string, members ...interface{}) *redis.IntCmd {
+	return p.add(p.m.ZRem(key, members...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZRemRangeByRank(key string, start, stop int64) *redis.IntCmd {
+	return p.add(p.m.ZRemRangeByRank(key, start, stop)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZRemRangeByScore(key, min, max string) *redis.IntCmd {
+	return p.add(p.m.ZRemRangeByScore(key, min, max)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZScore(key, member string) *redis.FloatCmd {
+	return p.add(p.m.ZScore(key, member)).(*redis.FloatCmd)
+}
+
+func (p *memoryPipeline) ZInterStore(destination string, store redis.ZStore, keys ...string) *redis.IntCmd {
+	return p.add(p.m.ZInterStore(destination, store, keys...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZUnionStore(destination string, store redis.ZStore, keys ...string) *redis.IntCmd {
+	return p.add(p.m.ZUnionStore(destination, store, keys...)).(*redis.IntCmd)
+}
+
+func (p *memoryPipeline) ZScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return p.add(p.m.ZScan(key, cursor, match, count)).(*redis.ScanCmd)
+}
+
+
+//-------- 脚本、发布 --------
+
+func (p *memoryPipeline) Eval(script string, keys []string, args ...interface{}) *redis.Cmd {
+	return p.add(p.m.Eval(script, keys, args...)).(*redis.Cmd)
+}
+
+func (p *memoryPipeline) EvalSha(sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	return p.add(p.m.EvalSha(sha1, keys, args...)).(*redis.Cmd)
+}
+
+func (p *memoryPipeline) Publish(channel string, message interface{}) *redis.IntCmd {
+	return p.add(p.m.Publish(channel, message)).(*redis.IntCmd)
+}
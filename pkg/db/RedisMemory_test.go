@@ -0,0 +1,120 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis"
+)
+
+func TestMemoryPipelineCmds(t *testing.T) {
+	mem := NewMemoryRedis()
+	mem.Set("a", "1", 0)
+
+	cmds, err := mem.Pipelined(func(pipe RedisPipeliner) error {
+		pipe.Incr("a")
+		pipe.Get("missing")
+		pipe.HSet("h", "f", "v")
+		return nil
+	})
+	if err != redis.Nil {
+		t.Errorf("Pipelined error = %v, want redis.Nil of the missing key", err)
+	}
+	if len(cmds) != 3 {
+		t.Fatalf("Pipelined cmds = %d, want 3", len(cmds))
+	}
+	if v := cmds[0].(*redis.IntCmd).Val(); v != 2 {
+		t.Errorf("pipelined INCR = %d, want 2", v)
+	}
+	if err := cmds[1].Err(); err != redis.Nil {
+		t.Errorf("pipelined GET missing error = %v, want redis.Nil", err)
+	}
+
+	//Exec 后管道清空
+	pipe := mem.Pipeline()
+	get := pipe.Get("a")
+	if cmds, _ := pipe.Exec(); len(cmds) != 1 || get.Val() != "2" {
+		t.Errorf("Exec = %v, GET = %q, want 1 cmd and 2", cmds, get.Val())
+	}
+	if cmds, err := pipe.Exec(); cmds != nil || err != nil {
+		t.Errorf("empty Exec = %v, %v, want nil, nil", cmds, err)
+	}
+}
+
+func TestMemoryTxPipelinedRollback(t *testing.T) {
+	mem := NewMemoryRedis()
+	mem.Set("a", "1", 0)
+	mem.RPush("l", "x")
+
+	fail := errors.New("fail")
+	if _, err := mem.TxPipelined(func(pipe RedisPipeliner) error {
+		pipe.Set("a", "2", 0)
+		pipe.RPush("l", "y")
+		pipe.Del("l2")
+		return fail
+	}); err != fail {
+		t.Fatalf("TxPipelined error = %v, want %v", err, fail)
+	}
+	if v := mem.Get("a").Val(); v != "1" {
+		t.Errorf("a after rollback = %q, want 1", v)
+	}
+	if n := mem.LLen("l").Val(); n != 1 {
+		t.Errorf("list length after rollback = %d, want 1", n)
+	}
+
+	cmds, err := mem.TxPipelined(func(pipe RedisPipeliner) error {
+		pipe.Set("a", "2", 0)
+		pipe.RPush("l", "y")
+		return nil
+	})
+	if err != nil || len(cmds) != 2 {
+		t.Fatalf("TxPipelined = %d cmds, %v", len(cmds), err)
+	}
+	if v := mem.Get("a").Val(); v != "2" {
+		t.Errorf("a after commit = %q, want 2", v)
+	}
+	if n := cmds[1].(*redis.IntCmd).Val(); n != 2 {
+		t.Errorf("pipelined RPUSH = %d, want 2", n)
+	}
+}
+
+func TestMemoryEval(t *testing.T) {
+	mem := NewMemoryRedis()
+	script := `return redis.call("INCRBY", KEYS[1], ARGV[1])`
+	if err := mem.Eval(script, []string{"n"}, 2).Err(); err != ErrMemoryRedisUnsupported {
+		t.Errorf("Eval of an unregistered script error = %v, want ErrMemoryRedisUnsupported", err)
+	}
+
+	RegisterMemoryScript(script, func(r RedisCmdable, keys []string, args []string) (interface{}, error) {
+		return nil, nil
+	})
+	if err := mem.Eval(script, []string{"n"}, 2).Err(); err != redis.Nil {
+		t.Errorf("Eval returning nil error = %v, want redis.Nil", err)
+	}
+
+	RegisterMemoryScript(script, func(r RedisCmdable, keys []string, args []string) (interface{}, error) {
+		return r.IncrBy(keys[0], 2).Result()
+	})
+	if v, err := mem.Eval(script, []string{"n"}, 2).Result(); err != nil || v != int64(2) {
+		t.Errorf("Eval = %v, %v, want 2", v, err)
+	}
+	if v, err := mem.EvalSha(scriptSha1(script), []string{"n"}, 2).Result(); err != nil || v != int64(4) {
+		t.Errorf("EvalSha = %v, %v, want 4", v, err)
+	}
+}
+
+func TestCacheTagVersions(t *testing.T) {
+	mem := NewMemoryRedis()
+	Redis = mem
+	defer func() { Redis = nil }()
+
+	c := NewModelCache("test_order", "test_member")
+	if v := c.tagVersions(); v != "0.0" {
+		t.Errorf("tagVersions = %q, want 0.0", v)
+	}
+	InvalidateCacheTags("test_member")
+	InvalidateCacheTags("test_member")
+	if v := c.tagVersions(); v != "0.2" {
+		t.Errorf("tagVersions = %q, want 0.2", v)
+	}
+}
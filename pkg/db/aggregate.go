@@ -0,0 +1,246 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/yimishiji/bee/pkg/filters"
+	"github.com/yimishiji/bee/pkg/types"
+)
+
+var (
+	int64Type   = reflect.TypeOf(int64(0))
+	float64Type = reflect.TypeOf(float64(0))
+)
+
+//ApplyAggregate 记录结果字段类型的 gorm 设置, ScanAggregate 按类型转换结果
+const aggregateTypesSetting = "bee:aggregate_types"
+
+//聚合查询: 按分组字段和聚合项设置 select、group by, having 条件写法同 filter
+//gorm 需已通过 Model() 指定模型, 分组和聚合的字段只能是模型中的字段, 隐藏字段不可用
+//sortFields 只能是结果字段名, 如 -count
+func ApplyAggregate(gorm *gorm.DB, groupBy []filters.GroupField, aggs []filters.Aggregate, having *filters.Condition, sortFields []string) (*gorm.DB, error) {
+	if gorm.Value == nil {
+		return gorm, fmt.Errorf("aggregate needs a model")
+	}
+	scope := gorm.NewScope(gorm.Value)
+	dialect := gorm.Dialect().GetName()
+	columnTypes := visibleColumnTypes(scope)
+	table := scope.Quote(scope.TableName())
+	if len(aggs) == 0 {
+		aggs = []filters.Aggregate{{Func: filters.AggCount, Field: "*"}}
+	}
+
+	//结果字段名对应的表达式和类型, having 中使用表达式以兼容不支持别名的数据库
+	exprs := map[string]string{}
+	aliasTypes := map[string]reflect.Type{}
+	var selects, groups []string
+
+	for _, g := range groupBy {
+		t, ok := columnTypes[g.Field]
+		if !ok {
+			return gorm, fmt.Errorf("unknown group_by field: %s", g.Field)
+		}
+		expr := table + "." + scope.Quote(g.Field)
+		if g.Bucket != "" {
+			var err error
+			if expr, err = bucketExpr(dialect, expr, g.Bucket); err != nil {
+				return gorm, err
+			}
+			t = nil
+		}
+		exprs[g.Alias()], aliasTypes[g.Alias()] = expr, t
+		selects = append(selects, expr+" AS "+scope.Quote(g.Alias()))
+		groups = append(groups, expr)
+	}
+
+	for _, a := range aggs {
+		expr := "COUNT(*)"
+		t := int64Type
+		if a.Field != "*" {
+			ft, ok := columnTypes[a.Field]
+			if !ok {
+				return gorm, fmt.Errorf("unknown aggregate field: %s", a.Field)
+			}
+			expr = strings.ToUpper(a.Func) + "(" + table + "." + scope.Quote(a.Field) + ")"
+			switch a.Func {
+			case filters.AggSum, filters.AggAvg:
+				t = sumType(a.Func, ft)
+			case filters.AggMin, filters.AggMax:
+				t = ft
+			}
+		}
+		if _, ok := exprs[a.Alias()]; ok {
+			return gorm, fmt.Errorf("duplicate aggregate: %s", a.Alias())
+		}
+		exprs[a.Alias()], aliasTypes[a.Alias()] = expr, t
+		selects = append(selects, expr+" AS "+scope.Quote(a.Alias()))
+	}
+
+	gorm = gorm.Select(strings.Join(selects, ", "))
+	if len(groups) > 0 {
+		gorm = gorm.Group(strings.Join(groups, ", "))
+	}
+
+	if having != nil {
+		havingSql, args, err := compileFilter(func(field string) (string, reflect.Type, error) {
+			expr, ok := exprs[field]
+			if !ok {
+				return "", nil, fmt.Errorf("unknown having field: %s", field)
+			}
+			return expr, aliasTypes[field], nil
+		}, having)
+		if err != nil {
+			return gorm, err
+		}
+		gorm = gorm.Having(havingSql, args...)
+	}
+
+	for _, v := range sortFields {
+		alias := strings.TrimPrefix(v, "-")
+		if _, ok := exprs[alias]; !ok {
+			return gorm, fmt.Errorf("invalid sort field for aggregate: %s", alias)
+		}
+		if strings.HasPrefix(v, "-") {
+			gorm = gorm.Order(scope.Quote(alias) + " DESC")
+		} else {
+			gorm = gorm.Order(scope.Quote(alias))
+		}
+	}
+	return gorm.Set(aggregateTypesSetting, aliasTypes), nil
+}
+
+//sum、avg 结果的类型: 定点小数字段为 types.Decimal, 整数字段 sum 为 int64, 其它为 float64
+func sumType(fn string, t reflect.Type) reflect.Type {
+	if t == nil {
+		return float64Type
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case decimalType, nullDecimalType:
+		return decimalType
+	case nullInt64Type, nullUint64Type:
+		if fn == filters.AggSum {
+			return int64Type
+		}
+		return float64Type
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if fn == filters.AggSum {
+			return int64Type
+		}
+	}
+	return float64Type
+}
+
+//时间分段表达式
+func bucketExpr(dialect, column, bucket string) (string, error) {
+	formats := map[string][3]string{
+		filters.BucketHour:  {"%Y-%m-%d %H:00", "YYYY-MM-DD HH24:00", "%Y-%m-%d %H:00"},
+		filters.BucketDay:   {"%Y-%m-%d", "YYYY-MM-DD", "%Y-%m-%d"},
+		filters.BucketMonth: {"%Y-%m", "YYYY-MM", "%Y-%m"},
+		filters.BucketYear:  {"%Y", "YYYY", "%Y"},
+	}
+	f, ok := formats[bucket]
+	if !ok {
+		return "", fmt.Errorf("invalid group_by bucket: %s", bucket)
+	}
+	switch dialect {
+	case "mysql":
+		return "DATE_FORMAT(" + column + ", '" + f[0] + "')", nil
+	case "postgres":
+		return "to_char(" + column + ", '" + f[1] + "')", nil
+	case "sqlite3":
+		return "strftime('" + f[2] + "', " + column + ")", nil
+	}
+	return "", fmt.Errorf("group_by bucket is not supported by %s", dialect)
+}
+
+//执行 ApplyAggregate 生成的查询并读取为 map, 值按结果字段的类型转换:
+//count 为整数, sum、avg 见 sumType, min、max 与字段类型相同, 时间分段为字符串
+//mysql 文本协议返回字符串、预处理语句返回数字, 转换后输出的 json 类型相同
+func ScanAggregate(gorm *gorm.DB) ([]map[string]interface{}, error) {
+	rows, err := gorm.Rows()
+	if err != nil {
+		return nil, err
+	}
+	v, _ := gorm.Get(aggregateTypesSetting)
+	aliasTypes, _ := v.(map[string]reflect.Type)
+	return scanMaps(rows, aliasTypes)
+}
+
+//将查询结果读取为 map, []byte 转为字符串
+func ScanMaps(rows *sql.Rows) ([]map[string]interface{}, error) {
+	return scanMaps(rows, nil)
+}
+
+func scanMaps(rows *sql.Rows, columnTypes map[string]reflect.Type) ([]map[string]interface{}, error) {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			v, err := convertResultValue(columnTypes[column], values[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %v of %s: %s", values[i], column, err)
+			}
+			row[column] = v
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+//查询结果的值按Go类型转换, 类型未知时只将 []byte 转为字符串
+func convertResultValue(t reflect.Type, v interface{}) (interface{}, error) {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	if t == nil || v == nil {
+		return v, nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch n := v.(type) {
+	case string:
+		return convertFilterValue(t, n)
+	case int64:
+		switch {
+		case t == decimalType:
+			return types.ParseDecimal(strconv.FormatInt(n, 10))
+		case t.Kind() == reflect.Float64:
+			return float64(n), nil
+		}
+	case float64:
+		switch {
+		case t == decimalType:
+			return types.ParseDecimal(strconv.FormatFloat(n, 'f', -1, 64))
+		case t.Kind() == reflect.Int64 && n == math.Trunc(n):
+			return int64(n), nil
+		}
+	}
+	return v, nil
+}
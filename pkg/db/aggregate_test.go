@@ -0,0 +1,114 @@
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yimishiji/bee/pkg/filters"
+	"github.com/yimishiji/bee/pkg/types"
+)
+
+func TestApplyAggregateHiddenColumns(t *testing.T) {
+	HideColumns("test_member", "mobile")
+
+	tests := []struct {
+		groupBy   string
+		aggregate string
+		err       bool
+	}{
+		{groupBy: "nickname", aggregate: "count:*"},
+		{groupBy: "nickname", aggregate: "max:id"},
+		{groupBy: "nickname", aggregate: "min:password", err: true},
+		{groupBy: "nickname", aggregate: "count:api_token", err: true},
+		{groupBy: "password", aggregate: "count:*", err: true},
+		{groupBy: "mobile", aggregate: "count:*", err: true},
+		{groupBy: "unknown", aggregate: "count:*", err: true},
+	}
+	for _, tt := range tests {
+		groupBy, err := filters.ParseGroupBy(tt.groupBy)
+		if err != nil {
+			t.Fatalf("ParseGroupBy(%q) error: %v", tt.groupBy, err)
+		}
+		aggs, err := filters.ParseAggregates(tt.aggregate)
+		if err != nil {
+			t.Fatalf("ParseAggregates(%q) error: %v", tt.aggregate, err)
+		}
+		_, err = ApplyAggregate(testConn().Model(&testMember{}), groupBy, aggs, nil, nil)
+		if (err != nil) != tt.err {
+			t.Errorf("ApplyAggregate(%s, %s) error = %v, want error %v", tt.groupBy, tt.aggregate, err, tt.err)
+		}
+	}
+}
+
+type testStat struct {
+	Id        int
+	Amount    types.Decimal
+	Score     float64
+	Points    types.NullInt64
+	CreatedAt time.Time
+}
+
+func (testStat) TableName() string {
+	return "test_stat"
+}
+
+//mysql 文本协议返回 []byte, 预处理语句返回数字, 转换后的 json 相同
+func TestScanAggregateTypes(t *testing.T) {
+	aggs, err := filters.ParseAggregates("count:*,sum:amount,avg:amount,sum:points,avg:points,sum:score,max:points,min:created_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := ApplyAggregate(testConn().Model(&testStat{}), nil, aggs, nil, nil)
+	if err != nil {
+		t.Fatalf("ApplyAggregate error: %v", err)
+	}
+	v, _ := q.Get(aggregateTypesSetting)
+	aliasTypes := v.(map[string]reflect.Type)
+
+	created := time.Date(2019, 1, 2, 3, 4, 5, 0, time.Local)
+	tests := []struct {
+		alias  string
+		text   string
+		binary interface{}
+		want   string
+	}{
+		{"count", "12", int64(12), `12`},
+		{"sum_amount", "300.5", float64(300.5), `"300.5"`},
+		{"avg_amount", "12.25", float64(12.25), `"12.25"`},
+		{"sum_points", "30", int64(30), `30`},
+		{"sum_points", "30", float64(30), `30`},
+		{"avg_points", "7", int64(7), `7`},
+		{"sum_score", "1.5", float64(1.5), `1.5`},
+		{"max_points", "9", int64(9), `9`},
+		{"min_created_at", "2019-01-02 03:04:05", created, ""},
+	}
+	for _, tt := range tests {
+		fromText, err := convertResultValue(aliasTypes[tt.alias], []byte(tt.text))
+		if err != nil {
+			t.Errorf("%s from %q error: %v", tt.alias, tt.text, err)
+			continue
+		}
+		fromBinary, err := convertResultValue(aliasTypes[tt.alias], tt.binary)
+		if err != nil {
+			t.Errorf("%s from %v error: %v", tt.alias, tt.binary, err)
+			continue
+		}
+		textJson, _ := json.Marshal(fromText)
+		binaryJson, _ := json.Marshal(fromBinary)
+		if tt.want != "" && string(binaryJson) != tt.want {
+			t.Errorf("%s from %v = %s, want %s", tt.alias, tt.binary, binaryJson, tt.want)
+		}
+		if string(textJson) != string(binaryJson) {
+			t.Errorf("%s from %q = %s, from %v = %s", tt.alias, tt.text, textJson, tt.binary, binaryJson)
+		}
+	}
+
+	if v, _ := convertResultValue(nil, []byte("2019-01-02")); v != "2019-01-02" {
+		t.Errorf("untyped value = %#v, want string", v)
+	}
+	if v, _ := convertResultValue(aliasTypes["sum_amount"], nil); v != nil {
+		t.Errorf("null sum = %#v, want nil", v)
+	}
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/go-redis/redis"
+	"github.com/jinzhu/gorm"
+	"github.com/yimishiji/bee/pkg/filters"
+)
+
+const (
+	//记录不存在时缓存的标记, 防止不存在的 id 反复查库
+	cacheNotFound = "\x00not_found"
+	//不存在标记的最长缓存时间
+	cacheNotFoundTTL = 30 * time.Second
+)
+
+//模型的 redis 缓存, [cache] 中配置了表的缓存秒数时开启, 否则直接查库
+//	[cache]
+//	member = 300
+//按 id 的缓存在更新、删除时删除; 列表缓存的键带上表和关联表的标签版本, 表有写入时版本加1, 旧的列表缓存不再命中
+type ModelCache struct {
+	table string
+	tags  []string
+	once  sync.Once
+	ttl   time.Duration
+	calls callGroup
+}
+
+//table 为模型的表, tags 为列表查询会 join 的关联表, 关联表有写入时列表缓存同样失效
+func NewModelCache(table string, tags ...string) *ModelCache {
+	return &ModelCache{table: table, tags: append([]string{table}, tags...)}
+}
+
+//缓存时间, 未配置为0
+func (c *ModelCache) TTL() time.Duration {
+	c.once.Do(func() {
+		sec := beego.AppConfig.DefaultFloat("cache::"+c.table, 0)
+		c.ttl = time.Duration(sec * float64(time.Second))
+	})
+	return c.ttl
+}
+
+//是否开启缓存
+func (c *ModelCache) Enabled() bool {
+	return Redis != nil && c.TTL() > 0
+}
+
+//按 id 读缓存到 v, 未命中时调用 load 查库并写入缓存; load 返回记录不存在时短时间缓存不存在的结果
+//同一进程内同一个键同时只有一个 load 在执行, 其它请求等待其结果, 避免缓存失效时大量请求同时查库
+func (c *ModelCache) Get(id interface{}, v interface{}, load func() error) error {
+	return c.fetch(c.key(id), v, load)
+}
+
+//按列表参数读缓存到 v, 同 Get
+func (c *ModelCache) List(p *filters.PageCommonParams, v interface{}, load func() error) error {
+	if !c.Enabled() {
+		return load()
+	}
+	hash := p.Hash()
+	if hash == "" {
+		return load()
+	}
+	return c.fetch(fmt.Sprintf("cache:%s:list:%s:%s", c.table, c.tagVersions(), hash), v, load)
+}
+
+//删除 id 的缓存, 并使表的列表缓存失效; conn 在事务中时提交后再删除一次, 避免提交前读到旧数据又写回缓存
+func (c *ModelCache) Invalidate(conn *gorm.DB, ids ...interface{}) {
+	if !c.Enabled() {
+		return
+	}
+	c.invalidate(ids)
+	if conn != nil && InTransaction(conn) {
+		AfterCommit(conn, func() {
+			c.invalidate(ids)
+		})
+	}
+}
+
+func (c *ModelCache) invalidate(ids []interface{}) {
+	if len(ids) > 0 {
+		//逐个删除, cluster 模式下多个键可能不在同一个节点
+		Redis.Pipelined(func(pipe RedisPipeliner) error {
+			for _, id := range ids {
+				pipe.Del(c.key(id))
+			}
+			return nil
+		})
+	}
+	InvalidateCacheTags(c.table)
+}
+
+//使带有这些标签(表名)的列表缓存失效, 用于直接修改表数据后
+func InvalidateCacheTags(tags ...string) {
+	if Redis == nil {
+		return
+	}
+	for _, tag := range tags {
+		Redis.Incr(cacheTagKey(tag))
+	}
+}
+
+func cacheTagKey(tag string) string {
+	return "cache:tag:" + tag
+}
+
+func (c *ModelCache) key(id interface{}) string {
+	return fmt.Sprintf("cache:%s:%v", c.table, id)
+}
+
+//各标签的版本, 如 3.1; 用管道逐个读取, cluster 模式下标签可能不在同一个节点
+func (c *ModelCache) tagVersions() string {
+	cmds := make([]*redis.StringCmd, len(c.tags))
+	Redis.Pipelined(func(pipe RedisPipeliner) error {
+		for i, tag := range c.tags {
+			cmds[i] = pipe.Get(cacheTagKey(tag))
+		}
+		return nil
+	})
+	versions := make([]string, len(c.tags))
+	for i, cmd := range cmds {
+		versions[i] = "0"
+		if s, err := cmd.Result(); err == nil {
+			versions[i] = s
+		}
+	}
+	return strings.Join(versions, ".")
+}
+
+//缓存时间加上 10% 以内的随机值, 避免同时写入的缓存同时过期
+func (c *ModelCache) jitterTTL() time.Duration {
+	ttl := c.TTL()
+	if jitter := int64(ttl / 10); jitter > 0 {
+		ttl += time.Duration(rand.Int63n(jitter))
+	}
+	return ttl
+}
+
+func (c *ModelCache) fetch(key string, v interface{}, load func() error) error {
+	if !c.Enabled() {
+		return load()
+	}
+	if data, err := Redis.Get(key).Result(); err == nil {
+		if data == cacheNotFound {
+			return gorm.ErrRecordNotFound
+		}
+		if json.Unmarshal([]byte(data), v) == nil {
+			return nil
+		}
+	}
+
+	leader := false
+	data, err := c.calls.do(key, func() ([]byte, error) {
+		leader = true
+		if err := load(); err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				ttl := c.TTL()
+				if ttl > cacheNotFoundTTL {
+					ttl = cacheNotFoundTTL
+				}
+				Redis.Set(key, cacheNotFound, ttl)
+			}
+			return nil, err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, nil
+		}
+		Redis.Set(key, string(data), c.jitterTTL())
+		return data, nil
+	})
+	switch {
+	case leader || err != nil:
+		return err
+	case data == nil:
+		return load()
+	}
+	return json.Unmarshal(data, v)
+}
+
+//同一个键同时只执行一次的调用
+type callGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+func (g *callGroup) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call{}
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.data, c.err
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.data, c.err = fn()
+	return c.data, c.err
+}
+
+//关联表的表名, 用作列表缓存的标签
+func RelationTables(relations []Relation) []string {
+	tables := make([]string, 0, len(relations))
+	for _, rel := range relations {
+		tables = append(tables, rel.Table)
+	}
+	return tables
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/jinzhu/gorm"
+)
+
+//初次连接失败时重试的最长间隔
+const maxConnectBackoff = 30 * time.Second
+
+//数据库连接配置, 对应 [db] 或 [db.<name>]
+//driver: mysql(默认)、postgres、sqlite3, 驱动需在 main 中引入, 如 _ "github.com/jinzhu/gorm/dialects/postgres"
+//dsn: 完整的连接串, 配置后忽略 host、user 等
+//charset、timezone、tls(mysql)、sslmode(postgres): 常用连接参数
+//params: 其它连接参数, k=v&k=v
+type dbConfig struct {
+	section  string
+	driver   string
+	host     string
+	user     string
+	password string
+	database string
+}
+
+func loadDbConfig(section string) dbConfig {
+	driver := strings.ToLower(beego.AppConfig.DefaultString(section+"::driver", "mysql"))
+	switch driver {
+	case "sqlite":
+		driver = "sqlite3"
+	case "postgresql", "pg":
+		driver = "postgres"
+	}
+	return dbConfig{
+		section:  section,
+		driver:   driver,
+		host:     beego.AppConfig.String(section + "::host"),
+		user:     beego.AppConfig.String(section + "::user"),
+		password: beego.AppConfig.String(section + "::password"),
+		database: beego.AppConfig.String(section + "::database"),
+	}
+}
+
+func (c dbConfig) get(key string) string {
+	return beego.AppConfig.String(c.section + "::" + key)
+}
+
+//按驱动生成连接串, host、user、password 为空时使用配置中的值
+//指定 host 时(从库)不使用 dsn 配置
+func (c dbConfig) dsn(host, user, password string) (string, error) {
+	override := ""
+	if host == "" {
+		override = c.get("dsn")
+	}
+	if host == "" {
+		host = c.host
+	}
+	if user == "" {
+		user = c.user
+	}
+	if password == "" {
+		password = c.password
+	}
+
+	params := url.Values{}
+	if v := c.get("params"); v != "" {
+		parsed, err := url.ParseQuery(v)
+		if err != nil {
+			return "", fmt.Errorf("[%s] invalid params: %s", c.section, err)
+		}
+		params = parsed
+	}
+	setDefault := func(key, value string) {
+		if value != "" && params.Get(key) == "" {
+			params.Set(key, value)
+		}
+	}
+
+	switch c.driver {
+	case "mysql":
+		if override != "" {
+			return override, nil
+		}
+		setDefault("charset", beego.AppConfig.DefaultString(c.section+"::charset", "utf8"))
+		setDefault("parseTime", "true")
+		setDefault("loc", c.get("timezone"))
+		setDefault("tls", c.get("tls"))
+		if ms := queryTimeoutMillis(c.section); ms > 0 {
+			setDefault("max_execution_time", fmt.Sprint(ms))
+		}
+		return fmt.Sprintf("%s:%s@tcp(%s)/%s?%s", user, password, host, c.database, params.Encode()), nil
+
+	case "postgres":
+		if override != "" {
+			return override, nil
+		}
+		port := ""
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			host, port = h, p
+		}
+		setDefault("host", host)
+		setDefault("port", port)
+		setDefault("user", user)
+		setDefault("password", password)
+		setDefault("dbname", c.database)
+		setDefault("sslmode", beego.AppConfig.DefaultString(c.section+"::sslmode", "disable"))
+		setDefault("TimeZone", c.get("timezone"))
+		if ms := queryTimeoutMillis(c.section); ms > 0 {
+			setDefault("statement_timeout", fmt.Sprint(ms))
+		}
+		var pairs []string
+		for key := range params {
+			pairs = append(pairs, key+"="+pgQuote(params.Get(key)))
+		}
+		return strings.Join(pairs, " "), nil
+
+	case "sqlite3":
+		dsn := override
+		if dsn == "" {
+			dsn = c.database
+		}
+		if len(params) > 0 {
+			dsn += "?" + params.Encode()
+		}
+		return dsn, nil
+	}
+	return "", fmt.Errorf("[%s] unsupported driver: %s", c.section, c.driver)
+}
+
+//postgres 连接串中的值, 含空格或引号时加单引号
+func pgQuote(v string) string {
+	if v == "" || strings.ContainsAny(v, ` '\`) {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+	}
+	return v
+}
+
+//查询超时的毫秒数, 未配置为0
+func queryTimeoutMillis(section string) int64 {
+	sec, err := beego.AppConfig.Float(section + "::query_timeout")
+	if err != nil || sec <= 0 {
+		return 0
+	}
+	return int64(sec * 1000)
+}
+
+//连接数据库, retry 为 true 时失败按 connect_retries 次数重试, 间隔从 connect_retry_interval 秒开始翻倍
+//只在启动时重试, 请求中的连接失败立即返回, 不阻塞请求
+func (c dbConfig) open(dsn string, retry bool) (*gorm.DB, error) {
+	retries := 0
+	if retry {
+		retries = beego.AppConfig.DefaultInt(c.section+"::connect_retries", 3)
+	}
+	backoff := time.Duration(beego.AppConfig.DefaultFloat(c.section+"::connect_retry_interval", 1) * float64(time.Second))
+
+	for attempt := 0; ; attempt++ {
+		db, err := gorm.Open(c.driver, dsn)
+		if err == nil {
+			c.configurePool(db.DB())
+			applyQueryLog(db, "")
+			registerContextCallbacks(db)
+			return db, nil
+		}
+		if attempt >= retries {
+			return nil, err
+		}
+		beego.Warn(fmt.Sprintf("db [%s] connect failed, retry in %s: %s", c.section, backoff, err))
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+}
+
+//连接池设置: max_open_conns、max_idle_conns、conn_max_lifetime(秒)
+func (c dbConfig) configurePool(db *sql.DB) {
+	if db == nil {
+		return
+	}
+	if n, err := beego.AppConfig.Int(c.section + "::max_open_conns"); err == nil {
+		db.SetMaxOpenConns(n)
+	}
+	if n, err := beego.AppConfig.Int(c.section + "::max_idle_conns"); err == nil {
+		db.SetMaxIdleConns(n)
+	}
+	if sec, err := beego.AppConfig.Float(c.section + "::conn_max_lifetime"); err == nil {
+		db.SetConnMaxLifetime(time.Duration(sec * float64(time.Second)))
+	}
+}
+
+//各连接的连接池状态, 默认连接为 default, 从库为 <连接名>@<host>
+func PoolStats() map[string]sql.DBStats {
+	stats := map[string]sql.DBStats{}
+	if Conn != nil && Conn.DB() != nil {
+		stats["default"] = Conn.DB().Stats()
+	}
+
+	connsMu.Lock()
+	for name, db := range conns {
+		if db.DB() != nil {
+			stats[name] = db.DB().Stats()
+		}
+	}
+	connsMu.Unlock()
+
+	replicaSetsMu.RLock()
+	for name, set := range replicaSets {
+		if name == "" {
+			name = "default"
+		}
+		for _, r := range set.replicas {
+			r.RLock()
+			if r.db != nil && r.db.DB() != nil {
+				stats[name+"@"+r.host] = r.db.DB().Stats()
+			}
+			r.RUnlock()
+		}
+	}
+	replicaSetsMu.RUnlock()
+	return stats
+}
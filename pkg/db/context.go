@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+//查询使用的 ctx 保存在连接设置中
+const contextSetting = "bee:context"
+
+//返回使用 ctx 的连接, ctx 取消(如客户端断开)或超时后, 之后的查询、写入直接返回 ctx 的错误
+//ctx 为空时返回 conn; 已有的查询条件、回调和连接设置都保留, 可在任意位置调用
+//执行中的查询由连接配置的 query_timeout(mysql max_execution_time、postgres statement_timeout)在服务端中止
+//开启sql日志时记录 ctx 中的请求id, 见 WithRequestId
+func WithContext(ctx context.Context, conn *gorm.DB) *gorm.DB {
+	if ctx == nil || conn == nil {
+		return conn
+	}
+	db := conn.Set(contextSetting, ctx)
+	if id := RequestIdFromContext(ctx); id != "" {
+		if conf := loadQueryLogConfig(); conf.slow {
+			db.SetLogger(&queryLogger{conf: conf, requestId: id})
+		}
+	}
+	return db
+}
+
+//取连接使用的 ctx, 没有时返回 nil
+func ContextFromConn(conn *gorm.DB) context.Context {
+	if conn == nil {
+		return nil
+	}
+	ctx, _ := conn.Get(contextSetting)
+	c, _ := ctx.(context.Context)
+	return c
+}
+
+//连接建立时注册一次, 查询、写入前检查连接设置中的 ctx
+func registerContextCallbacks(db *gorm.DB) {
+	callback := db.Callback()
+	callback.Query().Before("gorm:query").Register("bee:context", checkContext)
+	callback.RowQuery().Before("gorm:row_query").Register("bee:context", checkRowContext)
+	callback.Create().Before("gorm:begin_transaction").Register("bee:context", checkContext)
+	callback.Update().Before("gorm:begin_transaction").Register("bee:context", checkContext)
+	callback.Delete().Before("gorm:begin_transaction").Register("bee:context", checkContext)
+}
+
+//ctx 已取消或超时时中止, 错误为 ctx 的错误
+func checkContext(scope *gorm.Scope) {
+	if ctx := ContextFromConn(scope.DB()); ctx != nil && ctx.Err() != nil {
+		scope.Err(ctx.Err())
+	}
+}
+
+//Row、Rows、Count、Pluck 的查询, gorm:row_query 不检查错误, ctx 已结束时替换查询结果使其不执行
+func checkRowContext(scope *gorm.Scope) {
+	ctx := ContextFromConn(scope.DB())
+	if ctx == nil || ctx.Err() == nil {
+		return
+	}
+	err := scope.Err(ctx.Err())
+	if result, ok := scope.InstanceGet("row_query_result"); ok {
+		switch r := result.(type) {
+		case *gorm.RowQueryResult:
+			r.Row = errRow(err)
+		case *gorm.RowsQueryResult:
+			r.Error = err
+		}
+		scope.InstanceSet("row_query_result", nil)
+	}
+}
+
+//Scan 时返回 err 的 *sql.Row
+func errRow(err error) *sql.Row {
+	db := sql.OpenDB(unavailableConnector{err})
+	defer db.Close()
+	return db.QueryRow("")
+}
+
+//[db] 或 [db.<name>] 中配置的查询超时, query_timeout 秒数, 未配置为0
+func QueryTimeout(name string) time.Duration {
+	section := "db"
+	if name != "" && name != "default" {
+		section = "db." + name
+	}
+	return time.Duration(queryTimeoutMillis(section)) * time.Millisecond
+}
+
+//为 ctx 加上连接配置的查询超时, 未配置时只可取消
+func TimeoutContext(ctx context.Context, name string) (context.Context, context.CancelFunc) {
+	if timeout := QueryTimeout(name); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+//查询因 ctx 取消(如客户端断开)而中止
+func IsCanceled(err error) bool {
+	return err != nil && (err == context.Canceled || strings.Contains(err.Error(), context.Canceled.Error()))
+}
+
+//查询超时, 包括 ctx 超时和 mysql max_execution_time 中止的查询
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	return err == context.DeadlineExceeded ||
+		strings.Contains(err.Error(), context.DeadlineExceeded.Error()) ||
+		strings.Contains(err.Error(), "maximum statement execution time exceeded")
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func TestWithContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	conn := WithContext(ctx, testConn())
+	if err := conn.Find(&[]testOrder{}).Error; err != context.Canceled {
+		t.Errorf("Find with canceled ctx err = %v, want %v", err, context.Canceled)
+	}
+	if err := conn.Model(&testOrder{}).Count(new(int)).Error; err != context.Canceled {
+		t.Errorf("Count with canceled ctx err = %v, want %v", err, context.Canceled)
+	}
+	if err := conn.Create(&testOrder{Title: "a"}).Error; err != context.Canceled {
+		t.Errorf("Create with canceled ctx err = %v, want %v", err, context.Canceled)
+	}
+	if err := TransactionOn(conn, func(tx *gorm.DB) error { return nil }); err != context.Canceled {
+		t.Errorf("TransactionOn with canceled ctx err = %v, want %v", err, context.Canceled)
+	}
+	if !IsCanceled(conn.First(&testOrder{}).Error) {
+		t.Errorf("IsCanceled want true")
+	}
+}
+
+func TestWithContextKeepsConn(t *testing.T) {
+	ctx := context.WithValue(context.Background(), requestIdKey{}, "r1")
+	conn := testConn()
+	called := false
+	conn.Callback().Query().Before("gorm:query").Register("test:called", func(*gorm.Scope) {
+		called = true
+	})
+
+	q := WithContext(ctx, conn.Where("title = ?", "a"))
+	if ContextFromConn(q) != ctx || q.CommonDB() != conn.CommonDB() {
+		t.Fatalf("WithContext doesn't carry ctx on the same connection")
+	}
+	sql, args := querySQL(q)
+	if !strings.Contains(sql, "WHERE (title = ?)") || len(args) != 1 {
+		t.Errorf("WithContext drops conditions: %q %v", sql, args)
+	}
+	if !called {
+		t.Errorf("WithContext drops callbacks registered on the connection")
+	}
+	if WithContext(nil, conn) != conn || ContextFromConn(conn) != nil {
+		t.Errorf("WithContext(nil) want conn unchanged")
+	}
+}
@@ -0,0 +1,309 @@
+package db
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/logs"
+	"github.com/jinzhu/gorm"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+//去掉字段名的引号
+var identQuotes = strings.NewReplacer("`", "", `"`, "")
+
+//游标签名的密钥, 取 [db] cursor_secret, 未配置时为进程启动时生成的随机值并记录警告
+var (
+	cursorSecret     []byte
+	cursorSecretOnce sync.Once
+)
+
+//设置游标签名的密钥, 多个节点需使用相同的密钥, 否则其它节点生成的游标不可用
+func SetCursorSecret(secret []byte) {
+	cursorSecretOnce.Do(func() {})
+	cursorSecret = secret
+}
+
+func cursorKey() []byte {
+	cursorSecretOnce.Do(func() {
+		if s := beego.AppConfig.String("db::cursor_secret"); s != "" {
+			cursorSecret = []byte(s)
+			return
+		}
+		logs.Warn("db: [db] cursor_secret is not set, cursors are signed with a random key and become invalid after a restart or on other nodes")
+		cursorSecret = make([]byte, 32)
+		rand.Read(cursorSecret)
+	})
+	return cursorSecret
+}
+
+func cursorSign(b []byte) []byte {
+	mac := hmac.New(sha256.New, cursorKey())
+	mac.Write(b)
+	return mac.Sum(nil)
+}
+
+//游标分页的排序字段, nullable 的字段排序时 NULL 视为最小值: 升序在前, 降序在后
+type keysetColumn struct {
+	name     string
+	desc     bool
+	nullable bool
+}
+
+//游标内容, 编码为 base64(json).base64(签名), 签名不符的游标不可用
+type cursorPayload struct {
+	Order  string        `json:"o"`
+	Values []interface{} `json:"v"`
+}
+
+//游标分页: 按排序字段加主键排序, cursor 不为空时只取游标之后的记录
+//gorm 需已通过 Model() 指定模型, sortFields 写法同 PageCommonParams.SortFields, 如 -created_at
+func ApplyKeyset(gorm *gorm.DB, sortFields []string, cursor string) (*gorm.DB, error) {
+	scope := gorm.NewScope(gorm.Value)
+	columns, err := keysetColumns(scope, sortFields)
+	if err != nil {
+		return gorm, err
+	}
+
+	table := scope.Quote(scope.TableName())
+	for _, col := range columns {
+		column := table + "." + scope.Quote(col.name)
+		//mysql 与 postgres 中 NULL 的默认位置不同, 先按是否为 NULL 排序
+		switch {
+		case col.nullable && col.desc:
+			gorm = gorm.Order(column + " IS NULL").Order(column + " DESC")
+		case col.nullable:
+			gorm = gorm.Order(column + " IS NULL DESC").Order(column)
+		case col.desc:
+			gorm = gorm.Order(column + " DESC")
+		default:
+			gorm = gorm.Order(column)
+		}
+	}
+	if cursor == "" {
+		return gorm, nil
+	}
+
+	values, err := decodeCursor(cursor, columns)
+	if err != nil {
+		return gorm, err
+	}
+	columnTypes := modelColumnTypes(gorm)
+	for i, col := range columns {
+		if values[i], err = convertFilterValue(columnTypes[col.name], values[i]); err != nil {
+			return gorm, errInvalidCursor
+		}
+	}
+
+	//(a > ?) OR (a = ? AND b > ?) OR (a = ? AND b = ? AND id > ?)
+	var parts []string
+	var args []interface{}
+	for i, col := range columns {
+		var conds []string
+		for j := 0; j < i; j++ {
+			cond, condArgs := keysetEqual(table+"."+scope.Quote(columns[j].name), values[j])
+			conds = append(conds, cond)
+			args = append(args, condArgs...)
+		}
+		cond, condArgs := keysetAfter(table+"."+scope.Quote(col.name), col.desc, values[i])
+		if cond == "" {
+			continue
+		}
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+		parts = append(parts, "("+strings.Join(conds, " AND ")+")")
+	}
+	if len(parts) == 0 {
+		return gorm.Where("1 = 0"), nil
+	}
+	return gorm.Where(strings.Join(parts, " OR "), args...), nil
+}
+
+//字段等于游标中的值, 值为 NULL 时为 IS NULL
+func keysetEqual(column string, v interface{}) (string, []interface{}) {
+	if v == nil {
+		return column + " IS NULL", nil
+	}
+	return column + " = ?", []interface{}{v}
+}
+
+//字段排在游标中的值之后, NULL 视为最小值; 没有记录在其后时返回空
+func keysetAfter(column string, desc bool, v interface{}) (string, []interface{}) {
+	switch {
+	case v == nil && desc:
+		return "", nil
+	case v == nil:
+		return column + " IS NOT NULL", nil
+	case desc:
+		return "(" + column + " < ? OR " + column + " IS NULL)", []interface{}{v}
+	}
+	return column + " > ?", []interface{}{v}
+}
+
+//以一页的最后一条记录生成下一页的游标, sortFields 需与 ApplyKeyset 相同
+func NextCursor(gorm *gorm.DB, row interface{}, sortFields []string) (string, error) {
+	scope := gorm.NewScope(row)
+	columns, err := keysetColumns(scope, sortFields)
+	if err != nil {
+		return "", err
+	}
+
+	payload := cursorPayload{Order: keysetOrder(columns)}
+	for _, col := range columns {
+		field, ok := scope.FieldByName(col.name)
+		if !ok {
+			return "", fmt.Errorf("invalid sort field for cursor: %s", col.name)
+		}
+		v := field.Field.Interface()
+		if valuer, ok := v.(driver.Valuer); ok {
+			if v, err = valuer.Value(); err != nil {
+				return "", err
+			}
+		}
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		payload.Values = append(payload.Values, v)
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b) + "." + base64.RawURLEncoding.EncodeToString(cursorSign(b)), nil
+}
+
+//select 的字段加上游标分页用到的排序字段和主键, 下一页的游标由这些字段的值生成
+//fields 为空时查询全部字段, 原样返回
+func KeysetFields(gorm *gorm.DB, fields, sortFields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return fields, nil
+	}
+	scope := gorm.NewScope(gorm.Value)
+	columns, err := keysetColumns(scope, sortFields)
+	if err != nil {
+		return nil, err
+	}
+
+	table := scope.TableName()
+	selected := map[string]bool{}
+	for _, field := range fields {
+		field = strings.TrimSpace(identQuotes.Replace(field))
+		selected[strings.TrimPrefix(field, table+".")] = true
+	}
+	result := append([]string{}, fields...)
+	for _, col := range columns {
+		if !selected[col.name] {
+			result = append(result, scope.Quote(table)+"."+scope.Quote(col.name))
+		}
+	}
+	return result, nil
+}
+
+//排序字段加主键, 只允许模型中的字段, 隐藏字段不可用
+func keysetColumns(scope *gorm.Scope, sortFields []string) ([]keysetColumn, error) {
+	fields := map[string]reflect.Type{}
+	table := scope.TableName()
+	for _, field := range scope.GetModelStruct().StructFields {
+		if field.IsNormal && field.DBName != "" && !IsHiddenColumn(table, field.DBName) {
+			fields[field.DBName] = field.Struct.Type
+		}
+	}
+
+	pk := scope.PrimaryKey()
+	if pk == "" {
+		return nil, errors.New("cursor pagination needs a primary key")
+	}
+
+	var columns []keysetColumn
+	hasPk := false
+	for _, v := range sortFields {
+		col := keysetColumn{name: strings.TrimPrefix(v, "-"), desc: strings.HasPrefix(v, "-")}
+		t, ok := fields[col.name]
+		if !ok {
+			return nil, fmt.Errorf("invalid sort field for cursor: %s", col.name)
+		}
+		col.nullable = col.name != pk && isNullableType(t)
+		columns = append(columns, col)
+		if col.name == pk {
+			hasPk = true
+			break
+		}
+	}
+	if !hasPk {
+		columns = append(columns, keysetColumn{name: pk})
+	}
+	return columns, nil
+}
+
+//指针和带 Valid 字段的类型可为 NULL, 如 *string、types.NullString、sql.NullInt64
+func isNullableType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		return true
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	valid, ok := t.FieldByName("Valid")
+	return ok && valid.Type.Kind() == reflect.Bool
+}
+
+func keysetOrder(columns []keysetColumn) string {
+	var order []string
+	for _, col := range columns {
+		if col.desc {
+			order = append(order, "-"+col.name)
+		} else {
+			order = append(order, col.name)
+		}
+	}
+	return strings.Join(order, ",")
+}
+
+//解码游标, 排序方式与生成游标时不同则不可用
+func decodeCursor(cursor string, columns []keysetColumn) ([]interface{}, error) {
+	parts := strings.Split(cursor, ".")
+	if len(parts) != 2 {
+		return nil, errInvalidCursor
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, cursorSign(b)) {
+		return nil, errInvalidCursor
+	}
+	var payload cursorPayload
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, errInvalidCursor
+	}
+	if payload.Order != keysetOrder(columns) || len(payload.Values) != len(columns) {
+		return nil, errors.New("cursor does not match the sort order")
+	}
+
+	for i, v := range payload.Values {
+		switch v := v.(type) {
+		case json.Number:
+			payload.Values[i] = v.String()
+		case string, bool, nil:
+		default:
+			return nil, errInvalidCursor
+		}
+	}
+	return payload.Values, nil
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+type testOrder struct {
+	Id        int
+	Title     string
+	Amount    int
+	Password  string
+	Note      *string
+	CreatedAt string
+}
+
+func (testOrder) TableName() string {
+	return "test_order"
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	SetCursorSecret([]byte("test-secret"))
+	row := &testOrder{Id: 7, Title: "a", Amount: 30, CreatedAt: "2019-01-02 03:04:05"}
+
+	tests := []struct {
+		sort []string
+		want []interface{}
+	}{
+		{sort: nil, want: []interface{}{"7"}},
+		{sort: []string{"-created_at"}, want: []interface{}{"2019-01-02 03:04:05", "7"}},
+		{sort: []string{"amount", "-title"}, want: []interface{}{"30", "a", "7"}},
+		{sort: []string{"-id", "title"}, want: []interface{}{"7"}},
+	}
+	for _, tt := range tests {
+		cursor, err := NextCursor(testConn(), row, tt.sort)
+		if err != nil {
+			t.Errorf("NextCursor(%v) error: %v", tt.sort, err)
+			continue
+		}
+		columns, _ := keysetColumns(testConn().NewScope(&testOrder{}), tt.sort)
+		values, err := decodeCursor(cursor, columns)
+		if err != nil {
+			t.Errorf("decodeCursor(%v) error: %v", tt.sort, err)
+			continue
+		}
+		if !reflect.DeepEqual(values, tt.want) {
+			t.Errorf("cursor values for %v = %v, want %v", tt.sort, values, tt.want)
+		}
+		if _, err := ApplyKeyset(testConn().Model(&testOrder{}), tt.sort, cursor); err != nil {
+			t.Errorf("ApplyKeyset(%v) error: %v", tt.sort, err)
+		}
+	}
+}
+
+func TestCursorRejected(t *testing.T) {
+	SetCursorSecret([]byte("test-secret"))
+	row := &testOrder{Id: 7, Amount: 30}
+	cursor, err := NextCursor(testConn(), row, []string{"amount"})
+	if err != nil {
+		t.Fatalf("NextCursor error: %v", err)
+	}
+
+	parts := strings.Split(cursor, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"o":"amount,id","v":[1,1]}`))
+	tests := []struct {
+		name   string
+		sort   []string
+		cursor string
+	}{
+		{"unsigned", []string{"amount"}, parts[0]},
+		{"forged", []string{"amount"}, forged + "." + parts[1]},
+		{"bad signature", []string{"amount"}, parts[0] + ".AAAA"},
+		{"not base64", []string{"amount"}, "!!.!!"},
+		{"other sort", []string{"-amount"}, cursor},
+		{"empty", []string{"amount"}, "."},
+	}
+	for _, tt := range tests {
+		if _, err := ApplyKeyset(testConn().Model(&testOrder{}), tt.sort, tt.cursor); err == nil {
+			t.Errorf("ApplyKeyset with %s cursor want error", tt.name)
+		}
+	}
+
+	//更换密钥后原来的游标不可用
+	SetCursorSecret([]byte("other-secret"))
+	if _, err := ApplyKeyset(testConn().Model(&testOrder{}), []string{"amount"}, cursor); err == nil {
+		t.Errorf("ApplyKeyset with a cursor signed by another secret want error")
+	}
+}
+
+func TestCursorHiddenColumns(t *testing.T) {
+	SetCursorSecret([]byte("test-secret"))
+	row := &testOrder{Id: 7, Password: "hash"}
+	if _, err := NextCursor(testConn(), row, []string{"password"}); err == nil {
+		t.Errorf("NextCursor sorted by password want error")
+	}
+	if _, err := ApplyKeyset(testConn().Model(&testOrder{}), []string{"-password"}, ""); err == nil {
+		t.Errorf("ApplyKeyset sorted by password want error")
+	}
+	if _, err := ApplyKeyset(testConn().Model(&testOrder{}), []string{"unknown"}, ""); err == nil {
+		t.Errorf("ApplyKeyset sorted by unknown field want error")
+	}
+}
+
+func TestKeysetFields(t *testing.T) {
+	tests := []struct {
+		fields []string
+		sort   []string
+		want   []string
+	}{
+		{fields: nil, sort: []string{"amount"}, want: nil},
+		{fields: []string{"title"}, sort: nil, want: []string{"title", "`test_order`.`id`"}},
+		{fields: []string{"title"}, sort: []string{"-amount"}, want: []string{"title", "`test_order`.`amount`", "`test_order`.`id`"}},
+		{fields: []string{"id", "test_order.amount"}, sort: []string{"amount"}, want: []string{"id", "test_order.amount"}},
+		{fields: []string{"`id`", "title"}, sort: nil, want: []string{"`id`", "title"}},
+	}
+	for _, tt := range tests {
+		got, err := KeysetFields(testConn().Model(&testOrder{}), tt.fields, tt.sort)
+		if err != nil {
+			t.Errorf("KeysetFields(%v, %v) error: %v", tt.fields, tt.sort, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("KeysetFields(%v, %v) = %q, want %q", tt.fields, tt.sort, got, tt.want)
+		}
+	}
+}
+
+//查询的sql和参数, 测试连接不可用, 在 gorm:query 之后取出生成的sql
+func querySQL(q *gorm.DB) (string, []interface{}) {
+	var sql string
+	var args []interface{}
+	q.Callback().Query().After("gorm:query").Register("test:capture_sql", func(scope *gorm.Scope) {
+		sql, args = scope.SQL, scope.SQLVars
+	})
+	q.Find(&[]testOrder{})
+	return sql, args
+}
+
+func TestCursorNullSortValue(t *testing.T) {
+	SetCursorSecret([]byte("test-secret"))
+	note := "a"
+	tests := []struct {
+		sort      []string
+		note      *string
+		wantOrder string
+		wantWhere string
+		wantArgs  int
+	}{
+		{[]string{"note"}, nil, "`test_order`.`note` IS NULL DESC,`test_order`.`note`,`test_order`.`id`",
+			"((`test_order`.`note` IS NOT NULL) OR (`test_order`.`note` IS NULL AND `test_order`.`id` > ?))", 1},
+		{[]string{"-note"}, nil, "`test_order`.`note` IS NULL,`test_order`.`note` DESC,`test_order`.`id`",
+			"((`test_order`.`note` IS NULL AND `test_order`.`id` > ?))", 1},
+		{[]string{"-note"}, &note, "",
+			"(((`test_order`.`note` < ? OR `test_order`.`note` IS NULL)) OR (`test_order`.`note` = ? AND `test_order`.`id` > ?))", 3},
+		{[]string{"note"}, &note, "",
+			"((`test_order`.`note` > ?) OR (`test_order`.`note` = ? AND `test_order`.`id` > ?))", 3},
+	}
+	for _, tt := range tests {
+		cursor, err := NextCursor(testConn(), &testOrder{Id: 7, Note: tt.note}, tt.sort)
+		if err != nil {
+			t.Errorf("NextCursor(%v) error: %v", tt.sort, err)
+			continue
+		}
+		q, err := ApplyKeyset(testConn().Model(&testOrder{}), tt.sort, cursor)
+		if err != nil {
+			t.Errorf("ApplyKeyset(%v) error: %v", tt.sort, err)
+			continue
+		}
+		sql, args := querySQL(q)
+		if tt.wantOrder != "" && !strings.Contains(sql, "ORDER BY "+tt.wantOrder) {
+			t.Errorf("ApplyKeyset(%v) sql %q, want ORDER BY %s", tt.sort, sql, tt.wantOrder)
+		}
+		if !strings.Contains(sql, "WHERE "+tt.wantWhere) || len(args) != tt.wantArgs {
+			t.Errorf("ApplyKeyset(%v) sql %q args %v, want WHERE %s with %d args", tt.sort, sql, args, tt.wantWhere, tt.wantArgs)
+		}
+	}
+}
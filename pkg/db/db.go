@@ -1,74 +1,270 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 
 	"reflect"
-	"strconv"
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/astaxie/beego"
 	"github.com/jinzhu/gorm"
+	"github.com/yimishiji/bee/pkg/filters"
 )
 
 var (
 	Conn *gorm.DB
+
+	//命名连接, 对应配置中的 [db.<name>]
+	conns   = map[string]*gorm.DB{}
+	connsMu sync.Mutex
+	//正在连接的命名连接, 同名的并发调用等待同一次连接
+	connecting = map[string]*pendingConn{}
 )
 
+//连接名, 保存在连接的设置中, 用于区分 ctx 中的事务
+const connectionSetting = "bee:connection"
+
+//一次进行中的连接
+type pendingConn struct {
+	done chan struct{}
+	db   *gorm.DB
+	err  error
+}
+
+//连接 [db] 配置的默认数据库
 func GetDbConnect() (*gorm.DB, error) {
-	dbConStr := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8&parseTime=true", beego.AppConfig.String("db::user"), beego.AppConfig.String("db::password"), beego.AppConfig.String("db::host"), beego.AppConfig.String("db::database"))
-	db, err := gorm.Open("mysql", dbConStr)
+	db, err := openDb("db", true)
+	if err == nil {
+		db = withConnName(db, "")
+		openReplicas("db", "")
+		//启动时读取游标密钥, 未配置时在这里记录警告
+		cursorKey()
+	}
 	Conn = db
 	return db, err
 }
 
+//连接 [db.<name>] 配置的数据库, 之后可通过 Use(name) 获取
+func GetNamedDbConnect(name string) (*gorm.DB, error) {
+	if name == "" || name == "default" {
+		return GetDbConnect()
+	}
+
+	db, err := openDb("db."+name, true)
+	if err != nil {
+		return nil, err
+	}
+	db = withConnName(db, name)
+
+	connsMu.Lock()
+	if old, ok := conns[name]; ok {
+		old.Close()
+	}
+	conns[name] = db
+	connsMu.Unlock()
+	openReplicas("db."+name, name)
+
+	return db, nil
+}
+
+//返回命名连接, 空名称或 default 返回默认连接 Conn
+//未连接过的命名连接会按 [db.<name>] 配置连接; 连接失败时返回的连接上所有查询都返回该错误, 下次调用重新连接
+func Use(name string) *gorm.DB {
+	db, err := Open(name)
+	if err != nil {
+		return unavailableDb(loadDbConfig("db."+name).driver, err)
+	}
+	return db
+}
+
+//返回命名连接, 未连接过时按 [db.<name>] 配置连接, 失败立即返回错误, 不重试
+//连接在锁外进行, 不阻塞其它连接的获取
+func Open(name string) (*gorm.DB, error) {
+	if name == "" || name == "default" {
+		if Conn == nil {
+			return nil, errors.New("db: default connection is not open")
+		}
+		return Conn, nil
+	}
+
+	connsMu.Lock()
+	if db, ok := conns[name]; ok {
+		connsMu.Unlock()
+		return db, nil
+	}
+	if p, ok := connecting[name]; ok {
+		connsMu.Unlock()
+		<-p.done
+		return p.db, p.err
+	}
+	p := &pendingConn{done: make(chan struct{})}
+	connecting[name] = p
+	connsMu.Unlock()
+
+	p.db, p.err = openDb("db."+name, false)
+	if p.err == nil {
+		p.db = withConnName(p.db, name)
+	}
+
+	stored := false
+	connsMu.Lock()
+	delete(connecting, name)
+	if p.err == nil {
+		if db, ok := conns[name]; ok {
+			//等待期间已由 GetNamedDbConnect 连接
+			p.db.Close()
+			p.db = db
+		} else {
+			conns[name] = p.db
+			stored = true
+		}
+	}
+	connsMu.Unlock()
+	close(p.done)
+
+	if p.err != nil {
+		return nil, fmt.Errorf("db connection %q: %s", name, p.err)
+	}
+	if stored {
+		openReplicas("db."+name, name)
+	}
+	return p.db, nil
+}
+
+//连接名, 空名称为 default
+func connName(name string) string {
+	if name == "" {
+		return "default"
+	}
+	return name
+}
+
+//给连接记录连接名, 之后的查询和事务都带有该设置
+func withConnName(db *gorm.DB, name string) *gorm.DB {
+	return db.Set(connectionSetting, connName(name))
+}
+
+//conn 所属的连接名, 默认连接为 default, 不是通过 GetDbConnect、Use 等得到的连接为空
+func ConnName(conn *gorm.DB) string {
+	if conn == nil {
+		return ""
+	}
+	if v, ok := conn.Get(connectionSetting); ok {
+		name, _ := v.(string)
+		return name
+	}
+	return ""
+}
+
+//关闭所有数据库连接
+func CloseAll() {
+	closeReplicas()
+
+	connsMu.Lock()
+	defer connsMu.Unlock()
+	for name, db := range conns {
+		db.Close()
+		delete(conns, name)
+	}
+	if Conn != nil {
+		Conn.Close()
+	}
+}
+
+//连接失败时返回的连接, 底层的 sql.DB 每次取连接都返回 err, 查询、事务都返回错误而不会访问其它数据库
+func unavailableDb(driverName string, err error) *gorm.DB {
+	sqlDb := sql.OpenDB(unavailableConnector{err})
+	db, _ := gorm.Open(driverName, sqlDb)
+	registerContextCallbacks(db)
+	db.Error = err
+	return db
+}
+
+type unavailableConnector struct {
+	err error
+}
+
+func (c unavailableConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func (c unavailableConnector) Driver() driver.Driver {
+	return unavailableDriver{c.err}
+}
+
+type unavailableDriver struct {
+	err error
+}
+
+func (d unavailableDriver) Open(string) (driver.Conn, error) {
+	return nil, d.err
+}
+
+//按配置段连接数据库, 配置项见 dbConfig; retry 见 dbConfig.open
+func openDb(section string, retry bool) (*gorm.DB, error) {
+	if section != "db" {
+		if _, err := beego.AppConfig.GetSection(strings.ToLower(section)); err != nil {
+			return nil, fmt.Errorf("config section [%s] not found", section)
+		}
+	}
+	cfg := loadDbConfig(section)
+	dsn, err := cfg.dsn("", "", "")
+	if err != nil {
+		return nil, err
+	}
+	return cfg.open(dsn, retry)
+}
+
 //过滤条件
 func NewGormQuery(query map[string]string) *gorm.DB {
-	gorm := Conn
-	//过滤条件
-	for k, v := range query {
-		if strings.Contains(k, "-isnull") {
-			k = strings.Replace(k, "-isnull", "", 1)
-			gorm = gorm.Where(k + " isnull")
-		} else if strings.HasPrefix(v, ">=") {
-			v = strings.Replace(v, ">=", "", 1)
-			gorm = gorm.Where(k+" >= ?", v)
-		} else if strings.HasPrefix(v, "<=") {
-			v = strings.Replace(v, "<=", "", 1)
-			gorm = gorm.Where(k+" <= ?", v)
-		} else if strings.HasPrefix(v, ">") {
-			v = strings.Replace(v, ">", "", 1)
-			gorm = gorm.Where(k+" > ?", v)
-		} else if strings.HasPrefix(v, "<") {
-			v = strings.Replace(v, "<", "", 1)
-			gorm = gorm.Where(k+" < ?", v)
-		} else if strings.HasPrefix(v, "!=") {
-			v = strings.Replace(v, "!=", "", 1)
-			gorm = gorm.Where(k+" != ?", v)
-		} else if strings.HasPrefix(v, "<>") {
-			v = strings.Replace(v, "<>", "", 1)
-			gorm = gorm.Where(k+" <> ?", v)
-		} else if strings.HasPrefix(v, "like-") {
-			v = strings.Replace(v, "like-", "", 1)
-			gorm = gorm.Where(k+" LIKE ?", "%"+v+"%")
-		} else if strings.HasPrefix(v, "between-") {
-			v = strings.Replace(v, "between-", "", 1)
-			ranges := strings.SplitN(v, "-", 2)
-			if len(ranges) == 2 {
-				star, _ := strconv.Atoi(ranges[0])
-				end, _ := strconv.Atoi(ranges[1])
-				gorm = gorm.Where(k+" BETWEEN ? AND ?", star, end)
-			}
-		} else if strings.HasPrefix(v, "in-") {
-			v = strings.Replace(v, "in-", "", 1)
-			ranges := strings.SplitN(v, "-", -1)
-			gorm = gorm.Where(k+" in (?)", ranges)
+	return NewConnGormQuery(Conn, query)
+}
+
+//在指定连接上构建过滤条件, 如 db.NewConnGormQuery(db.Use("report"), query)
+//query 为 k:v 形式的旧写法, 转为条件树后与 filter 参数使用相同的方式编译
+func NewConnGormQuery(conn *gorm.DB, query map[string]string) *gorm.DB {
+	return ApplyFilter(conn, filters.QueryCondition(query))
+}
+
+var (
+	jsonColumnRegex  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	jsonPathRegex    = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)*$`)
+	jsonPathIdxRegex = regexp.MustCompile(`^[0-9]+$`)
+)
+
+//json字段路径转为sql表达式, column->a.b.0
+//mysql: JSON_UNQUOTE(JSON_EXTRACT(column, '$.a.b[0]'))
+//postgres: column #>> '{a,b,0}'
+func JsonPathExpr(dialect string, key string) (string, error) {
+	kv := strings.SplitN(key, "->", 2)
+	if len(kv) != 2 || !jsonColumnRegex.MatchString(kv[0]) || !jsonPathRegex.MatchString(kv[1]) {
+		return "", fmt.Errorf("invalid json path query key: %s", key)
+	}
+	return jsonExtract(dialect, kv[0], kv[1]), nil
+}
+
+//取json字段中的值, column 为已校验的字段表达式, path 如 address.city
+func jsonExtract(dialect, column, path string) string {
+	segments := strings.Split(path, ".")
+	if dialect == "postgres" {
+		return fmt.Sprintf("%s #>> '{%s}'", column, strings.Join(segments, ","))
+	}
+
+	jsonPath := "$"
+	for _, seg := range segments {
+		if jsonPathIdxRegex.MatchString(seg) {
+			jsonPath += "[" + seg + "]"
 		} else {
-			gorm = gorm.Where(k+" = ?", v)
+			jsonPath += "." + seg
 		}
 	}
-
-	return gorm
+	return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, '%s'))", column, jsonPath)
 }
 
 //过滤字段，实现select功能
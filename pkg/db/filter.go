@@ -0,0 +1,306 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/yimishiji/bee/pkg/filters"
+	"github.com/yimishiji/bee/pkg/types"
+)
+
+//字段名, 可带表名 member.mobile
+var fieldRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+//like 值中的通配符转义
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+//字符串转时间的格式
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Operator 筛选操作符, 通过 RegisterOperator 注册后可在 filter= 和 query= 中使用
+type Operator struct {
+	filters.OperatorSpec
+	// Build 生成sql条件, column 为校验后的字段表达式, 值按 Arity 传入:
+	// ArityNone 为空, ArityOne 一个, ArityList 一个或多个, ArityPair 两个
+	Build func(column string, values []interface{}) (string, []interface{}, error)
+	// Raw 为 true 时值不按字段的Go类型转换, 如 near 的经纬度、json-contains 的json
+	Raw bool
+}
+
+func init() {
+	//内置操作符的写法在 filters 中定义, 这里加上生成 sql 的实现
+	builtin := func(name string, raw bool, build func(column string, values []interface{}) (string, []interface{}, error)) {
+		spec, ok := filters.LookupOperatorSpec(name)
+		if !ok {
+			panic(fmt.Sprintf("db: builtin operator %q not found", name))
+		}
+		RegisterOperator(Operator{OperatorSpec: spec, Build: build, Raw: raw})
+	}
+	compare := func(name, sqlOp string) {
+		builtin(name, false, func(column string, values []interface{}) (string, []interface{}, error) {
+			return column + " " + sqlOp + " ?", values, nil
+		})
+	}
+
+	compare(filters.OpEq, "=")
+	compare(filters.OpNe, "<>")
+	compare(filters.OpGt, ">")
+	compare(filters.OpGe, ">=")
+	compare(filters.OpLt, "<")
+	compare(filters.OpLe, "<=")
+	builtin(filters.OpIn, false, func(column string, values []interface{}) (string, []interface{}, error) {
+		return column + " IN (?)", []interface{}{values}, nil
+	})
+	builtin(filters.OpBetween, false, func(column string, values []interface{}) (string, []interface{}, error) {
+		return column + " BETWEEN ? AND ?", values, nil
+	})
+	builtin(filters.OpLike, true, func(column string, values []interface{}) (string, []interface{}, error) {
+		return column + " LIKE ?", []interface{}{"%" + likeEscaper.Replace(fmt.Sprint(values[0])) + "%"}, nil
+	})
+	builtin(filters.OpIsNull, false, func(column string, values []interface{}) (string, []interface{}, error) {
+		return column + " IS NULL", nil, nil
+	})
+	builtin(filters.OpNotNull, false, func(column string, values []interface{}) (string, []interface{}, error) {
+		return column + " IS NOT NULL", nil, nil
+	})
+}
+
+//注册筛选操作符, 同名的覆盖内置操作符; 写在 filters 的操作符表中, 解析 query=、filter= 时使用同一份
+//	db.RegisterOperator(db.Operator{
+//		OperatorSpec: filters.OperatorSpec{Name: "json-contains", Arity: filters.ArityOne, Prefixes: []string{"json-contains-"}},
+//		Build: func(column string, values []interface{}) (string, []interface{}, error) {
+//			return "JSON_CONTAINS(" + column + ", ?)", values, nil
+//		},
+//		Raw: true,
+//	})
+func RegisterOperator(op Operator) {
+	if op.Build == nil {
+		panic(fmt.Sprintf("db: operator %q has no Build func", op.Name))
+	}
+	spec := op.OperatorSpec
+	spec.Impl = nil
+	op.OperatorSpec = spec
+	spec.Impl = op
+	filters.RegisterOperatorSpec(spec)
+}
+
+//已注册的操作符, 只注册了写法没有 sql 实现的返回 false
+func lookupOperator(name string) (Operator, bool) {
+	spec, ok := filters.LookupOperatorSpec(name)
+	if !ok {
+		return Operator{}, false
+	}
+	op, ok := spec.Impl.(Operator)
+	return op, ok
+}
+
+//字段解析, 返回sql中的字段表达式和字段的Go类型, 类型未知时为nil
+type fieldResolver func(field string) (string, reflect.Type, error)
+
+//按条件树添加where条件, 条件不合法时不返回数据并记录错误
+//gorm 已通过 Model() 指定模型时, 只允许模型和 WithRelations 关联表中的字段, 字符串值按字段的Go类型转换
+func ApplyFilter(gorm *gorm.DB, cond *filters.Condition) *gorm.DB {
+	if cond == nil {
+		return gorm
+	}
+	fields := newModelFields(gorm)
+	sql, args, err := compileFilter(fields.resolve, cond)
+	if err != nil {
+		gorm = gorm.Where("1 = 0")
+		gorm.AddError(err)
+		return gorm
+	}
+	return fields.join(gorm).Where(sql, args...)
+}
+
+//将条件树编译为sql, 字段名校验后写入sql, 值全部使用占位符
+//columnTypes 为字段名对应的Go类型, 不为空时只允许其中的字段
+func CompileCondition(dialect string, columnTypes map[string]reflect.Type, cond *filters.Condition) (string, []interface{}, error) {
+	return compileFilter(columnsResolver(dialect, "", columnTypes), cond)
+}
+
+func compileFilter(resolve fieldResolver, cond *filters.Condition) (string, []interface{}, error) {
+	if err := cond.Validate(); err != nil {
+		return "", nil, err
+	}
+	return compileCondition(resolve, cond)
+}
+
+func compileCondition(resolve fieldResolver, cond *filters.Condition) (string, []interface{}, error) {
+	switch cond.Op {
+	case filters.OpAnd, filters.OpOr:
+		var parts []string
+		var args []interface{}
+		for _, sub := range cond.Conditions {
+			sql, subArgs, err := compileCondition(resolve, sub)
+			if err != nil {
+				return "", nil, err
+			}
+			parts = append(parts, "("+sql+")")
+			args = append(args, subArgs...)
+		}
+		return strings.Join(parts, " "+strings.ToUpper(cond.Op)+" "), args, nil
+	case filters.OpNot:
+		sql, args, err := compileCondition(resolve, cond.Conditions[0])
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + sql + ")", args, nil
+	}
+
+	op, ok := lookupOperator(cond.Op)
+	if !ok {
+		return "", nil, fmt.Errorf("unknown filter operator: %s", cond.Op)
+	}
+
+	column, t, err := resolve(cond.Field)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var values []interface{}
+	if l, ok := cond.Value.([]interface{}); ok {
+		values = append(values, l...)
+	} else if op.Arity != filters.ArityNone {
+		values = []interface{}{cond.Value}
+	}
+
+	//按字段类型转换, json路径和未知字段保持原值
+	if t != nil && !op.Raw {
+		for i, v := range values {
+			if values[i], err = convertFilterValue(t, v); err != nil {
+				return "", nil, fmt.Errorf("invalid value %v for filter field %s: %s", v, cond.Field, err)
+			}
+		}
+	}
+	return op.Build(column, values)
+}
+
+//校验字段名, json字段路径转为取值表达式
+func columnExpr(dialect, field string) (string, error) {
+	if strings.Contains(field, "->") {
+		return JsonPathExpr(dialect, field)
+	}
+	if !fieldRegex.MatchString(field) {
+		return "", fmt.Errorf("invalid filter field: %s", field)
+	}
+	return field, nil
+}
+
+//模型字段的Go类型, 以字段名为键
+func modelColumnTypes(gorm *gorm.DB) map[string]reflect.Type {
+	if gorm.Value == nil {
+		return nil
+	}
+	return structColumnTypes(gorm.NewScope(gorm.Value))
+}
+
+//结构体字段的Go类型, 外键生成的结构体指针字段类型为nil
+func structColumnTypes(scope *gorm.Scope) map[string]reflect.Type {
+	columnTypes := map[string]reflect.Type{}
+	for _, field := range scope.GetModelStruct().StructFields {
+		if field.DBName == "" || field.IsIgnored || field.Relationship != nil {
+			continue
+		}
+		if field.IsNormal {
+			columnTypes[field.DBName] = field.Struct.Type
+		} else {
+			columnTypes[field.DBName] = nil
+		}
+	}
+	return columnTypes
+}
+
+//columnTypes 不为空时只允许其中的字段, 可带表名 table; json路径校验其所在字段
+func columnsResolver(dialect, table string, columnTypes map[string]reflect.Type) fieldResolver {
+	return func(field string) (string, reflect.Type, error) {
+		column, err := columnExpr(dialect, field)
+		if err != nil || columnTypes == nil {
+			return column, nil, err
+		}
+
+		name := field
+		if idx := strings.Index(name, "->"); idx >= 0 {
+			name = name[:idx]
+		}
+		if idx := strings.LastIndex(name, "."); idx >= 0 {
+			if table != "" && name[:idx] != table {
+				return "", nil, fmt.Errorf("unknown filter field: %s", field)
+			}
+			name = name[idx+1:]
+		}
+		t, ok := columnTypes[name]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown filter field: %s", field)
+		}
+		if strings.Contains(field, "->") {
+			return column, nil, nil
+		}
+		return column, t, nil
+	}
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	nullTimeType    = reflect.TypeOf(types.NullTime{})
+	decimalType     = reflect.TypeOf(types.Decimal{})
+	nullDecimalType = reflect.TypeOf(types.NullDecimal{})
+	nullInt64Type   = reflect.TypeOf(types.NullInt64{})
+	nullUint64Type  = reflect.TypeOf(types.NullUint64{})
+	nullFloat64Type = reflect.TypeOf(types.NullFloat64{})
+	nullBoolType    = reflect.TypeOf(types.NullBool{})
+)
+
+//字符串值按字段的Go类型转换, 其它类型的值保持不变
+func convertFilterValue(t reflect.Type, v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType, nullTimeType:
+		for _, layout := range timeLayouts {
+			if tm, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return tm, nil
+			}
+		}
+		return nil, fmt.Errorf("not a time")
+	case decimalType, nullDecimalType:
+		return types.ParseDecimal(s)
+	case nullInt64Type:
+		return strconv.ParseInt(s, 10, 64)
+	case nullUint64Type:
+		return strconv.ParseUint(s, 10, 64)
+	case nullFloat64Type:
+		return strconv.ParseFloat(s, 64)
+	case nullBoolType:
+		return strconv.ParseBool(s)
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(s, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	}
+	return v, nil
+}
@@ -0,0 +1,197 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/yimishiji/bee/pkg/filters"
+)
+
+type testMember struct {
+	Id       int
+	Mobile   string
+	Nickname string
+	Password string
+	ApiToken string
+}
+
+func (testMember) TableName() string {
+	return "test_member"
+}
+
+//不连接数据库的 gorm 连接, 只用于生成条件
+func testConn() *gorm.DB {
+	conn := unavailableDb("mysql", errors.New("test db"))
+	conn.Error = nil
+	return conn
+}
+
+func TestApplyFilterHiddenColumns(t *testing.T) {
+	HideColumns("test_member", "mobile")
+
+	tests := []struct {
+		filter string
+		err    bool
+	}{
+		{filter: "nickname==a"},
+		{filter: "id>1;nickname=like=a"},
+		{filter: "password=like=a", err: true},
+		{filter: "test_member.password==a", err: true},
+		{filter: "api_token==a", err: true},
+		{filter: "PASSWORD==a", err: true},
+		{filter: "mobile==138", err: true},
+		{filter: "nickname==a,password==b", err: true},
+		{filter: "!(password=isnull=)", err: true},
+		{filter: "unknown==1", err: true},
+	}
+	for _, tt := range tests {
+		cond, err := filters.ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) error: %v", tt.filter, err)
+		}
+		q := ApplyFilter(testConn().Model(&testMember{}), cond)
+		if (q.Error != nil) != tt.err {
+			t.Errorf("ApplyFilter(%q) error = %v, want error %v", tt.filter, q.Error, tt.err)
+		}
+		if q.Error != nil && strings.Contains(q.Error.Error(), "hidden") {
+			t.Errorf("ApplyFilter(%q) error %q reveals the column is hidden", tt.filter, q.Error)
+		}
+	}
+}
+
+func TestApplySortHiddenColumns(t *testing.T) {
+	HideColumns("test_member", "mobile")
+
+	tests := []struct {
+		sort []string
+		err  bool
+	}{
+		{sort: []string{"id", "-nickname"}},
+		{sort: []string{"-password"}, err: true},
+		{sort: []string{"id", "api_token"}, err: true},
+		{sort: []string{"mobile"}, err: true},
+	}
+	for _, tt := range tests {
+		q := ApplySort(testConn().Model(&testMember{}), tt.sort)
+		if (q.Error != nil) != tt.err {
+			t.Errorf("ApplySort(%v) error = %v, want error %v", tt.sort, q.Error, tt.err)
+		}
+	}
+}
+
+func TestIsHiddenColumn(t *testing.T) {
+	HideColumns("Test_Hidden", "Remark")
+	tests := []struct {
+		table, column string
+		want          bool
+	}{
+		{"a", "password", true},
+		{"a", "login_password_hash", true},
+		{"a", "pwd", true},
+		{"a", "pwd2", false},
+		{"a", "salt", true},
+		{"a", "user_salt", true},
+		{"a", "client_secret", true},
+		{"a", "refresh_token", true},
+		{"a", "mobile", false},
+		{"test_hidden", "remark", true},
+		{"TEST_HIDDEN", "REMARK", true},
+		{"other", "remark", false},
+	}
+	for _, tt := range tests {
+		if got := IsHiddenColumn(tt.table, tt.column); got != tt.want {
+			t.Errorf("IsHiddenColumn(%q, %q) = %v, want %v", tt.table, tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestRelationHiddenColumns(t *testing.T) {
+	HideColumns("test_member", "mobile")
+	relations := []Relation{{Name: "Member", Table: "test_member", ForeignKey: "member_id", References: "id", Model: &testMember{}}}
+
+	tests := []struct {
+		filter string
+		err    bool
+	}{
+		{filter: "Member.nickname==a"},
+		{filter: "Member.id>1;title==a"},
+		{filter: "Member.password==a", err: true},
+		{filter: "Member.api_token=isnull=", err: true},
+		{filter: "Member.mobile==138", err: true},
+		{filter: "Member.unknown==1", err: true},
+	}
+	for _, tt := range tests {
+		cond, err := filters.ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) error: %v", tt.filter, err)
+		}
+		q := ApplyFilter(WithRelations(testConn().Model(&testOrder{}), relations...), cond)
+		if (q.Error != nil) != tt.err {
+			t.Errorf("ApplyFilter(%q) error = %v, want error %v", tt.filter, q.Error, tt.err)
+		}
+	}
+
+	for _, sort := range [][]string{{"-Member.password"}, {"Member.mobile"}} {
+		if q := ApplySort(WithRelations(testConn().Model(&testOrder{}), relations...), sort); q.Error == nil {
+			t.Errorf("ApplySort(%v) want error", sort)
+		}
+	}
+	if q := ApplySort(WithRelations(testConn().Model(&testOrder{}), relations...), []string{"-Member.nickname"}); q.Error != nil {
+		t.Errorf("ApplySort(-Member.nickname) error: %v", q.Error)
+	}
+}
+
+func TestRegisterOperator(t *testing.T) {
+	RegisterOperator(Operator{
+		OperatorSpec: filters.OperatorSpec{Name: "test-prefix", Arity: filters.ArityOne, Prefixes: []string{"test-prefix-"}},
+		Build: func(column string, values []interface{}) (string, []interface{}, error) {
+			return column + " LIKE ?", []interface{}{values[0].(string) + "%"}, nil
+		},
+		Raw: true,
+	})
+	//只注册写法没有 sql 实现的操作符可以解析, 不能编译
+	filters.RegisterOperatorSpec(filters.OperatorSpec{Name: "test-spec-only", Arity: filters.ArityOne})
+
+	spec, ok := filters.LookupOperatorSpec("test-prefix")
+	if !ok || spec.Arity != filters.ArityOne {
+		t.Fatalf("LookupOperatorSpec(test-prefix) = %v, %v", spec, ok)
+	}
+	cond := filters.QueryCondition(map[string]string{"nickname": "test-prefix-ab"})
+	sql, args, err := CompileCondition("mysql", nil, cond)
+	if err != nil || sql != "(nickname LIKE ?)" || len(args) != 1 || args[0] != "ab%" {
+		t.Errorf("CompileCondition = %q, %v, %v", sql, args, err)
+	}
+
+	cond, err = filters.ParseFilter("nickname=test-spec-only=a")
+	if err != nil {
+		t.Fatalf("ParseFilter error: %v", err)
+	}
+	if _, _, err := CompileCondition("mysql", nil, cond); err == nil || !strings.Contains(err.Error(), "unknown filter operator") {
+		t.Errorf("CompileCondition of a spec only operator error = %v, want unknown filter operator", err)
+	}
+}
+
+func TestSelectColumnsWithRelation(t *testing.T) {
+	HideColumns("test_member", "mobile")
+	relations := []Relation{{Name: "Member", Table: "test_member", ForeignKey: "member_id", References: "id", Model: &testMember{}}}
+	cond, err := filters.ParseFilter("Member.nickname==a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := ApplyFilter(WithRelations(testConn().Model(&testOrder{}), relations...), cond)
+	q = ApplySort(q, []string{"-Member.nickname"})
+	sql, _ := querySQL(SelectColumns(q, []string{"id", " `title` ", "test_order.amount", "id"}))
+	if !strings.HasPrefix(sql, "SELECT `test_order`.`id`,`test_order`.`title`,`test_order`.`amount` FROM") || !strings.Contains(sql, "LEFT JOIN") {
+		t.Errorf("select with relation sql = %q", sql)
+	}
+
+	for _, fields := range [][]string{{"password"}, {"id", "nickname"}, {"test_member.id"}, {"id,title"}, {"count(*)"}} {
+		q := SelectColumns(testConn().Model(&testOrder{}), fields)
+		if q.Error == nil {
+			t.Errorf("SelectColumns(%q) want error", fields)
+		}
+	}
+}
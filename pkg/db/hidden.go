@@ -0,0 +1,54 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+	"github.com/yimishiji/bee/pkg/hidden"
+)
+
+//隐藏字段不能用于 filter、sortby、aggregate 和分页游标, 避免通过条件逐字猜出密码等字段的值
+//默认隐藏的字段见 hidden.DefaultColumns, 与 bee generate appcode 默认不输出的字段相同
+
+var (
+	hiddenColumns   = map[string]map[string]bool{}
+	hiddenColumnsMu sync.RWMutex
+)
+
+//隐藏表的字段, 生成的 model 在 init 中按 -hidden 配置调用
+func HideColumns(table string, columns ...string) {
+	hiddenColumnsMu.Lock()
+	defer hiddenColumnsMu.Unlock()
+	table = strings.ToLower(table)
+	if hiddenColumns[table] == nil {
+		hiddenColumns[table] = map[string]bool{}
+	}
+	for _, column := range columns {
+		hiddenColumns[table][strings.ToLower(column)] = true
+	}
+}
+
+//字段是否隐藏: 匹配 hidden.DefaultColumns 或由 HideColumns 指定
+func IsHiddenColumn(table, column string) bool {
+	if hidden.Match(table, column) {
+		return true
+	}
+	table, column = strings.ToLower(table), strings.ToLower(column)
+	hiddenColumnsMu.RLock()
+	defer hiddenColumnsMu.RUnlock()
+	return hiddenColumns[table][column]
+}
+
+//结构体中可用于筛选、排序的字段, 去掉隐藏字段
+func visibleColumnTypes(scope *gorm.Scope) map[string]reflect.Type {
+	columnTypes := structColumnTypes(scope)
+	table := scope.TableName()
+	for column := range columnTypes {
+		if IsHiddenColumn(table, column) {
+			delete(columnTypes, column)
+		}
+	}
+	return columnTypes
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/logs"
+	"github.com/jinzhu/gorm"
+)
+
+type requestIdKey struct{}
+
+//sql日志配置, 取自 [db]
+//query_log: off(默认)、slow 只记录慢查询、all 记录全部
+//slow_query_threshold: 慢查询秒数, 默认1
+//query_log_vars: 是否记录查询参数, 默认不记录
+type queryLogConfig struct {
+	all       bool
+	slow      bool
+	threshold time.Duration
+	vars      bool
+}
+
+var (
+	queryLogOnce sync.Once
+	queryLogConf queryLogConfig
+)
+
+func loadQueryLogConfig() queryLogConfig {
+	queryLogOnce.Do(func() {
+		switch strings.ToLower(beego.AppConfig.String("db::query_log")) {
+		case "all", "true", "on":
+			queryLogConf.all, queryLogConf.slow = true, true
+		case "slow":
+			queryLogConf.slow = true
+		}
+		sec := beego.AppConfig.DefaultFloat("db::slow_query_threshold", 1)
+		queryLogConf.threshold = time.Duration(sec * float64(time.Second))
+		queryLogConf.vars = beego.AppConfig.DefaultBool("db::query_log_vars", false)
+	})
+	return queryLogConf
+}
+
+//返回携带请求id的 ctx, 使用该 ctx 的查询在sql日志中记录请求id
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+//取 ctx 中的请求id
+func RequestIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+//按配置开启sql日志, 日志中记录 requestId
+func applyQueryLog(db *gorm.DB, requestId string) {
+	conf := loadQueryLogConfig()
+	if !conf.slow {
+		return
+	}
+	db.SetLogger(&queryLogger{conf: conf, requestId: requestId})
+	db.LogMode(true)
+}
+
+//sql日志, 每条查询输出一行json
+//	{"request_id":"...","sql":"SELECT ...","duration_ms":12.5,"rows":20}
+//超过 slow_query_threshold 的查询以 warn 级别输出, 带 "slow":true 和调用位置 caller
+type queryLogger struct {
+	conf      queryLogConfig
+	requestId string
+}
+
+type queryLogEntry struct {
+	RequestId  string        `json:"request_id,omitempty"`
+	Sql        string        `json:"sql,omitempty"`
+	Vars       []interface{} `json:"vars,omitempty"`
+	DurationMs float64       `json:"duration_ms"`
+	Rows       int64         `json:"rows"`
+	Slow       bool          `json:"slow,omitempty"`
+	Caller     string        `json:"caller,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+//gorm 的日志接口, sql: ("sql", 位置, 耗时, sql, 参数, 行数), 错误: ("log", 位置, 错误...)
+func (l *queryLogger) Print(v ...interface{}) {
+	if len(v) < 2 {
+		return
+	}
+	entry := queryLogEntry{RequestId: l.requestId}
+
+	switch v[0] {
+	case "sql":
+		if len(v) < 6 {
+			return
+		}
+		duration, _ := v[2].(time.Duration)
+		entry.Sql, _ = v[3].(string)
+		entry.DurationMs = float64(duration) / float64(time.Millisecond)
+		entry.Rows, _ = v[5].(int64)
+		if l.conf.vars {
+			entry.Vars, _ = v[4].([]interface{})
+		}
+		if duration >= l.conf.threshold {
+			entry.Slow = true
+			entry.Caller = queryCaller()
+			logs.Warn(entry.json())
+		} else if l.conf.all {
+			logs.Info(entry.json())
+		}
+
+	case "log":
+		entry.Error = strings.TrimSpace(fmt.Sprintln(v[2:]...))
+		entry.Caller = queryCaller()
+		logs.Error(entry.json())
+	}
+}
+
+func (e queryLogEntry) json() string {
+	b, err := json.Marshal(e)
+	if err != nil {
+		e.Vars = nil
+		b, _ = json.Marshal(e)
+	}
+	return string(b)
+}
+
+//调用位置, 跳过 gorm、pkg/db 和标准库
+func queryCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.File, "/jinzhu/gorm/") &&
+			!strings.Contains(frame.File, "/yimishiji/bee/pkg/db/") &&
+			!strings.HasPrefix(frame.Function, "runtime.") &&
+			!strings.HasPrefix(frame.Function, "database/sql.") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
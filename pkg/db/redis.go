@@ -1,30 +1,309 @@
 package db
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/astaxie/beego"
 	"github.com/go-redis/redis"
 )
 
+//redis 命令, 键名加前缀; redis 客户端、管道和内存实现共用
+type RedisCmdable interface {
+	BuildKey(key string) string
+
+	//键
+	Del(keys ...string) *redis.IntCmd
+	Unlink(keys ...string) *redis.IntCmd
+	Exists(keys ...string) *redis.IntCmd
+	Expire(key string, expiration time.Duration) *redis.BoolCmd
+	ExpireAt(key string, tm time.Time) *redis.BoolCmd
+	PExpire(key string, expiration time.Duration) *redis.BoolCmd
+	Persist(key string) *redis.BoolCmd
+	TTL(key string) *redis.DurationCmd
+	PTTL(key string) *redis.DurationCmd
+	Type(key string) *redis.StatusCmd
+	Rename(key, newkey string) *redis.StatusCmd
+	RenameNX(key, newkey string) *redis.BoolCmd
+
+	//字符串
+	Get(key string) *redis.StringCmd
+	GetSet(key string, value interface{}) *redis.StringCmd
+	Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	SetNX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	SetXX(key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	MGet(keys ...string) *redis.SliceCmd
+	MSet(pairs ...interface{}) *redis.StatusCmd
+	MSetNX(pairs ...interface{}) *redis.BoolCmd
+	Incr(key string) *redis.IntCmd
+	IncrBy(key string, value int64) *redis.IntCmd
+	IncrByFloat(key string, value float64) *redis.FloatCmd
+	Decr(key string) *redis.IntCmd
+	DecrBy(key string, decrement int64) *redis.IntCmd
+	Append(key, value string) *redis.IntCmd
+	StrLen(key string) *redis.IntCmd
+
+	//哈希
+	HDel(key string, fields ...string) *redis.IntCmd
+	HExists(key, field string) *redis.BoolCmd
+	HGet(key, field string) *redis.StringCmd
+	HGetAll(key string) *redis.StringStringMapCmd
+	HIncrBy(key, field string, incr int64) *redis.IntCmd
+	HIncrByFloat(key, field string, incr float64) *redis.FloatCmd
+	HKeys(key string) *redis.StringSliceCmd
+	HLen(key string) *redis.IntCmd
+	HMGet(key string, fields ...string) *redis.SliceCmd
+	HMSet(key string, fields map[string]interface{}) *redis.StatusCmd
+	HSet(key, field string, value interface{}) *redis.BoolCmd
+	HSetNX(key, field string, value interface{}) *redis.BoolCmd
+	HVals(key string) *redis.StringSliceCmd
+	HScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd
+
+	//列表
+	LIndex(key string, index int64) *redis.StringCmd
+	LInsertBefore(key string, pivot, value interface{}) *redis.IntCmd
+	LInsertAfter(key string, pivot, value interface{}) *redis.IntCmd
+	LLen(key string) *redis.IntCmd
+	LPop(key string) *redis.StringCmd
+	LPush(key string, values ...interface{}) *redis.IntCmd
+	LPushX(key string, value interface{}) *redis.IntCmd
+	LRange(key string, start, stop int64) *redis.StringSliceCmd
+	LRem(key string, count int64, value interface{}) *redis.IntCmd
+	LSet(key string, index int64, value interface{}) *redis.StatusCmd
+	LTrim(key string, start, stop int64) *redis.StatusCmd
+	RPop(key string) *redis.StringCmd
+	RPopLPush(source, destination string) *redis.StringCmd
+	BRPopLPush(source, destination string, timeout time.Duration) *redis.StringCmd
+	RPush(key string, values ...interface{}) *redis.IntCmd
+	RPushX(key string, value interface{}) *redis.IntCmd
+
+	//集合
+	SAdd(key string, members ...interface{}) *redis.IntCmd
+	SCard(key string) *redis.IntCmd
+	SDiff(keys ...string) *redis.StringSliceCmd
+	SDiffStore(destination string, keys ...string) *redis.IntCmd
+	SInter(keys ...string) *redis.StringSliceCmd
+	SInterStore(destination string, keys ...string) *redis.IntCmd
+	SIsMember(key string, member interface{}) *redis.BoolCmd
+	SMembers(key string) *redis.StringSliceCmd
+	SMove(source, destination string, member interface{}) *redis.BoolCmd
+	SPop(key string) *redis.StringCmd
+	SPopN(key string, count int64) *redis.StringSliceCmd
+	SRandMember(key string) *redis.StringCmd
+	SRandMemberN(key string, count int64) *redis.StringSliceCmd
+	SRem(key string, members ...interface{}) *redis.IntCmd
+	SUnion(keys ...string) *redis.StringSliceCmd
+	SUnionStore(destination string, keys ...string) *redis.IntCmd
+	SScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd
+
+	//有序集合
+	ZAdd(key string, members ...redis.Z) *redis.IntCmd
+	ZAddNX(key string, members ...redis.Z) *redis.IntCmd
+	ZAddXX(key string, members ...redis.Z) *redis.IntCmd
+	ZIncrBy(key string, increment float64, member string) *redis.FloatCmd
+	ZCard(key string) *redis.IntCmd
+	ZCount(key, min, max string) *redis.IntCmd
+	ZRange(key string, start, stop int64) *redis.StringSliceCmd
+	ZRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd
+	ZRangeByScore(key string, opt redis.ZRangeBy) *redis.StringSliceCmd
+	ZRangeByScoreWithScores(key string, opt redis.ZRangeBy) *redis.ZSliceCmd
+	ZRevRange(key string, start, stop int64) *redis.StringSliceCmd
+	ZRevRangeWithScores(key string, start, stop int64) *redis.ZSliceCmd
+	ZRevRangeByScore(key string, opt redis.ZRangeBy) *redis.StringSliceCmd
+	ZRevRangeByScoreWithScores(key string, opt redis.ZRangeBy) *redis.ZSliceCmd
+	ZRank(key, member string) *redis.IntCmd
+	ZRevRank(key, member string) *redis.IntCmd
+	ZRem(key string, members ...interface{}) *redis.IntCmd
+	ZRemRangeByRank(key string, start, stop int64) *redis.IntCmd
+	ZRemRangeByScore(key, min, max string) *redis.IntCmd
+	ZScore(key, member string) *redis.FloatCmd
+	ZInterStore(destination string, store redis.ZStore, keys ...string) *redis.IntCmd
+	ZUnionStore(destination string, store redis.ZStore, keys ...string) *redis.IntCmd
+	ZScan(key string, cursor uint64, match string, count int64) *redis.ScanCmd
+
+	//脚本、发布
+	Eval(script string, keys []string, args ...interface{}) *redis.Cmd
+	EvalSha(sha1 string, keys []string, args ...interface{}) *redis.Cmd
+	Publish(channel string, message interface{}) *redis.IntCmd
+}
+
+//redis 客户端, GetRedisClient 连接后赋值给 Redis; 测试中可使用 NewMemoryRedis
+type RedisClient interface {
+	RedisCmdable
+
+	Ping() error
+	StripKey(key string) string
+	Keys(pattern string) ([]string, error)
+	Scan(cursor uint64, match string, count int64) ([]string, uint64, error)
+	ScanKeys(match string, count int64) ([]string, error)
+	BLPop(timeout time.Duration, keys ...string) ([]string, error)
+	BRPop(timeout time.Duration, keys ...string) ([]string, error)
+	Subscribe(channels ...string) *redis.PubSub
+	PSubscribe(patterns ...string) *redis.PubSub
+	Pipeline() RedisPipeliner
+	TxPipeline() RedisPipeliner
+	Pipelined(fn func(pipe RedisPipeliner) error) ([]redis.Cmder, error)
+	TxPipelined(fn func(pipe RedisPipeliner) error) ([]redis.Cmder, error)
+	Close() error
+}
+
+//管道, 命令与 RedisClient 相同, 调用 Exec 后一次发送
+type RedisPipeliner interface {
+	RedisCmdable
+
+	Exec() ([]redis.Cmder, error)
+	Discard() error
+}
+
 var (
-	Redis *redisClient
+	Redis RedisClient
 )
 
-//连接redis
-func GetRedisClient() (*redisClient, error) {
-	db, _ := beego.AppConfig.Int("redis::database")
-	redisOption := &redis.Options{
-		Addr:     beego.AppConfig.String("redis::host"),
-		Password: beego.AppConfig.String("redis::password"),
-		DB:       db,
+//连接redis, 按 redis::mode 连接单机、哨兵或集群, 键名前缀为 redis::prefix
+func GetRedisClient() (RedisClient, error) {
+	client, err := newUniversalClient(loadRedisConfig())
+	if err != nil {
+		return nil, err
 	}
-	client := NewClient(redisOption)
+	c := newRedisClient(client, beego.AppConfig.String("redis::prefix"))
 
-	_, err := client.baseRedisClient.Ping().Result()
+	err = c.Ping()
 	if err != nil {
+		c.Close()
 		return nil, err
 	}
 
-	Redis = client
+	Redis = c
+
+	return c, nil
+}
+
+//redis 连接配置, 对应 [redis]
+//mode: single(默认)、sentinel、cluster
+//host: 单机地址; master_name、sentinel_addrs: 哨兵; cluster_nodes、cluster_read_only: 集群, 地址以逗号分隔
+//tls、tls_skip_verify: TLS 连接
+//pool_size、min_idle_conns、max_retries 和 dial_timeout、read_timeout、write_timeout、pool_timeout、idle_timeout(秒): 未配置时使用 go-redis 的默认值
+type redisConfig struct {
+	mode          string
+	host          string
+	password      string
+	database      int
+	masterName    string
+	sentinelAddrs []string
+	clusterNodes  []string
+	readOnly      bool
+	tls           *tls.Config
+	poolSize      int
+	minIdleConns  int
+	maxRetries    int
+	dialTimeout   time.Duration
+	readTimeout   time.Duration
+	writeTimeout  time.Duration
+	poolTimeout   time.Duration
+	idleTimeout   time.Duration
+}
+
+func loadRedisConfig() redisConfig {
+	c := redisConfig{
+		mode:          strings.ToLower(beego.AppConfig.DefaultString("redis::mode", "single")),
+		host:          beego.AppConfig.String("redis::host"),
+		password:      beego.AppConfig.String("redis::password"),
+		database:      beego.AppConfig.DefaultInt("redis::database", 0),
+		masterName:    beego.AppConfig.String("redis::master_name"),
+		sentinelAddrs: splitAddrs(beego.AppConfig.String("redis::sentinel_addrs")),
+		clusterNodes:  splitAddrs(beego.AppConfig.String("redis::cluster_nodes")),
+		readOnly:      beego.AppConfig.DefaultBool("redis::cluster_read_only", false),
+		poolSize:      beego.AppConfig.DefaultInt("redis::pool_size", 0),
+		minIdleConns:  beego.AppConfig.DefaultInt("redis::min_idle_conns", 0),
+		maxRetries:    beego.AppConfig.DefaultInt("redis::max_retries", 0),
+		dialTimeout:   redisSeconds("dial_timeout"),
+		readTimeout:   redisSeconds("read_timeout"),
+		writeTimeout:  redisSeconds("write_timeout"),
+		poolTimeout:   redisSeconds("pool_timeout"),
+		idleTimeout:   redisSeconds("idle_timeout"),
+	}
+	if beego.AppConfig.DefaultBool("redis::tls", false) {
+		c.tls = &tls.Config{InsecureSkipVerify: beego.AppConfig.DefaultBool("redis::tls_skip_verify", false)}
+	}
+	return c
+}
+
+func redisSeconds(key string) time.Duration {
+	return time.Duration(beego.AppConfig.DefaultFloat("redis::"+key, 0) * float64(time.Second))
+}
+
+//逗号分隔的地址, 去掉空白
+func splitAddrs(s string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(s, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
 
-	return client, nil
+func newUniversalClient(c redisConfig) (redis.UniversalClient, error) {
+	switch c.mode {
+	case "", "single":
+		return redis.NewClient(&redis.Options{
+			Addr:         c.host,
+			Password:     c.password,
+			DB:           c.database,
+			MaxRetries:   c.maxRetries,
+			DialTimeout:  c.dialTimeout,
+			ReadTimeout:  c.readTimeout,
+			WriteTimeout: c.writeTimeout,
+			PoolSize:     c.poolSize,
+			MinIdleConns: c.minIdleConns,
+			PoolTimeout:  c.poolTimeout,
+			IdleTimeout:  c.idleTimeout,
+			TLSConfig:    c.tls,
+		}), nil
+	case "sentinel":
+		if c.masterName == "" || len(c.sentinelAddrs) == 0 {
+			return nil, errors.New("redis::master_name and redis::sentinel_addrs are required in sentinel mode")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    c.masterName,
+			SentinelAddrs: c.sentinelAddrs,
+			Password:      c.password,
+			DB:            c.database,
+			MaxRetries:    c.maxRetries,
+			DialTimeout:   c.dialTimeout,
+			ReadTimeout:   c.readTimeout,
+			WriteTimeout:  c.writeTimeout,
+			PoolSize:      c.poolSize,
+			MinIdleConns:  c.minIdleConns,
+			PoolTimeout:   c.poolTimeout,
+			IdleTimeout:   c.idleTimeout,
+			TLSConfig:     c.tls,
+		}), nil
+	case "cluster":
+		if len(c.clusterNodes) == 0 {
+			return nil, errors.New("redis::cluster_nodes is required in cluster mode")
+		}
+		//集群只有 0 号库
+		if c.database != 0 {
+			return nil, errors.New("redis::database must be 0 in cluster mode")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        c.clusterNodes,
+			ReadOnly:     c.readOnly,
+			Password:     c.password,
+			MaxRetries:   c.maxRetries,
+			DialTimeout:  c.dialTimeout,
+			ReadTimeout:  c.readTimeout,
+			WriteTimeout: c.writeTimeout,
+			PoolSize:     c.poolSize,
+			MinIdleConns: c.minIdleConns,
+			PoolTimeout:  c.poolTimeout,
+			IdleTimeout:  c.idleTimeout,
+			TLSConfig:    c.tls,
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown redis::mode %q, use single, sentinel or cluster", c.mode)
 }
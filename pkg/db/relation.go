@@ -0,0 +1,213 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	relationsSetting = "bee:relations"
+	joinedSetting    = "bee:joined"
+)
+
+// Relation 模型通过外键关联的表, 可在 query、filter、sortby 中以 关联名.字段 筛选和排序, 如 Member.mobile
+type Relation struct {
+	Name       string      //关联名, 如 Member
+	Table      string      //关联表, 如 member
+	ForeignKey string      //本表的外键字段, 如 member_id
+	References string      //关联表被引用的字段, 如 id
+	Model      interface{} //关联表的结构体, 用于字段校验和值类型转换, 如 &TableStructs.Member{}
+}
+
+//关联表在sql中的别名
+func (r Relation) alias(table string) string {
+	alias := gorm.ToDBName(r.Name)
+	if alias == table {
+		alias += "_rel"
+	}
+	return alias
+}
+
+//设置模型的关联表, 之后的 ApplyFilter、ApplySort 中用到关联表的字段时自动 LEFT JOIN
+func WithRelations(gorm *gorm.DB, relations ...Relation) *gorm.DB {
+	return gorm.Set(relationsSetting, relations)
+}
+
+//按字段排序, 写法同 PageCommonParams.SortFields, 如 -created_at、Member.mobile
+//gorm 已通过 Model() 指定模型时, 只允许模型和关联表中的字段, 不合法时不返回数据并记录错误
+func ApplySort(gorm *gorm.DB, sortFields []string) *gorm.DB {
+	fields := newModelFields(gorm)
+	var orders []string
+	for _, v := range sortFields {
+		column, _, err := fields.resolve(strings.TrimPrefix(v, "-"))
+		if err != nil {
+			gorm = gorm.Where("1 = 0")
+			gorm.AddError(fmt.Errorf("invalid sort field: %s", strings.TrimPrefix(v, "-")))
+			return gorm
+		}
+		if strings.HasPrefix(v, "-") {
+			column += " DESC"
+		}
+		orders = append(orders, column)
+	}
+
+	gorm = fields.join(gorm)
+	for _, order := range orders {
+		gorm = gorm.Order(order)
+	}
+	return gorm
+}
+
+//fields= 只取部分字段: 只允许模型中的字段, 隐藏字段不可用, 字段名加上表名, 与关联表 join 时不会有同名字段冲突
+//字段可写作 字段、表.字段, 可带引号; gorm 需已通过 Model() 指定模型
+func SelectColumns(gorm *gorm.DB, fields []string) *gorm.DB {
+	if len(fields) == 0 {
+		return gorm
+	}
+	if gorm.Value == nil {
+		gorm.AddError(errors.New("select fields needs a model"))
+		return gorm
+	}
+	scope := gorm.NewScope(gorm.Value)
+	table := scope.TableName()
+	columnTypes := visibleColumnTypes(scope)
+
+	var columns []string
+	selected := map[string]bool{}
+	for _, field := range fields {
+		name := strings.TrimSpace(identQuotes.Replace(field))
+		name = strings.TrimPrefix(name, table+".")
+		if _, ok := columnTypes[name]; !ok {
+			gorm = gorm.Where("1 = 0")
+			gorm.AddError(fmt.Errorf("invalid select field: %s", strings.TrimSpace(field)))
+			return gorm
+		}
+		if !selected[name] {
+			selected[name] = true
+			columns = append(columns, scope.Quote(table)+"."+scope.Quote(name))
+		}
+	}
+	return gorm.Select(strings.Join(columns, ","))
+}
+
+//模型和关联表的字段, 解析字段名并记录用到的关联表
+type modelFields struct {
+	dialect     string
+	scope       *gorm.Scope
+	table       string
+	columnTypes map[string]reflect.Type
+	relations   map[string]Relation
+	relTypes    map[string]map[string]reflect.Type
+	used        []Relation
+}
+
+func newModelFields(gorm *gorm.DB) *modelFields {
+	m := &modelFields{dialect: gorm.Dialect().GetName()}
+	if gorm.Value == nil {
+		return m
+	}
+	m.scope = gorm.NewScope(gorm.Value)
+	m.table = m.scope.TableName()
+	m.columnTypes = visibleColumnTypes(m.scope)
+	m.relations = map[string]Relation{}
+	m.relTypes = map[string]map[string]reflect.Type{}
+	if v, ok := gorm.Get(relationsSetting); ok {
+		for _, rel := range v.([]Relation) {
+			m.relations[rel.Name] = rel
+		}
+	}
+	return m
+}
+
+//字段名转为sql表达式: 字段、表.字段、关联名.字段, 可带json路径
+//未指定模型时只校验字段名
+func (m *modelFields) resolve(field string) (string, reflect.Type, error) {
+	column, err := columnExpr(m.dialect, field)
+	if err != nil || m.columnTypes == nil {
+		return column, nil, err
+	}
+
+	name, path := field, ""
+	if idx := strings.Index(name, "->"); idx >= 0 {
+		name, path = name[:idx], name[idx+2:]
+	}
+	prefix := ""
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		prefix, name = name[:idx], name[idx+1:]
+	}
+
+	alias, columnTypes := m.table, m.columnTypes
+	if prefix != "" && prefix != m.table {
+		rel, ok := m.relations[prefix]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown filter field: %s", field)
+		}
+		alias, columnTypes = rel.alias(m.table), m.relationTypes(rel)
+		m.use(rel)
+	}
+
+	t, ok := columnTypes[name]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown filter field: %s", field)
+	}
+	column = m.scope.Quote(alias) + "." + m.scope.Quote(name)
+	if path != "" {
+		return jsonExtract(m.dialect, column, path), nil, nil
+	}
+	return column, t, nil
+}
+
+//关联表的字段类型, 不含关联表的隐藏字段
+func (m *modelFields) relationTypes(rel Relation) map[string]reflect.Type {
+	if columnTypes, ok := m.relTypes[rel.Name]; ok {
+		return columnTypes
+	}
+	columnTypes := map[string]reflect.Type{}
+	if rel.Model != nil {
+		columnTypes = visibleColumnTypes(m.scope.NewDB().NewScope(rel.Model))
+	}
+	m.relTypes[rel.Name] = columnTypes
+	return columnTypes
+}
+
+func (m *modelFields) use(rel Relation) {
+	for _, r := range m.used {
+		if r.Name == rel.Name {
+			return
+		}
+	}
+	m.used = append(m.used, rel)
+}
+
+//LEFT JOIN 用到的关联表, 已 join 的不重复添加
+//第一次 join 时 select 本表的字段, 避免与关联表的同名字段冲突
+func (m *modelFields) join(gorm *gorm.DB) *gorm.DB {
+	if len(m.used) == 0 {
+		return gorm
+	}
+	joined := map[string]bool{}
+	if v, ok := gorm.Get(joinedSetting); ok {
+		for name := range v.(map[string]bool) {
+			joined[name] = true
+		}
+	}
+	if len(joined) == 0 {
+		gorm = gorm.Select(m.scope.Quote(m.table) + ".*")
+	}
+
+	for _, rel := range m.used {
+		if joined[rel.Name] {
+			continue
+		}
+		alias := m.scope.Quote(rel.alias(m.table))
+		gorm = gorm.Joins(fmt.Sprintf("LEFT JOIN %s AS %s ON %s.%s = %s.%s",
+			m.scope.Quote(rel.Table), alias, alias, m.scope.Quote(rel.References),
+			m.scope.Quote(m.table), m.scope.Quote(rel.ForeignKey)))
+		joined[rel.Name] = true
+	}
+	return gorm.Set(joinedSetting, joined)
+}
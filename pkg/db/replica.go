@@ -0,0 +1,234 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/jinzhu/gorm"
+)
+
+//从库健康检查默认间隔
+const defaultReplicaCheckInterval = 10 * time.Second
+
+//从库连接和 ping 的超时
+const replicaPingTimeout = 3 * time.Second
+
+//连接名对应的从库组, 默认连接的名称为空
+var (
+	replicaSets   = map[string]*replicaSet{}
+	replicaSetsMu sync.RWMutex
+)
+
+//一个主库对应的从库组
+type replicaSet struct {
+	replicas []*replica
+	next     uint32
+	stop     chan struct{}
+}
+
+//从库, 连接失败或 ping 不通时标记为不可用, 由健康检查恢复
+type replica struct {
+	sync.RWMutex
+	host    string
+	name    string //所属主库的连接名
+	cfg     dbConfig
+	dsn     string
+	db      *gorm.DB
+	healthy bool
+	closed  bool
+}
+
+//读连接, 轮询可用的从库, 没有配置或全部不可用时返回主库
+func Read(name string) *gorm.DB {
+	if name == "default" {
+		name = ""
+	}
+	replicaSetsMu.RLock()
+	set := replicaSets[name]
+	replicaSetsMu.RUnlock()
+
+	if set != nil {
+		if db := set.pick(); db != nil {
+			return db
+		}
+	}
+	return Use(name)
+}
+
+//读连接, primary 为 true 时读主库, 用于读取本次请求刚写入的数据
+func ReadFor(name string, primary bool) *gorm.DB {
+	if primary {
+		return Use(name)
+	}
+	return Read(name)
+}
+
+//按 [db] 或 [db.<name>] 中的 replicas 配置连接从库
+//replicas = 10.0.0.2:3306,10.0.0.3:3306
+//replica_user, replica_password 未配置时使用主库的账号
+//replica_check_interval 健康检查间隔秒数, 默认10
+func openReplicas(section, name string) {
+	hosts := beego.AppConfig.Strings(section + "::replicas")
+	user := beego.AppConfig.String(section + "::replica_user")
+	password := beego.AppConfig.String(section + "::replica_password")
+	cfg := loadDbConfig(section)
+
+	set := &replicaSet{stop: make(chan struct{})}
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		dsn, err := cfg.dsn(host, user, password)
+		if err != nil {
+			beego.Warn("db replica", host, err)
+			continue
+		}
+		r := &replica{
+			host: host,
+			name: name,
+			cfg:  cfg,
+			dsn:  dsn,
+		}
+		r.check()
+		set.replicas = append(set.replicas, r)
+	}
+
+	replicaSetsMu.Lock()
+	if old, ok := replicaSets[name]; ok {
+		old.close()
+		delete(replicaSets, name)
+	}
+	if len(set.replicas) > 0 {
+		replicaSets[name] = set
+	}
+	replicaSetsMu.Unlock()
+
+	if len(set.replicas) > 0 {
+		interval := defaultReplicaCheckInterval
+		if sec, err := beego.AppConfig.Int(section + "::replica_check_interval"); err == nil && sec > 0 {
+			interval = time.Duration(sec) * time.Second
+		}
+		go set.healthCheck(interval)
+	}
+}
+
+//关闭所有从库连接
+func closeReplicas() {
+	replicaSetsMu.Lock()
+	defer replicaSetsMu.Unlock()
+	for name, set := range replicaSets {
+		set.close()
+		delete(replicaSets, name)
+	}
+}
+
+//轮询取一个可用的从库
+func (s *replicaSet) pick() *gorm.DB {
+	n := len(s.replicas)
+	start := atomic.AddUint32(&s.next, 1)
+	for i := 0; i < n; i++ {
+		r := s.replicas[(int(start)+i)%n]
+		r.RLock()
+		db, healthy := r.db, r.healthy
+		r.RUnlock()
+		if healthy && db != nil {
+			return db
+		}
+	}
+	return nil
+}
+
+//定时检查从库状态
+func (s *replicaSet) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, r := range s.replicas {
+				r.check()
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *replicaSet) close() {
+	close(s.stop)
+	for _, r := range s.replicas {
+		r.Lock()
+		if r.db != nil {
+			r.db.Close()
+			r.db = nil
+		}
+		r.healthy = false
+		r.closed = true
+		r.Unlock()
+	}
+}
+
+//检查从库, 未连接时尝试重新连接; 连接和 ping 在锁外进行并有超时, 不阻塞读请求取从库
+func (r *replica) check() {
+	r.RLock()
+	db, wasHealthy := r.db, r.healthy
+	r.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+	defer cancel()
+
+	var err error
+	opened := false
+	if db == nil {
+		db, err = r.open(ctx)
+		opened = err == nil
+	} else {
+		err = db.DB().PingContext(ctx)
+	}
+
+	if err != nil && wasHealthy {
+		beego.Warn("db replica", r.host, "unavailable:", err)
+	} else if err == nil && !wasHealthy {
+		beego.Info("db replica", r.host, "available")
+	}
+
+	r.Lock()
+	if opened {
+		if r.db != nil || r.closed {
+			//检查期间已关闭或已连接, 丢弃本次连接
+			r.Unlock()
+			db.Close()
+			return
+		}
+		r.db = db
+	}
+	r.healthy = err == nil && r.db != nil
+	r.Unlock()
+}
+
+//连接从库并在 ctx 超时内 ping
+func (r *replica) open(ctx context.Context) (*gorm.DB, error) {
+	sqlDb, err := sql.Open(r.cfg.driver, r.dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = sqlDb.PingContext(ctx); err != nil {
+		sqlDb.Close()
+		return nil, err
+	}
+	db, err := gorm.Open(r.cfg.driver, sqlDb)
+	if err != nil {
+		sqlDb.Close()
+		return nil, err
+	}
+	r.cfg.configurePool(db.DB())
+	applyQueryLog(db, "")
+	registerContextCallbacks(db)
+	return withConnName(db, r.name), nil
+}
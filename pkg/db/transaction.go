@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	//事务嵌套层数, 用于生成 savepoint 名称
+	txDepthSetting = "bee:tx_depth"
+	//事务提交后执行的函数
+	afterCommitSetting = "bee:after_commit"
+)
+
+//ctx 中事务的键, 按连接名区分
+type txContextKey struct {
+	name string
+}
+
+//已开启事务的连接
+type sqlTx interface {
+	Commit() error
+	Rollback() error
+}
+
+//在默认连接上开启事务执行 fn, fn 返回错误或 panic 时回滚, 否则提交
+//	err := db.Transaction(func(tx *gorm.DB) error {
+//		if err := OrderModel.AddOn(tx, order); err != nil {
+//			return err
+//		}
+//		return StockModel.UpdateOn(tx, stock)
+//	})
+func Transaction(fn func(tx *gorm.DB) error) error {
+	return TransactionOn(Conn, fn)
+}
+
+//在指定连接上开启事务执行 fn, 如 db.TransactionOn(db.Use("report"), fn)
+//conn 已在事务中时使用 savepoint 嵌套, fn 出错只回滚到 savepoint, 由外层事务决定提交或回滚
+//panic 时回滚后继续 panic
+func TransactionOn(conn *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	if InTransaction(conn) {
+		return savepoint(conn, fn)
+	}
+
+	if ctx := ContextFromConn(conn); ctx != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	tx := conn.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	afterCommit := &[]func(){}
+	tx = tx.Set(txDepthSetting, 0).Set(afterCommitSetting, afterCommit)
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback().Error; rbErr != nil {
+			return fmt.Errorf("%s (rollback failed: %s)", err, rbErr)
+		}
+		return err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return err
+	}
+	for _, f := range *afterCommit {
+		f()
+	}
+	return nil
+}
+
+//嵌套事务, 使用 savepoint
+func savepoint(tx *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	depth := 0
+	if v, ok := tx.Get(txDepthSetting); ok {
+		depth = v.(int)
+	}
+	depth++
+	name := fmt.Sprintf("bee_sp_%d", depth)
+	if err = tx.Exec("SAVEPOINT " + name).Error; err != nil {
+		return err
+	}
+	tx = tx.Set(txDepthSetting, depth)
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Exec("ROLLBACK TO SAVEPOINT " + name)
+			panic(r)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Exec("ROLLBACK TO SAVEPOINT " + name).Error; rbErr != nil {
+			return fmt.Errorf("%s (rollback failed: %s)", err, rbErr)
+		}
+		return err
+	}
+	return tx.Exec("RELEASE SAVEPOINT " + name).Error
+}
+
+//conn 是否在事务中
+func InTransaction(conn *gorm.DB) bool {
+	_, ok := conn.CommonDB().(sqlTx)
+	return ok
+}
+
+//conn 在事务中时, 最外层事务提交后执行 fn, 回滚时不执行; 不在事务中时立即执行
+//用于删除缓存、发送消息等需要在数据可见后进行的操作
+func AfterCommit(conn *gorm.DB, fn func()) {
+	if v, ok := conn.Get(afterCommitSetting); ok && InTransaction(conn) {
+		fns := v.(*[]func())
+		*fns = append(*fns, fn)
+		return
+	}
+	fn()
+}
+
+//在 ctx 中默认连接的事务或默认连接上开启事务, fn 的 ctx 携带事务, 传给默认连接上模型的 *Ctx 函数即在同一事务中执行
+//	err := db.TransactionContext(ctx, func(ctx context.Context) error {
+//		if err := OrderModel.AddCtx(ctx, order); err != nil {
+//			return err
+//		}
+//		return StockModel.UpdateCtx(ctx, stock)
+//	})
+func TransactionContext(ctx context.Context, fn func(ctx context.Context) error) error {
+	return TransactionContextOn(ctx, "", fn)
+}
+
+//在命名连接上开启事务, 同 TransactionContext; ctx 中其它连接的事务不受影响
+func TransactionContextOn(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	conn, err := Open(name)
+	if err != nil {
+		return err
+	}
+	return TransactionOn(ConnFromContext(ctx, conn), func(tx *gorm.DB) error {
+		return fn(WithTx(ctx, tx))
+	})
+}
+
+//返回携带事务的 ctx, 事务按所属连接保存, 只用于同一连接上的查询
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txContextKey{ConnName(tx)}, tx)
+}
+
+//取 ctx 中命名连接的事务, 空名称或 default 为默认连接
+func TxFromContext(ctx context.Context, name string) (*gorm.DB, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	tx, ok := ctx.Value(txContextKey{connName(name)}).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+//ctx 中有 conn 所属连接的事务时返回事务, 否则返回 conn; 返回的连接使用 ctx 执行查询, 见 WithContext
+//其它连接的事务不会用于 conn, 从库连接使用所属主库的事务
+func ConnFromContext(ctx context.Context, conn *gorm.DB) *gorm.DB {
+	if name := ConnName(conn); name != "" {
+		if tx, ok := TxFromContext(ctx, name); ok {
+			return WithContext(ctx, tx)
+		}
+	}
+	return WithContext(ctx, conn)
+}
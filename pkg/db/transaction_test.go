@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConnFromContext(t *testing.T) {
+	def := withConnName(testConn(), "")
+	report := withConnName(testConn(), "report")
+	defTx := withConnName(testConn(), "default")
+	reportTx := withConnName(testConn(), "report")
+
+	if ConnName(def) != "default" || ConnName(report) != "report" || ConnName(testConn()) != "" {
+		t.Fatalf("ConnName = %q %q %q, want default report empty", ConnName(def), ConnName(report), ConnName(testConn()))
+	}
+
+	ctx := WithTx(context.Background(), defTx)
+	if got := ConnFromContext(ctx, report); got.CommonDB() != report.DB() {
+		t.Errorf("ConnFromContext on report uses the default connection's transaction")
+	}
+	if got := ConnFromContext(ctx, def); got.CommonDB() != defTx.DB() {
+		t.Errorf("ConnFromContext on default doesn't use the default connection's transaction")
+	}
+	if got := ConnFromContext(ctx, testConn()); got.CommonDB() == defTx.DB() {
+		t.Errorf("ConnFromContext on an unnamed connection uses the default connection's transaction")
+	}
+
+	ctx = WithTx(ctx, reportTx)
+	if got := ConnFromContext(ctx, report); got.CommonDB() != reportTx.DB() {
+		t.Errorf("ConnFromContext on report doesn't use the report transaction")
+	}
+	if got := ConnFromContext(ctx, def); got.CommonDB() != defTx.DB() {
+		t.Errorf("ConnFromContext on default uses the report transaction")
+	}
+	if ConnName(ConnFromContext(ctx, report)) != "report" {
+		t.Errorf("ConnFromContext loses the connection name")
+	}
+	if tx, ok := TxFromContext(ctx, ""); !ok || tx != defTx {
+		t.Errorf("TxFromContext(default) = %v, %v", tx, ok)
+	}
+	if _, ok := TxFromContext(context.Background(), "report"); ok {
+		t.Errorf("TxFromContext without transaction want false")
+	}
+}
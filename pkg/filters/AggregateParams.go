@@ -0,0 +1,113 @@
+package filters
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//聚合函数
+const (
+	AggCount = "count"
+	AggSum   = "sum"
+	AggAvg   = "avg"
+	AggMin   = "min"
+	AggMax   = "max"
+)
+
+//时间分段
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketMonth = "month"
+	BucketYear  = "year"
+)
+
+var aggFieldRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+//聚合查询最多返回的行数, 未指定 limit 时不使用列表默认的 10 条, 按此上限返回
+var MaxAggregateRows int64 = 10000
+
+//聚合查询的 limit: 请求中指定且不超过 MaxAggregateRows 时使用指定值, 否则为 MaxAggregateRows
+func (p *PageCommonParams) AggregateLimit() int64 {
+	if !p.LimitSet || p.Limits < 0 || p.Limits > MaxAggregateRows {
+		return MaxAggregateRows
+	}
+	return p.Limits
+}
+
+// Aggregate 聚合项, 如 sum:amount
+type Aggregate struct {
+	Func  string
+	Field string //count 可为 *
+}
+
+//结果中的字段名, 如 count、sum_amount
+func (a Aggregate) Alias() string {
+	if a.Field == "*" {
+		return a.Func
+	}
+	return a.Func + "_" + a.Field
+}
+
+// GroupField 分组字段, 时间字段可按 Bucket 分段, 如 created_at:day
+type GroupField struct {
+	Field  string
+	Bucket string
+}
+
+//结果中的字段名, 如 status、created_at_day
+func (g GroupField) Alias() string {
+	if g.Bucket == "" {
+		return g.Field
+	}
+	return g.Field + "_" + g.Bucket
+}
+
+//解析 aggregate=count:*,sum:amount,avg:price
+func ParseAggregates(v string) ([]Aggregate, error) {
+	var aggs []Aggregate
+	for _, item := range strings.Split(v, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Error: invalid aggregate %q, e.g. sum:amount", item)
+		}
+		agg := Aggregate{Func: strings.ToLower(kv[0]), Field: kv[1]}
+		switch agg.Func {
+		case AggCount:
+			if agg.Field != "*" && !aggFieldRegex.MatchString(agg.Field) {
+				return nil, fmt.Errorf("Error: invalid aggregate field: %s", agg.Field)
+			}
+		case AggSum, AggAvg, AggMin, AggMax:
+			if !aggFieldRegex.MatchString(agg.Field) {
+				return nil, fmt.Errorf("Error: invalid aggregate field: %s", agg.Field)
+			}
+		default:
+			return nil, fmt.Errorf("Error: invalid aggregate func %q, must be count, sum, avg, min or max", agg.Func)
+		}
+		aggs = append(aggs, agg)
+	}
+	return aggs, nil
+}
+
+//解析 group_by=status,created_at:day
+func ParseGroupBy(v string) ([]GroupField, error) {
+	var groups []GroupField
+	for _, item := range strings.Split(v, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		group := GroupField{Field: kv[0]}
+		if !aggFieldRegex.MatchString(group.Field) {
+			return nil, fmt.Errorf("Error: invalid group_by field: %s", group.Field)
+		}
+		if len(kv) == 2 {
+			group.Bucket = strings.ToLower(kv[1])
+			switch group.Bucket {
+			case BucketHour, BucketDay, BucketMonth, BucketYear:
+			default:
+				return nil, fmt.Errorf("Error: invalid group_by bucket %q, must be hour, day, month or year", kv[1])
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
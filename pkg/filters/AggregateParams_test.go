@@ -0,0 +1,92 @@
+package filters
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAggregates(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []Aggregate
+		err  bool
+	}{
+		{in: "count:*", want: []Aggregate{{AggCount, "*"}}},
+		{in: "COUNT:id, sum:amount,avg:price", want: []Aggregate{{AggCount, "id"}, {AggSum, "amount"}, {AggAvg, "price"}}},
+		{in: "min:created_at,max:created_at", want: []Aggregate{{AggMin, "created_at"}, {AggMax, "created_at"}}},
+		{in: "sum:*", err: true},
+		{in: "sum", err: true},
+		{in: "sum:", err: true},
+		{in: "median:price", err: true},
+		{in: "sum:amount)", err: true},
+		{in: "count:1 or 1=1", err: true},
+		{in: "sum:a.b", err: true},
+		{in: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAggregates(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseAggregates(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAggregates(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	if alias := (Aggregate{AggCount, "*"}).Alias(); alias != "count" {
+		t.Errorf("count:* alias = %s, want count", alias)
+	}
+	if alias := (Aggregate{AggSum, "amount"}).Alias(); alias != "sum_amount" {
+		t.Errorf("sum:amount alias = %s, want sum_amount", alias)
+	}
+}
+
+func TestParseGroupBy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []GroupField
+		err  bool
+	}{
+		{in: "status", want: []GroupField{{Field: "status"}}},
+		{in: "status, created_at:DAY", want: []GroupField{{Field: "status"}, {"created_at", BucketDay}}},
+		{in: "a:hour,b:month,c:year", want: []GroupField{{"a", BucketHour}, {"b", BucketMonth}, {"c", BucketYear}}},
+		{in: "created_at:week", err: true},
+		{in: "created_at:", err: true},
+		{in: "1status", err: true},
+		{in: "status;drop", err: true},
+		{in: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGroupBy(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseGroupBy(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseGroupBy(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	if alias := (GroupField{"created_at", BucketDay}).Alias(); alias != "created_at_day" {
+		t.Errorf("created_at:day alias = %s, want created_at_day", alias)
+	}
+}
+
+func TestAggregateLimit(t *testing.T) {
+	tests := []struct {
+		p    PageCommonParams
+		want int64
+	}{
+		{p: PageCommonParams{Limits: 10}, want: MaxAggregateRows},
+		{p: PageCommonParams{Limits: 31, LimitSet: true}, want: 31},
+		{p: PageCommonParams{Limits: 0, LimitSet: true}, want: 0},
+		{p: PageCommonParams{Limits: -1, LimitSet: true}, want: MaxAggregateRows},
+		{p: PageCommonParams{Limits: MaxAggregateRows + 1, LimitSet: true}, want: MaxAggregateRows},
+	}
+	for _, tt := range tests {
+		if got := tt.p.AggregateLimit(); got != tt.want {
+			t.Errorf("AggregateLimit(limit=%d, set=%v) = %d, want %d", tt.p.Limits, tt.p.LimitSet, got, tt.want)
+		}
+	}
+}
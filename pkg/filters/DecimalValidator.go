@@ -0,0 +1,41 @@
+package filters
+
+import (
+	"fmt"
+
+	"github.com/yimishiji/bee/pkg/types"
+)
+
+// DecimalPrecision 验证定点小数能否存入 decimal(Digits, Decimals) 字段，
+// 用法 valid.Check(v.Amount, filters.DecimalPrecision{Digits: 10, Decimals: 2, Key: "amount"})
+type DecimalPrecision struct {
+	Digits   int
+	Decimals int
+	Key      string
+}
+
+// IsSatisfied 整数位和小数位是否超出限制, 空值视为通过
+func (d DecimalPrecision) IsSatisfied(obj interface{}) bool {
+	switch v := obj.(type) {
+	case types.Decimal:
+		return v.FitsPrecision(d.Digits, d.Decimals)
+	case types.NullDecimal:
+		return !v.Valid || v.FitsPrecision(d.Digits, d.Decimals)
+	}
+	return false
+}
+
+// DefaultMessage 默认错误信息
+func (d DecimalPrecision) DefaultMessage() string {
+	return fmt.Sprintf("Must be a decimal with at most %d digits and %d decimals", d.Digits, d.Decimals)
+}
+
+// GetKey 字段名
+func (d DecimalPrecision) GetKey() string {
+	return d.Key
+}
+
+// GetLimitValue 精度限制
+func (d DecimalPrecision) GetLimitValue() interface{} {
+	return [2]int{d.Digits, d.Decimals}
+}
@@ -0,0 +1,487 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//条件组与比较操作符
+const (
+	OpAnd     = "and"
+	OpOr      = "or"
+	OpNot     = "not"
+	OpEq      = "eq"
+	OpNe      = "ne"
+	OpGt      = "gt"
+	OpGe      = "ge"
+	OpLt      = "lt"
+	OpLe      = "le"
+	OpIn      = "in"
+	OpBetween = "between"
+	OpLike    = "like"
+	OpIsNull  = "isnull"
+	OpNotNull = "notnull"
+)
+
+//筛选条件的节点数和层数上限
+const (
+	maxConditionNodes = 100
+	maxConditionDepth = 10
+)
+
+// Condition 筛选条件语法树
+// Op 为 and/or/not 时是条件组, 子条件在 Conditions 中, not 只有一个子条件
+// 其它 Op 为已注册的操作符, ArityList 的 Value 为 []interface{}, ArityPair 为两个元素的 []interface{}
+type Condition struct {
+	Op         string
+	Field      string
+	Value      interface{}
+	Conditions []*Condition
+}
+
+//是否是条件组
+func (c *Condition) IsGroup() bool {
+	return c.Op == OpAnd || c.Op == OpOr || c.Op == OpNot
+}
+
+//校验条件树, 检查操作符、参数个数、节点数和层数
+func (c *Condition) Validate() error {
+	nodes := 0
+	return c.validate(1, &nodes)
+}
+
+func (c *Condition) validate(depth int, nodes *int) error {
+	*nodes++
+	if *nodes > maxConditionNodes {
+		return fmt.Errorf("filter has more than %d conditions", maxConditionNodes)
+	}
+	if depth > maxConditionDepth {
+		return fmt.Errorf("filter is nested deeper than %d levels", maxConditionDepth)
+	}
+
+	switch c.Op {
+	case OpAnd, OpOr:
+		if len(c.Conditions) == 0 {
+			return fmt.Errorf("filter %s group is empty", c.Op)
+		}
+	case OpNot:
+		if len(c.Conditions) != 1 {
+			return errors.New("filter not group must have exactly one condition")
+		}
+	default:
+		spec, ok := LookupOperatorSpec(c.Op)
+		if !ok {
+			return fmt.Errorf("unknown filter operator: %s", c.Op)
+		}
+		l, isList := c.Value.([]interface{})
+		switch spec.Arity {
+		case ArityOne:
+			if c.Value == nil || isList {
+				return fmt.Errorf("filter %s on %s needs a single value", c.Op, c.Field)
+			}
+		case ArityList:
+			if !isList || len(l) == 0 {
+				return fmt.Errorf("filter %s on %s needs a list of values", c.Op, c.Field)
+			}
+		case ArityPair:
+			if !isList || len(l) != 2 {
+				return fmt.Errorf("filter %s on %s needs two values", c.Op, c.Field)
+			}
+		}
+	}
+
+	if c.IsGroup() {
+		for _, sub := range c.Conditions {
+			if sub == nil {
+				return errors.New("filter has an empty condition")
+			}
+			if err := sub.validate(depth+1, nodes); err != nil {
+				return err
+			}
+		}
+	} else if c.Field == "" {
+		return fmt.Errorf("filter %s needs a field", c.Op)
+	}
+	return nil
+}
+
+//解析 filter 参数, 以 { 开头的按json解析, 否则按RSQL解析
+func ParseFilter(s string) (*Condition, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	var cond *Condition
+	var err error
+	if strings.HasPrefix(s, "{") {
+		cond, err = ParseFilterJSON([]byte(s))
+	} else {
+		cond, err = ParseFilterRSQL(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cond.Validate(); err != nil {
+		return nil, err
+	}
+	return cond, nil
+}
+
+// ParseFilterJSON 解析json格式的筛选条件
+//	{"and": [{"field": "status", "op": "eq", "value": 1}, {"or": [...]}, {"not": {...}}]}
+func ParseFilterJSON(data []byte) (*Condition, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var node interface{}
+	if err := dec.Decode(&node); err != nil {
+		return nil, fmt.Errorf("invalid filter json: %s", err)
+	}
+	return jsonCondition(node)
+}
+
+func jsonCondition(node interface{}) (*Condition, error) {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid filter json: condition must be an object")
+	}
+
+	for _, op := range []string{OpAnd, OpOr} {
+		if v, ok := m[op]; ok {
+			l, ok := v.([]interface{})
+			if !ok || len(m) != 1 {
+				return nil, fmt.Errorf("invalid filter json: %s must be the only key with a list of conditions", op)
+			}
+			group := &Condition{Op: op}
+			for _, item := range l {
+				sub, err := jsonCondition(item)
+				if err != nil {
+					return nil, err
+				}
+				group.Conditions = append(group.Conditions, sub)
+			}
+			return group, nil
+		}
+	}
+	if v, ok := m[OpNot]; ok {
+		if len(m) != 1 {
+			return nil, errors.New("invalid filter json: not must be the only key")
+		}
+		sub, err := jsonCondition(v)
+		if err != nil {
+			return nil, err
+		}
+		return &Condition{Op: OpNot, Conditions: []*Condition{sub}}, nil
+	}
+
+	field, _ := m["field"].(string)
+	op, _ := m["op"].(string)
+	if field == "" || op == "" {
+		return nil, errors.New("invalid filter json: condition needs field and op")
+	}
+	return &Condition{Op: strings.ToLower(op), Field: field, Value: jsonValue(m["value"])}, nil
+}
+
+//json.Number 转为 int64 或 float64
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case []interface{}:
+		for i := range t {
+			t[i] = jsonValue(t[i])
+		}
+	}
+	return v
+}
+
+//RSQL比较符的简写, 长的写在前面; 其它操作符写作 =name=
+var rsqlOperators = []struct {
+	token string
+	op    string
+}{
+	{"==", OpEq},
+	{"!=", OpNe},
+	{">=", OpGe},
+	{"<=", OpLe},
+	{">", OpGt},
+	{"<", OpLt},
+}
+
+var rsqlNamedOperator = regexp.MustCompile(`^=([a-z][a-z0-9_-]*)=`)
+
+// ParseFilterRSQL 解析RSQL格式的筛选条件
+// ; 为 and, , 为 or, 括号分组, !(...) 为 not
+// 值含有特殊字符时用单引号或双引号括起来, 引号内用 \ 转义; in/between 的值写成 (a,b)
+//	status==1;(type=in=(a,b),price=between=(10,20));!(name=like='a,b');deleted_at=isnull=
+func ParseFilterRSQL(s string) (*Condition, error) {
+	p := &rsqlParser{s: s}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return cond, nil
+}
+
+type rsqlParser struct {
+	s   string
+	pos int
+}
+
+func (p *rsqlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid filter at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *rsqlParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *rsqlParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+// or := and {"," and}
+func (p *rsqlParser) parseOr() (*Condition, error) {
+	return p.parseList(',', OpOr, p.parseAnd)
+}
+
+// and := term {";" term}
+func (p *rsqlParser) parseAnd() (*Condition, error) {
+	return p.parseList(';', OpAnd, p.parseTerm)
+}
+
+func (p *rsqlParser) parseList(sep byte, op string, next func() (*Condition, error)) (*Condition, error) {
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	group := &Condition{Op: op, Conditions: []*Condition{first}}
+	for p.peek() == sep {
+		p.pos++
+		cond, err := next()
+		if err != nil {
+			return nil, err
+		}
+		group.Conditions = append(group.Conditions, cond)
+	}
+	if len(group.Conditions) == 1 {
+		return first, nil
+	}
+	return group, nil
+}
+
+// term := "(" or ")" | "!(" or ")" | comparison
+func (p *rsqlParser) parseTerm() (*Condition, error) {
+	switch p.peek() {
+	case '!':
+		p.pos++
+		if p.peek() != '(' {
+			return nil, p.errorf("expected ( after !")
+		}
+		sub, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		return &Condition{Op: OpNot, Conditions: []*Condition{sub}}, nil
+	case '(':
+		p.pos++
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("expected )")
+		}
+		p.pos++
+		return cond, nil
+	}
+	return p.parseComparison()
+}
+
+// comparison := field operator [value | "(" value {"," value} ")"]
+func (p *rsqlParser) parseComparison() (*Condition, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) {
+		//json字段路径 extra->address.city
+		if strings.HasPrefix(p.s[p.pos:], "->") {
+			p.pos += 2
+			continue
+		}
+		if strings.ContainsRune("=!<>;,()'\" ", rune(p.s[p.pos])) {
+			break
+		}
+		p.pos++
+	}
+	field := p.s[start:p.pos]
+	if field == "" {
+		return nil, p.errorf("expected field name")
+	}
+
+	cond := &Condition{Field: field}
+	if m := rsqlNamedOperator.FindStringSubmatch(p.s[p.pos:]); m != nil {
+		cond.Op = m[1]
+		p.pos += len(m[0])
+	} else {
+		for _, o := range rsqlOperators {
+			if strings.HasPrefix(p.s[p.pos:], o.token) {
+				cond.Op = o.op
+				p.pos += len(o.token)
+				break
+			}
+		}
+	}
+	if cond.Op == "" {
+		return nil, p.errorf("expected operator after %s", field)
+	}
+	spec, ok := LookupOperatorSpec(cond.Op)
+	if !ok {
+		return nil, p.errorf("unknown operator %s", cond.Op)
+	}
+
+	switch spec.Arity {
+	case ArityNone:
+		//值可省略, =isnull=true 与 =isnull= 相同, =isnull=false 等同 notnull
+		if c := p.peek(); c != 0 && c != ';' && c != ',' && c != ')' {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, p.errorf("%s expects true or false", cond.Op)
+			}
+			if !b {
+				negated, ok := map[string]string{OpIsNull: OpNotNull, OpNotNull: OpIsNull}[cond.Op]
+				if !ok {
+					return &Condition{Op: OpNot, Conditions: []*Condition{cond}}, nil
+				}
+				cond.Op = negated
+			}
+		}
+		return cond, nil
+	case ArityList, ArityPair:
+		if p.peek() != '(' {
+			return nil, p.errorf("%s on %s expects (a,b)", cond.Op, field)
+		}
+		p.pos++
+		var values []interface{}
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if p.peek() == ',' {
+				p.pos++
+				continue
+			}
+			if p.peek() != ')' {
+				return nil, p.errorf("expected ) after %s values", cond.Op)
+			}
+			p.pos++
+			break
+		}
+		cond.Value = values
+		return cond, nil
+	}
+
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	cond.Value = v
+	return cond, nil
+}
+
+//值, 引号内的字符原样保留, \ 转义下一个字符
+func (p *rsqlParser) parseValue() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return "", p.errorf("expected value")
+	}
+
+	if q := p.s[p.pos]; q == '\'' || q == '"' {
+		p.pos++
+		var buf bytes.Buffer
+		for p.pos < len(p.s) {
+			c := p.s[p.pos]
+			p.pos++
+			if c == '\\' && p.pos < len(p.s) {
+				buf.WriteByte(p.s[p.pos])
+				p.pos++
+				continue
+			}
+			if c == q {
+				return buf.String(), nil
+			}
+			buf.WriteByte(c)
+		}
+		return "", p.errorf("unterminated quoted value")
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(";,()'\"", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	v := strings.TrimSpace(p.s[start:p.pos])
+	if v == "" {
+		return "", p.errorf("expected value")
+	}
+	return v, nil
+}
+
+//将 query=k:v,k:v 参数转为条件树, 兼容原有写法, 值保持字符串, 编译时按字段类型转换
+//	col-isnull:  col-notnull:  col:>=1  col:<=1  col:>1  col:<1  col:!=1  col:<>1  col:like-a
+//	col:between-1-9  col:between-2019-01-01~2019-01-31  col:in-a-b  col:a
+//已注册的自定义操作符按其前缀匹配
+func QueryCondition(query map[string]string) *Condition {
+	if len(query) == 0 {
+		return nil
+	}
+
+	group := &Condition{Op: OpAnd}
+	for k, v := range query {
+		cond := &Condition{Field: k}
+		if strings.HasSuffix(k, "-isnull") {
+			cond.Op, cond.Field = OpIsNull, strings.TrimSuffix(k, "-isnull")
+		} else if strings.HasSuffix(k, "-notnull") {
+			cond.Op, cond.Field = OpNotNull, strings.TrimSuffix(k, "-notnull")
+		} else if spec, raw, ok := matchOperatorPrefix(v); ok {
+			cond.Op = spec.Name
+			switch spec.Arity {
+			case ArityOne:
+				cond.Value = raw
+			case ArityList, ArityPair:
+				var values []interface{}
+				for _, item := range spec.splitRaw(raw) {
+					values = append(values, item)
+				}
+				cond.Value = values
+			}
+		} else {
+			cond.Op, cond.Value = OpEq, v
+		}
+		group.Conditions = append(group.Conditions, cond)
+	}
+	return group
+}
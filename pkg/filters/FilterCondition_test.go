@@ -0,0 +1,219 @@
+package filters
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+//条件树的简写, 如 and(eq(status,1),not(isnull(deleted_at)))
+func formatCondition(c *Condition) string {
+	if c == nil {
+		return "<nil>"
+	}
+	if c.IsGroup() {
+		var subs []string
+		for _, sub := range c.Conditions {
+			subs = append(subs, formatCondition(sub))
+		}
+		return c.Op + "(" + strings.Join(subs, ",") + ")"
+	}
+	switch v := c.Value.(type) {
+	case nil:
+		return c.Op + "(" + c.Field + ")"
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			items = append(items, fmt.Sprintf("%v", item))
+		}
+		return c.Op + "(" + c.Field + ",[" + strings.Join(items, " ") + "])"
+	}
+	return fmt.Sprintf("%s(%s,%v)", c.Op, c.Field, c.Value)
+}
+
+func TestParseFilterRSQL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "status==1", want: "eq(status,1)"},
+		{in: "status!=1", want: "ne(status,1)"},
+		{in: "price>=10;price<20", want: "and(ge(price,10),lt(price,20))"},
+		{in: "a==1,b==2;c==3", want: "or(eq(a,1),and(eq(b,2),eq(c,3)))"},
+		{in: "(a==1,b==2);c==3", want: "and(or(eq(a,1),eq(b,2)),eq(c,3))"},
+		{in: "!(name=like='a,b')", want: "not(like(name,a,b))"},
+		{in: "type=in=(a,b,c)", want: "in(type,[a b c])"},
+		{in: "price=between=(10,20)", want: "between(price,[10 20])"},
+		{in: "deleted_at=isnull=", want: "isnull(deleted_at)"},
+		{in: "deleted_at=isnull=true", want: "isnull(deleted_at)"},
+		{in: "deleted_at=isnull=false", want: "notnull(deleted_at)"},
+		{in: `name=="it\"s"`, want: `eq(name,it"s)`},
+		{in: "extra->address.city==sh", want: "eq(extra->address.city,sh)"},
+		{in: "Member.mobile==138", want: "eq(Member.mobile,138)"},
+		{in: " a==1 ; b== 2 ", want: "and(eq(a,1),eq(b,2))"},
+		{in: "a ==1", err: true},
+		{in: "", err: true},
+		{in: "status", err: true},
+		{in: "status==", err: true},
+		{in: "status=foo=1", err: true},
+		{in: "(a==1", err: true},
+		{in: "a==1)", err: true},
+		{in: "!a==1", err: true},
+		{in: "type=in=a", err: true},
+		{in: "name=='abc", err: true},
+		{in: "deleted_at=isnull=maybe", err: true},
+	}
+	for _, tt := range tests {
+		cond, err := ParseFilterRSQL(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseFilterRSQL(%q) = %s, want error", tt.in, formatCondition(cond))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseFilterRSQL(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got := formatCondition(cond); got != tt.want {
+			t.Errorf("ParseFilterRSQL(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: `{"field": "status", "op": "eq", "value": 1}`, want: "eq(status,1)"},
+		{in: `{"field": "price", "op": "GE", "value": 1.5}`, want: "ge(price,1.5)"},
+		{in: `{"and": [{"field": "a", "op": "eq", "value": "x"}, {"or": [{"field": "b", "op": "lt", "value": 2}, {"not": {"field": "c", "op": "isnull"}}]}]}`,
+			want: "and(eq(a,x),or(lt(b,2),not(isnull(c))))"},
+		{in: `{"field": "type", "op": "in", "value": [1, "b"]}`, want: "in(type,[1 b])"},
+		{in: `[]`, err: true},
+		{in: `{"field": "a"}`, err: true},
+		{in: `{"and": {"field": "a", "op": "eq", "value": 1}}`, err: true},
+		{in: `{"and": [], "field": "a"}`, err: true},
+		{in: `{"not": {"field": "a", "op": "eq", "value": 1}, "or": []}`, err: true},
+		{in: `{"field": `, err: true},
+	}
+	for _, tt := range tests {
+		cond, err := ParseFilterJSON([]byte(tt.in))
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseFilterJSON(%s) = %s, want error", tt.in, formatCondition(cond))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseFilterJSON(%s) error: %v", tt.in, err)
+			continue
+		}
+		if got := formatCondition(cond); got != tt.want {
+			t.Errorf("ParseFilterJSON(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	//json 数字为 int64 或 float64
+	cond, _ := ParseFilterJSON([]byte(`{"field": "a", "op": "in", "value": [1, 1.5]}`))
+	if l := cond.Value.([]interface{}); reflect.TypeOf(l[0]).Kind() != reflect.Int64 || reflect.TypeOf(l[1]).Kind() != reflect.Float64 {
+		t.Errorf("json numbers = %T %T, want int64 float64", l[0], l[1])
+	}
+}
+
+func TestParseFilterValidate(t *testing.T) {
+	tests := []struct {
+		in  string
+		err bool
+	}{
+		{in: "a==1"},
+		{in: ""},
+		{in: `{"field": "a", "op": "between", "value": [1]}`, err: true},
+		{in: `{"field": "a", "op": "in", "value": []}`, err: true},
+		{in: `{"field": "a", "op": "eq"}`, err: true},
+		{in: `{"field": "a", "op": "nope", "value": 1}`, err: true},
+		{in: `{"and": []}`, err: true},
+		{in: strings.Repeat("(", 11) + "a==1" + strings.Repeat(")", 11)},
+		{in: strings.Repeat("!(", 11) + "a==1" + strings.Repeat(")", 11), err: true},
+		{in: strings.TrimSuffix(strings.Repeat("a==1;", 101), ";"), err: true},
+	}
+	for _, tt := range tests {
+		_, err := ParseFilter(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseFilter(%q) error = %v, want error %v", tt.in, err, tt.err)
+		}
+	}
+}
+
+func TestSplitPair(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"10-20", []string{"10", "20"}},
+		{"2019-01-01~2019-01-31", []string{"2019-01-01", "2019-01-31"}},
+		{"2019-01-01-2019-01-31", []string{"2019-01-01", "2019-01-31"}},
+		{"a~b~c", []string{"a", "b~c"}},
+		{"10", []string{"10"}},
+		{"1-2-3", []string{"1-2-3"}},
+		{"-5-10", []string{"-5", "10"}},
+		{"1--5", []string{"1", "-5"}},
+		{"-10--5", []string{"-10", "-5"}},
+		{"-1.5-2.5", []string{"-1.5", "2.5"}},
+		{"1e-5-2", []string{"1e-5", "2"}},
+		{"-5~-1", []string{"-5", "-1"}},
+		{"a-b", []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		if got := SplitPair(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitPair(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQueryCondition(t *testing.T) {
+	if QueryCondition(nil) != nil {
+		t.Errorf("QueryCondition(nil) want nil")
+	}
+	tests := []struct {
+		key, value string
+		want       string
+	}{
+		{"status", "1", "eq(status,1)"},
+		{"price", ">=10", "ge(price,10)"},
+		{"price", ">10", "gt(price,10)"},
+		{"price", "<=10", "le(price,10)"},
+		{"price", "<10", "lt(price,10)"},
+		{"status", "!=1", "ne(status,1)"},
+		{"status", "<>1", "ne(status,1)"},
+		{"name", "like-ab", "like(name,ab)"},
+		{"type", "in-a-b-c", "in(type,[a b c])"},
+		{"price", "between-1-9", "between(price,[1 9])"},
+		{"price", "between--5-10", "between(price,[-5 10])"},
+		{"price", "between-1--5", "between(price,[1 -5])"},
+		{"day", "between-2019-01-01~2019-01-31", "between(day,[2019-01-01 2019-01-31])"},
+		{"deleted_at-isnull", "", "isnull(deleted_at)"},
+		{"deleted_at-notnull", "", "notnull(deleted_at)"},
+	}
+	for _, tt := range tests {
+		cond := QueryCondition(map[string]string{tt.key: tt.value})
+		if got := formatCondition(cond); got != "and("+tt.want+")" {
+			t.Errorf("QueryCondition(%s:%s) = %s, want and(%s)", tt.key, tt.value, got, tt.want)
+		}
+	}
+
+	cond := QueryCondition(map[string]string{"a": "1", "b": ">2"})
+	var got []string
+	for _, sub := range cond.Conditions {
+		got = append(got, formatCondition(sub))
+	}
+	sort.Strings(got)
+	if want := []string{"eq(a,1)", "gt(b,2)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryCondition = %v, want %v", got, want)
+	}
+}
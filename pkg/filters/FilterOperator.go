@@ -0,0 +1,147 @@
+package filters
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Arity 操作符需要的值个数
+type Arity int
+
+const (
+	ArityNone Arity = iota // 不需要值, 如 isnull
+	ArityOne               // 一个值, 如 eq
+	ArityList              // 一个或多个值, 如 in
+	ArityPair              // 两个值, 如 between
+)
+
+// OperatorSpec 操作符的写法, 用于解析和校验 filter、query 参数
+type OperatorSpec struct {
+	//名称, filter 中使用, RSQL 写作 =name=
+	Name  string
+	Arity Arity
+	//query=k:v 写法中值的前缀, 如 between-, 为空时不支持该写法
+	Prefixes []string
+	//query 写法中将去掉前缀的值拆为多个值, 为空时 ArityList 按 - 拆分, ArityPair 见 SplitPair
+	Split func(raw string) []string
+	//生成 sql 的实现, 由 db.RegisterOperator 设置为 db.Operator; 操作符只在这里注册一次, 解析和编译使用同一份
+	Impl interface{}
+}
+
+var (
+	operatorSpecs   = map[string]OperatorSpec{}
+	operatorSpecsMu sync.RWMutex
+	operatorNameReg = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+)
+
+func init() {
+	for _, spec := range []OperatorSpec{
+		{Name: OpEq, Arity: ArityOne},
+		{Name: OpNe, Arity: ArityOne, Prefixes: []string{"!=", "<>"}},
+		{Name: OpGt, Arity: ArityOne, Prefixes: []string{">"}},
+		{Name: OpGe, Arity: ArityOne, Prefixes: []string{">="}},
+		{Name: OpLt, Arity: ArityOne, Prefixes: []string{"<"}},
+		{Name: OpLe, Arity: ArityOne, Prefixes: []string{"<="}},
+		{Name: OpIn, Arity: ArityList, Prefixes: []string{"in-"}},
+		{Name: OpBetween, Arity: ArityPair, Prefixes: []string{"between-"}},
+		{Name: OpLike, Arity: ArityOne, Prefixes: []string{"like-"}},
+		{Name: OpIsNull, Arity: ArityNone},
+		{Name: OpNotNull, Arity: ArityNone},
+	} {
+		RegisterOperatorSpec(spec)
+	}
+}
+
+//注册操作符写法, 同名的覆盖; 一般通过 db.RegisterOperator 注册
+func RegisterOperatorSpec(spec OperatorSpec) {
+	if !operatorNameReg.MatchString(spec.Name) || spec.Name == OpAnd || spec.Name == OpOr || spec.Name == OpNot {
+		panic(fmt.Sprintf("filters: invalid operator name %q", spec.Name))
+	}
+	operatorSpecsMu.Lock()
+	operatorSpecs[spec.Name] = spec
+	operatorSpecsMu.Unlock()
+}
+
+//查找操作符写法
+func LookupOperatorSpec(name string) (OperatorSpec, bool) {
+	operatorSpecsMu.RLock()
+	defer operatorSpecsMu.RUnlock()
+	spec, ok := operatorSpecs[name]
+	return spec, ok
+}
+
+//按 query 写法的前缀匹配操作符, 前缀长的优先, 如 >= 先于 >
+func matchOperatorPrefix(v string) (OperatorSpec, string, bool) {
+	operatorSpecsMu.RLock()
+	defer operatorSpecsMu.RUnlock()
+
+	var prefixes []string
+	byPrefix := map[string]OperatorSpec{}
+	for _, spec := range operatorSpecs {
+		for _, prefix := range spec.Prefixes {
+			prefixes = append(prefixes, prefix)
+			byPrefix[prefix] = spec
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i]) != len(prefixes[j]) {
+			return len(prefixes[i]) > len(prefixes[j])
+		}
+		return prefixes[i] < prefixes[j]
+	})
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(v, prefix) {
+			return byPrefix[prefix], strings.TrimPrefix(v, prefix), true
+		}
+	}
+	return OperatorSpec{}, v, false
+}
+
+//query 写法中拆分去掉前缀的值
+func (spec OperatorSpec) splitRaw(raw string) []string {
+	if spec.Split != nil {
+		return spec.Split(raw)
+	}
+	switch spec.Arity {
+	case ArityList:
+		return strings.Split(raw, "-")
+	case ArityPair:
+		return SplitPair(raw)
+	}
+	return []string{raw}
+}
+
+// SplitPair 拆分 between 的两个值
+// 含 ~ 时按 ~ 拆分, 如 2019-01-01~2019-01-31、-5~-1;
+// 两边都是数字时按其间的 - 拆分, 数字可带负号, 如 10-20、-5-10、1--5;
+// 否则 - 的个数为奇数时按中间的 - 拆分, 如 2019-01-01-2019-01-31
+func SplitPair(raw string) []string {
+	if strings.Contains(raw, "~") {
+		return strings.SplitN(raw, "~", 2)
+	}
+	for i := 1; i < len(raw); i++ {
+		if raw[i] != '-' {
+			continue
+		}
+		if _, err := strconv.ParseFloat(raw[:i], 64); err != nil {
+			continue
+		}
+		if _, err := strconv.ParseFloat(raw[i+1:], 64); err == nil {
+			return []string{raw[:i], raw[i+1:]}
+		}
+	}
+	n := strings.Count(raw, "-")
+	if n%2 == 0 {
+		return []string{raw}
+	}
+	idx := -1
+	for i := 0; i <= n/2; i++ {
+		idx += strings.Index(raw[idx+1:], "-") + 1
+	}
+	return []string{raw[:idx], raw[idx+1:]}
+}
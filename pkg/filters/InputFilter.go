@@ -2,7 +2,6 @@ package filters
 
 import (
 	"errors"
-	"fmt"
 	"strconv"
 	"strings"
 
@@ -29,6 +28,7 @@ func (c *InputFilter) GetPagePublicParams() (p *PageCommonParams, err error) {
 	// limit: 10 (default is 10)
 	if v, err := c.GetInt64("limit"); err == nil {
 		params.Limits = v
+		params.LimitSet = true
 	}
 	// offset: 0 (default is 0)
 	if v, err := c.GetInt64("offset"); err == nil {
@@ -37,6 +37,26 @@ func (c *InputFilter) GetPagePublicParams() (p *PageCommonParams, err error) {
 		params.Offsets = (page - 1) * params.Limits
 	}
 
+	// cursor: 游标分页, 第一页传空值 cursor=, 之后传上一页的 next_cursor; after 同 cursor
+	for _, key := range []string{"cursor", "after"} {
+		if c.has(key) {
+			params.Keyset = true
+			params.Offsets = 0
+			params.Cursor = c.GetString(key)
+			break
+		}
+	}
+
+	// count: 0 不统计总数, 1 统计; 默认游标分页不统计
+	params.Count = !params.Keyset
+	if v := c.GetString("count"); v != "" {
+		count, err := strconv.ParseBool(v)
+		if err != nil {
+			return params, errors.New("Error: invalid count, must be 0 or 1")
+		}
+		params.Count = count
+	}
+
 	// sortby: col1,col2
 	if v := c.GetString("sortby"); v != "" {
 		params.Sort = strings.Split(v, ",")
@@ -49,8 +69,6 @@ func (c *InputFilter) GetPagePublicParams() (p *PageCommonParams, err error) {
 
 	var query = make(map[string]string)
 	if v := c.GetString("query"); v != "" {
-		fmt.Println(v)
-
 		for _, cond := range strings.Split(v, ",") {
 			kv := strings.SplitN(cond, ":", 2)
 			if len(kv) != 2 {
@@ -62,6 +80,34 @@ func (c *InputFilter) GetPagePublicParams() (p *PageCommonParams, err error) {
 	}
 	params.Querys = query
 
+	// filter: RSQL 或 json 格式的条件, 支持 and/or/not 分组
+	if v := c.GetString("filter"); v != "" {
+		cond, err := ParseFilter(v)
+		if err != nil {
+			return params, err
+		}
+		params.Filter = cond
+	}
+
+	// group_by: status,created_at:day
+	if v := c.GetString("group_by"); v != "" {
+		if params.GroupBy, err = ParseGroupBy(v); err != nil {
+			return params, err
+		}
+	}
+	// aggregate: count:*,sum:amount,avg:price
+	if v := c.GetString("aggregate"); v != "" {
+		if params.Aggregates, err = ParseAggregates(v); err != nil {
+			return params, err
+		}
+	}
+	// having: 与 filter 写法相同, 如 count>10;sum_amount=between=(100,200)
+	if v := c.GetString("having"); v != "" {
+		if params.Having, err = ParseFilter(v); err != nil {
+			return params, err
+		}
+	}
+
 	//relations data
 	if v := c.GetString("rels"); v != "" {
 		for _, rel := range strings.Split(v, ",") {
@@ -122,6 +168,18 @@ func (c *InputFilter) GetString(key string, def ...string) string {
 	return ""
 }
 
+//请求中是否有该参数, 值可以为空
+func (c *InputFilter) has(key string) bool {
+	if c.Input.Param(key) != "" {
+		return true
+	}
+	if c.Input.Context.Request.Form == nil {
+		c.Input.Context.Request.ParseForm()
+	}
+	_, ok := c.Input.Context.Request.Form[key]
+	return ok
+}
+
 // GetInt64 returns input value as int64 or the default value while it's present and input is blank.
 func (c *InputFilter) GetInt64(key string, def ...int64) (int64, error) {
 	strv := c.Input.Query(key)
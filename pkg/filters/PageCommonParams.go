@@ -1,5 +1,12 @@
 package filters
 
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
 //公类页公共参数结构
 type PageCommonParams struct {
 	Field      []string
@@ -7,7 +14,41 @@ type PageCommonParams struct {
 	Orders     []string
 	Querys     map[string]string
 	Limits     int64
+	LimitSet   bool //请求中是否指定了 limit, 未指定时 Limits 为默认的 10
 	Offsets    int64
 	SortFields []string
 	Rels       []string
+	Filter     *Condition   //filter 参数的条件树
+	Keyset     bool         //游标分页, 请求中有 cursor 或 after 参数时开启
+	Cursor     string       //上一页返回的 next_cursor, 为空时取第一页
+	Count      bool         //是否统计总数, 游标分页默认不统计
+	GroupBy    []GroupField //聚合查询的分组字段
+	Aggregates []Aggregate  //聚合项, 为空时为 count:*
+	Having     *Condition   //聚合结果的筛选条件, 字段为分组或聚合项的结果字段名
+}
+
+//列表查询结果的分页信息
+type PageResult struct {
+	TotalCount int64  //总数, 未统计时为 -1
+	NextCursor string //下一页游标, 没有下一页时为空
+	HasMore    bool   //是否还有下一页
+}
+
+//列表参数的哈希, 用作列表缓存的键; select 字段和关联的顺序不影响结果
+func (p *PageCommonParams) Hash() string {
+	n := *p
+	n.Field = sortedCopy(p.Field)
+	n.Rels = sortedCopy(p.Rels)
+	data, err := json.Marshal(n)
+	if err != nil {
+		return ""
+	}
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sortedCopy(l []string) []string {
+	c := append([]string(nil), l...)
+	sort.Strings(c)
+	return c
 }
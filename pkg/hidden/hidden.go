@@ -0,0 +1,32 @@
+package hidden
+
+import (
+	"path"
+	"strings"
+)
+
+//默认隐藏的字段名称, 支持 * 通配
+//bee generate appcode 生成的接口不输出这些字段, db 包不允许用于 filter、sortby、aggregate 和分页游标
+var DefaultColumns = []string{"*password*", "*passwd*", "pwd", "salt", "*_salt", "*secret*", "*token*", "*api_key*", "*private_key*"}
+
+//表的字段是否匹配 DefaultColumns 或 patterns 中的一个
+//pattern 为字段名或 表名.字段名, 不区分大小写, 支持 path.Match 通配
+func Match(table, column string, patterns ...string) bool {
+	table, column = strings.ToLower(table), strings.ToLower(column)
+	for _, list := range [][]string{DefaultColumns, patterns} {
+		for _, pattern := range list {
+			pattern = strings.ToLower(strings.TrimSpace(pattern))
+			if pattern == "" {
+				continue
+			}
+			name := column
+			if strings.Contains(pattern, ".") {
+				name = table + "." + column
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package hidden
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		table, column string
+		patterns      []string
+		want          bool
+	}{
+		{"a", "password", nil, true},
+		{"a", "Login_Password_Hash", nil, true},
+		{"a", "pwd2", nil, false},
+		{"a", "user_salt", nil, true},
+		{"a", "refresh_token", nil, true},
+		{"a", "mobile", nil, false},
+		{"a", "mobile", []string{" Mobile "}, true},
+		{"member", "mobile", []string{"member.mobile"}, true},
+		{"Member", "MOBILE", []string{"member.mob*"}, true},
+		{"order", "mobile", []string{"member.mobile"}, false},
+		{"a", "mobile", []string{""}, false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.table, tt.column, tt.patterns...); got != tt.want {
+			t.Errorf("Match(%q, %q, %q) = %v, want %v", tt.table, tt.column, tt.patterns, got, tt.want)
+		}
+	}
+}
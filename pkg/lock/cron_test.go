@@ -0,0 +1,41 @@
+package lock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yimishiji/bee/pkg/cron"
+	"github.com/yimishiji/bee/pkg/db"
+	"github.com/yimishiji/bee/pkg/lock"
+)
+
+//cron 的 Single 任务使用锁, 锁脚本的 Go 实现只在本包测试中注册, 所以在这里测试
+func TestSingleJobMemoryRedis(t *testing.T) {
+	db.Redis = db.NewMemoryRedis()
+	defer func() { db.Redis = nil }()
+
+	runs := 0
+	if err := cron.Register(cron.Job{Name: "single", Spec: "0 0 * * * *", Single: true, Run: func(ctx context.Context) error {
+		runs++
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cron.Trigger(context.Background(), "single"); err != nil {
+		t.Fatalf("Trigger error: %v", err)
+	}
+
+	//其它节点持有锁时跳过
+	l, err := lock.Obtain("cron:single", time.Minute)
+	if err != nil {
+		t.Fatalf("Obtain error: %v", err)
+	}
+	defer l.Release()
+	if err := cron.Trigger(context.Background(), "single"); err != nil {
+		t.Fatalf("Trigger error: %v", err)
+	}
+	if runs != 1 {
+		t.Errorf("runs = %d, want 1", runs)
+	}
+}
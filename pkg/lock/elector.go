@@ -0,0 +1,130 @@
+package lock
+
+import (
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/toolbox"
+)
+
+//leader 选举, 多个节点竞争同一个锁, 取得锁的节点为 leader 并定时续期
+//leader 下线后锁在 ttl 内过期, 由其它节点接替
+type Elector struct {
+	name string
+	ttl  time.Duration
+
+	mu     sync.RWMutex
+	lock   *Lock
+	stop   chan struct{}
+	stopWg sync.WaitGroup
+}
+
+//name 为选举的名称, 同名的节点之间选出一个 leader; ttl 为 leader 失联后其它节点接替的最长时间
+func NewElector(name string, ttl time.Duration) *Elector {
+	return &Elector{name: "leader:" + name, ttl: ttl}
+}
+
+//开始参与选举, 立即尝试一次, 之后每隔 ttl/3 续期或重新竞选
+func (e *Elector) Start() {
+	e.mu.Lock()
+	if e.stop != nil {
+		e.mu.Unlock()
+		return
+	}
+	e.stop = make(chan struct{})
+	stop := e.stop
+	e.mu.Unlock()
+
+	e.campaign()
+	e.stopWg.Add(1)
+	go func() {
+		defer e.stopWg.Done()
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				e.campaign()
+			}
+		}
+	}()
+}
+
+//退出选举, 是 leader 时释放锁, 其它节点可立即接替
+func (e *Elector) Stop() {
+	e.mu.Lock()
+	if e.stop == nil {
+		e.mu.Unlock()
+		return
+	}
+	close(e.stop)
+	e.stop = nil
+	e.mu.Unlock()
+	e.stopWg.Wait()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lock != nil {
+		e.lock.Release()
+		e.lock = nil
+	}
+}
+
+//是 leader 时续期, 否则竞选
+func (e *Elector) campaign() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.lock != nil {
+		err := e.lock.Refresh(e.ttl)
+		if err == nil {
+			return
+		}
+		//续期失败时不再认为自己是 leader, 避免与新 leader 同时执行
+		beego.Warn("lock: leadership of", e.name, "lost:", err)
+		e.lock = nil
+	}
+
+	l, err := Obtain(e.name, e.ttl)
+	switch err {
+	case nil:
+		beego.Info("lock: became leader of", e.name)
+		e.lock = l
+	case ErrNotObtained:
+	default:
+		beego.Warn("lock: campaign for", e.name, "failed:", err)
+	}
+}
+
+//当前节点是否是 leader
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lock != nil
+}
+
+//当选时的 fencing token, 不是 leader 时为0
+func (e *Elector) Token() int64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.lock == nil {
+		return 0
+	}
+	return e.lock.Token()
+}
+
+//包装定时任务, 只在 leader 节点执行
+//	elector := lock.NewElector("cron", 30*time.Second)
+//	elector.Start()
+//	toolbox.AddTask("tk1", toolbox.NewTask("tk1", "0 */3 * * * *", elector.Task(InstanceCallback.RunSecond)))
+func (e *Elector) Task(fn toolbox.TaskFunc) toolbox.TaskFunc {
+	return func() error {
+		if !e.IsLeader() {
+			return nil
+		}
+		return fn()
+	}
+}
@@ -0,0 +1,180 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+var (
+	//锁被其它节点持有
+	ErrNotObtained = errors.New("lock: not obtained")
+	//锁已过期或被其它节点取得
+	ErrLost = errors.New("lock: lost")
+	//未连接redis
+	ErrNoRedis = errors.New("lock: redis not connected")
+)
+
+//取锁并生成 fencing token, 锁不存在时设置并将计数加1
+var obtainScript = `
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`
+
+//持有者一致时延长过期时间
+var refreshScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+
+//持有者一致时删除, 避免删掉过期后被其它节点取得的锁
+var releaseScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+//redis 分布式锁, 键为 lock:{<name>}, 值为持有者的随机串; 名称放在 {} 中, cluster 模式下锁和 fencing 计数在同一个节点
+type Lock struct {
+	name  string
+	owner string
+	token int64
+	ttl   time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+//取锁, 已被持有时返回 ErrNotObtained; ttl 后锁自动过期, 需更久时调用 Refresh 或 KeepAlive
+func Obtain(name string, ttl time.Duration) (*Lock, error) {
+	if db.Redis == nil {
+		return nil, ErrNoRedis
+	}
+	l := &Lock{name: name, owner: newOwner(), ttl: ttl}
+	res, err := db.Redis.Eval(obtainScript, []string{l.key(), l.key() + ":fence"}, l.owner, ttlMillis(ttl)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if token, _ := res.(int64); token > 0 {
+		l.token = token
+		return l, nil
+	}
+	return nil, ErrNotObtained
+}
+
+//取锁, 已被持有时每隔 retry 重试, 直到取得或 ctx 结束
+func ObtainWait(ctx context.Context, name string, ttl, retry time.Duration) (*Lock, error) {
+	for {
+		l, err := Obtain(name, ttl)
+		if err != ErrNotObtained {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+//取锁后执行 fn, 执行期间自动续期, 结束后释放; 锁被持有时返回 ErrNotObtained
+//	err := lock.Do("order:close", time.Minute, func(l *lock.Lock) error {
+//		return closeExpiredOrders(l.Token())
+//	})
+func Do(name string, ttl time.Duration, fn func(l *Lock) error) error {
+	l, err := Obtain(name, ttl)
+	if err != nil {
+		return err
+	}
+	l.KeepAlive(ttl / 3)
+	defer l.Release()
+	return fn(l)
+}
+
+func (l *Lock) key() string {
+	return "lock:{" + l.name + "}"
+}
+
+//fencing token, 每次取锁递增; 写入外部存储时带上, 拒绝比已写入的 token 小的请求, 防止锁过期后的旧持有者写入
+func (l *Lock) Token() int64 {
+	return l.token
+}
+
+//延长过期时间为 ttl, 锁已不属于自己时返回 ErrLost
+func (l *Lock) Refresh(ttl time.Duration) error {
+	res, err := db.Redis.Eval(refreshScript, []string{l.key()}, l.owner, ttlMillis(ttl)).Result()
+	if err != nil {
+		return err
+	}
+	if n, _ := res.(int64); n == 0 {
+		return ErrLost
+	}
+	return nil
+}
+
+//每隔 interval 续期, 直到 Release; 返回的通道在锁丢失时关闭
+func (l *Lock) KeepAlive(interval time.Duration) <-chan struct{} {
+	lost := make(chan struct{})
+	l.mu.Lock()
+	if l.stop == nil {
+		l.stop = make(chan struct{})
+	}
+	stop := l.stop
+	l.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := l.Refresh(l.ttl); err == ErrLost {
+					close(lost)
+					return
+				}
+			}
+		}
+	}()
+	return lost
+}
+
+//释放锁并停止续期, 锁已不属于自己时返回 ErrLost
+func (l *Lock) Release() error {
+	l.mu.Lock()
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+	l.mu.Unlock()
+
+	res, err := db.Redis.Eval(releaseScript, []string{l.key()}, l.owner).Result()
+	if err != nil {
+		return err
+	}
+	if n, _ := res.(int64); n == 0 {
+		return ErrLost
+	}
+	return nil
+}
+
+func newOwner() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func ttlMillis(ttl time.Duration) int64 {
+	if ms := int64(ttl / time.Millisecond); ms > 0 {
+		return ms
+	}
+	return 1
+}
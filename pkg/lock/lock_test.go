@@ -0,0 +1,59 @@
+package lock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+func TestLockMemoryRedis(t *testing.T) {
+	mem := db.NewMemoryRedis()
+	db.Redis = mem
+	defer func() { db.Redis = nil }()
+
+	l, err := Obtain("job", time.Second)
+	if err != nil {
+		t.Fatalf("Obtain error: %v", err)
+	}
+	if l.Token() != 1 {
+		t.Errorf("token = %d, want 1", l.Token())
+	}
+	if _, err := Obtain("job", time.Second); err != ErrNotObtained {
+		t.Errorf("Obtain held lock error = %v, want ErrNotObtained", err)
+	}
+
+	if err := l.Refresh(time.Minute); err != nil {
+		t.Errorf("Refresh error: %v", err)
+	}
+	mem.FastForward(30 * time.Second)
+	if _, err := Obtain("job", time.Second); err != ErrNotObtained {
+		t.Errorf("Obtain refreshed lock error = %v, want ErrNotObtained", err)
+	}
+
+	//过期后被其它节点取得, 原持有者不能续期和释放
+	mem.FastForward(time.Minute)
+	other, err := Obtain("job", time.Minute)
+	if err != nil {
+		t.Fatalf("Obtain expired lock error: %v", err)
+	}
+	if other.Token() != 2 {
+		t.Errorf("token = %d, want 2", other.Token())
+	}
+	if err := l.Refresh(time.Minute); err != ErrLost {
+		t.Errorf("Refresh lost lock error = %v, want ErrLost", err)
+	}
+	if err := l.Release(); err != ErrLost {
+		t.Errorf("Release lost lock error = %v, want ErrLost", err)
+	}
+	if _, err := Obtain("job", time.Second); err != ErrNotObtained {
+		t.Errorf("Obtain after the old holder released error = %v, want ErrNotObtained", err)
+	}
+
+	if err := other.Release(); err != nil {
+		t.Errorf("Release error: %v", err)
+	}
+	if _, err := Obtain("job", time.Second); err != nil {
+		t.Errorf("Obtain released lock error: %v", err)
+	}
+}
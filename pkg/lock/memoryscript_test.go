@@ -0,0 +1,45 @@
+package lock
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+//脚本的 Go 实现, 只在测试中注册, db.Redis 为 db.NewMemoryRedis() 时使用
+func init() {
+	db.RegisterMemoryScript(obtainScript, memoryObtain)
+	db.RegisterMemoryScript(refreshScript, memoryRefresh)
+	db.RegisterMemoryScript(releaseScript, memoryRelease)
+}
+
+func memoryMillis(s string) time.Duration {
+	ms, _ := strconv.ParseInt(s, 10, 64)
+	return time.Duration(ms) * time.Millisecond
+}
+
+func memoryObtain(r db.RedisCmdable, keys []string, args []string) (interface{}, error) {
+	ok, err := r.SetNX(keys[0], args[0], memoryMillis(args[1])).Result()
+	if err != nil || !ok {
+		return int64(0), err
+	}
+	return r.Incr(keys[1]).Result()
+}
+
+func memoryRefresh(r db.RedisCmdable, keys []string, args []string) (interface{}, error) {
+	if r.Get(keys[0]).Val() != args[0] {
+		return int64(0), nil
+	}
+	if ok, err := r.PExpire(keys[0], memoryMillis(args[1])).Result(); err != nil || !ok {
+		return int64(0), err
+	}
+	return int64(1), nil
+}
+
+func memoryRelease(r db.RedisCmdable, keys []string, args []string) (interface{}, error) {
+	if r.Get(keys[0]).Val() != args[0] {
+		return int64(0), nil
+	}
+	return r.Del(keys[0]).Result()
+}
@@ -0,0 +1,205 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+//任务处理函数, 返回错误时按 Backoff 重试, 返回 Permanent(err) 时直接进入死信队列
+//ctx 在 Stop 等待超时后取消
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+//消费者, 以 Concurrency 个协程并发处理队列中的任务
+//	c := queue.NewConsumer("sms", handle, 4)
+//	c.Start()
+//	defer c.Stop(30 * time.Second)
+type Consumer struct {
+	Queue       string
+	Handler     HandlerFunc
+	Concurrency int
+	//第 n 次失败后的重试间隔, 默认 DefaultBackoff
+	Backoff func(attempts int) time.Duration
+	//队列为空时的轮询间隔, 默认1秒
+	PollInterval time.Duration
+	//任务执行超时未确认时重新执行, 默认5分钟, 应大于任务的最长执行时间
+	Visibility time.Duration
+
+	mu      sync.Mutex
+	running bool
+	quit    chan struct{}
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func NewConsumer(queue string, handler HandlerFunc, concurrency int) *Consumer {
+	return &Consumer{Queue: queue, Handler: handler, Concurrency: concurrency}
+}
+
+//开始消费
+func (c *Consumer) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return
+	}
+	if c.Concurrency < 1 {
+		c.Concurrency = 1
+	}
+	if c.Backoff == nil {
+		c.Backoff = DefaultBackoff
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	if c.Visibility <= 0 {
+		c.Visibility = 5 * time.Minute
+	}
+	c.running = true
+	c.quit = make(chan struct{})
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	for i := 0; i < c.Concurrency; i++ {
+		c.wg.Add(1)
+		go c.work()
+	}
+}
+
+//停止取新任务, 等待执行中的任务完成; 超过 timeout 后取消任务的 ctx 并返回
+//未完成的任务在 Visibility 后由其它消费者重新执行
+func (c *Consumer) Stop(timeout time.Duration) {
+	c.mu.Lock()
+	if !c.running {
+		c.mu.Unlock()
+		return
+	}
+	c.running = false
+	close(c.quit)
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logs.Warn(fmt.Sprintf("queue %s: stop timeout, cancel running jobs", c.Queue))
+	}
+	c.cancel()
+}
+
+func (c *Consumer) work() {
+	defer c.wg.Done()
+	b := currentBackend()
+	for {
+		select {
+		case <-c.quit:
+			return
+		default:
+		}
+
+		job, err := b.Pop(c.Queue, c.Visibility)
+		if err != nil {
+			logs.Error(fmt.Sprintf("queue %s: pop: %s", c.Queue, err))
+		}
+		if job == nil {
+			select {
+			case <-c.quit:
+				return
+			case <-time.After(c.PollInterval):
+			}
+			continue
+		}
+		//超时重新执行的次数已超过最大重试次数(如任务每次都导致进程退出), 不再执行
+		if job.Attempts > job.MaxRetries {
+			c.bury(b, job, fmt.Errorf("not acked within %s, the worker may have exited while running it", c.Visibility))
+			continue
+		}
+		c.process(b, job)
+	}
+}
+
+func (c *Consumer) bury(b Backend, job *Job, err error) {
+	job.LastError = err.Error()
+	logs.Error(fmt.Sprintf("queue %s: job %s failed after %d attempts: %s", c.Queue, job.ID, job.Attempts, err))
+	if err := b.Bury(job); err != nil {
+		logs.Error(fmt.Sprintf("queue %s: job %s: %s", c.Queue, job.ID, err))
+	}
+}
+
+func (c *Consumer) process(b Backend, job *Job) {
+	err := c.call(job)
+	if err == nil {
+		if err := b.Ack(job); err != nil {
+			logs.Error(fmt.Sprintf("queue %s: ack job %s: %s", c.Queue, job.ID, err))
+		}
+		return
+	}
+
+	job.Attempts++
+	if isPermanent(err) || job.Attempts > job.MaxRetries {
+		c.bury(b, job, err)
+		return
+	}
+	job.LastError = err.Error()
+	logs.Warn(fmt.Sprintf("queue %s: job %s attempt %d failed: %s", c.Queue, job.ID, job.Attempts, err))
+	if err := b.Retry(job, time.Now().Add(c.Backoff(job.Attempts))); err != nil {
+		logs.Error(fmt.Sprintf("queue %s: job %s: %s", c.Queue, job.ID, err))
+	}
+}
+
+//执行任务, panic 转为错误
+func (c *Consumer) call(job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return c.Handler(c.ctx, job)
+}
+
+var (
+	consumersMu sync.Mutex
+	consumers   []*Consumer
+)
+
+//注册消费者, 由 StartAll 统一启动
+func Register(queue string, handler HandlerFunc, concurrency int) *Consumer {
+	c := NewConsumer(queue, handler, concurrency)
+	consumersMu.Lock()
+	consumers = append(consumers, c)
+	consumersMu.Unlock()
+	return c
+}
+
+//启动已注册的消费者
+func StartAll() {
+	consumersMu.Lock()
+	defer consumersMu.Unlock()
+	for _, c := range consumers {
+		c.Start()
+	}
+}
+
+//停止已注册的消费者, 在程序退出前调用, 等待执行中的任务最多 timeout
+func StopAll(timeout time.Duration) {
+	consumersMu.Lock()
+	list := append([]*Consumer(nil), consumers...)
+	consumersMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range list {
+		wg.Add(1)
+		go func(c *Consumer) {
+			defer wg.Done()
+			c.Stop(timeout)
+		}(c)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+//内存存储, 用于测试, 只在本进程内有效
+//	queue.SetBackend(queue.NewMemoryBackend())
+type MemoryBackend struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+}
+
+type memoryQueue struct {
+	ready      []string
+	delayed    []memoryItem
+	processing map[string]time.Time
+	dead       []string
+}
+
+type memoryItem struct {
+	raw string
+	at  time.Time
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{queues: map[string]*memoryQueue{}}
+}
+
+func (b *MemoryBackend) queue(name string) *memoryQueue {
+	q, ok := b.queues[name]
+	if !ok {
+		q = &memoryQueue{processing: map[string]time.Time{}}
+		b.queues[name] = q
+	}
+	return q
+}
+
+func (b *MemoryBackend) Push(job *Job, at time.Time) error {
+	raw, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	job.raw = raw
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queue(job.Queue)
+	if at.IsZero() || !at.After(time.Now()) {
+		q.ready = append(q.ready, raw)
+	} else {
+		q.delayed = append(q.delayed, memoryItem{raw: raw, at: at})
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Pop(name string, visibility time.Duration) (*Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queue(name)
+	now := time.Now()
+
+	sort.SliceStable(q.delayed, func(i, j int) bool {
+		return q.delayed[i].at.Before(q.delayed[j].at)
+	})
+	for len(q.delayed) > 0 && !q.delayed[0].at.After(now) {
+		q.ready = append(q.ready, q.delayed[0].raw)
+		q.delayed = q.delayed[1:]
+	}
+	//超时未确认的任务重新执行, 计入 Attempts
+	for raw, deadline := range q.processing {
+		if !deadline.After(now) {
+			delete(q.processing, raw)
+			if job, err := decodeJob(raw); err == nil {
+				job.Attempts++
+				if v, err := encodeJob(job); err == nil {
+					raw = v
+				}
+			}
+			q.ready = append(q.ready, raw)
+		}
+	}
+	if len(q.ready) == 0 {
+		return nil, nil
+	}
+
+	raw := q.ready[0]
+	q.ready = q.ready[1:]
+	job, err := decodeJob(raw)
+	if err != nil {
+		q.dead = append(q.dead, raw)
+		return nil, err
+	}
+	q.processing[raw] = now.Add(visibility)
+	return job, nil
+}
+
+func (b *MemoryBackend) Ack(job *Job) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.queue(job.Queue).processing, job.raw)
+	return nil
+}
+
+func (b *MemoryBackend) Retry(job *Job, at time.Time) error {
+	raw, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queue(job.Queue)
+	delete(q.processing, job.raw)
+	q.delayed = append(q.delayed, memoryItem{raw: raw, at: at})
+	job.raw = raw
+	return nil
+}
+
+func (b *MemoryBackend) Bury(job *Job) error {
+	raw, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queue(job.Queue)
+	delete(q.processing, job.raw)
+	q.dead = append([]string{raw}, q.dead...)
+	if len(q.dead) > deadLimit {
+		q.dead = q.dead[:deadLimit]
+	}
+	job.raw = raw
+	return nil
+}
+
+func (b *MemoryBackend) Dead(name string, limit int64) ([]*Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var jobs []*Job
+	for _, raw := range b.queue(name).dead {
+		if limit > 0 && int64(len(jobs)) >= limit {
+			break
+		}
+		if job, err := decodeJob(raw); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+//待执行、延迟、执行中的任务数, 用于测试中判断任务是否执行完
+func (b *MemoryBackend) Len(name string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queue(name)
+	return len(q.ready) + len(q.delayed) + len(q.processing)
+}
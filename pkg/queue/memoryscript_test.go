@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"strconv"
+
+	"github.com/go-redis/redis"
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+//popScript 的 Go 实现, 只在测试中注册, db.Redis 为 db.NewMemoryRedis() 时 RedisBackend 同样可用
+func init() {
+	db.RegisterMemoryScript(popScript, memoryPop)
+}
+
+func memoryPop(r db.RedisCmdable, keys []string, args []string) (interface{}, error) {
+	due := redis.ZRangeBy{Min: "-inf", Max: args[0], Count: 100}
+	for _, v := range r.ZRangeByScore(keys[1], due).Val() {
+		r.ZRem(keys[1], v)
+		r.LPush(keys[0], v)
+	}
+	for _, v := range r.ZRangeByScore(keys[2], due).Val() {
+		r.ZRem(keys[2], v)
+		r.HIncrBy(keys[3], v, 1)
+		r.LPush(keys[0], v)
+	}
+	job, err := r.RPop(keys[0]).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := strconv.ParseFloat(args[1], 64)
+	r.ZAdd(keys[2], redis.Z{Score: deadline, Member: job})
+	redelivered, _ := r.HGet(keys[3], job).Int64()
+	return []interface{}{job, redelivered}, nil
+}
@@ -0,0 +1,183 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+//默认的最大重试次数
+const DefaultMaxRetries = 5
+
+//任务
+type Job struct {
+	ID         string          `json:"id"`
+	Queue      string          `json:"queue"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`    //已执行的次数
+	MaxRetries int             `json:"max_retries"` //失败后最多重试的次数, 超过后进入死信队列
+	LastError  string          `json:"last_error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+
+	raw string //存储中的原始数据, 用于确认和重试
+}
+
+//解析任务数据
+func (j *Job) Bind(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+//任务的存储, 默认为 redis, 测试中可用 NewMemoryBackend
+type Backend interface {
+	//加入任务, at 为空时立即可执行, 否则到时间后才可执行
+	Push(job *Job, at time.Time) error
+	//取一个可执行的任务, 没有时返回 nil; 任务在 visibility 内未确认时重新可执行, 重新执行时 Attempts 加 1
+	Pop(queue string, visibility time.Duration) (*Job, error)
+	//确认任务已完成
+	Ack(job *Job) error
+	//任务失败, at 之后重试
+	Retry(job *Job, at time.Time) error
+	//任务移入死信队列
+	Bury(job *Job) error
+	//死信队列中最近的 limit 个任务, limit <= 0 时返回全部
+	Dead(queue string, limit int64) ([]*Job, error)
+}
+
+var (
+	backendMu sync.RWMutex
+	backend   Backend
+)
+
+//设置任务的存储, 未设置时使用 redis(db.Redis)
+func SetBackend(b Backend) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	backend = b
+}
+
+func currentBackend() Backend {
+	backendMu.RLock()
+	b := backend
+	backendMu.RUnlock()
+	if b != nil {
+		return b
+	}
+	return NewRedisBackend()
+}
+
+//任务选项
+type Option func(o *options)
+
+type options struct {
+	at         time.Time
+	maxRetries int
+}
+
+//延迟 d 后执行
+func Delay(d time.Duration) Option {
+	return func(o *options) {
+		o.at = time.Now().Add(d)
+	}
+}
+
+//在 t 时执行
+func At(t time.Time) Option {
+	return func(o *options) {
+		o.at = t
+	}
+}
+
+//失败后最多重试 n 次, 默认 DefaultMaxRetries
+func MaxRetries(n int) Option {
+	return func(o *options) {
+		o.maxRetries = n
+	}
+}
+
+//加入任务, payload 转为json
+//	queue.Enqueue("sms", SmsPayload{Mobile: "138..."}, queue.Delay(time.Minute))
+func Enqueue(queue string, payload interface{}, opts ...Option) (*Job, error) {
+	o := options{maxRetries: DefaultMaxRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:         newJobID(),
+		Queue:      queue,
+		Payload:    data,
+		MaxRetries: o.maxRetries,
+		CreatedAt:  time.Now(),
+	}
+	return job, currentBackend().Push(job, o.at)
+}
+
+//死信队列中最近的 limit 个任务, limit <= 0 时返回全部(最多保留 10000 条), 用于排查失败原因
+func DeadJobs(queue string, limit int64) ([]*Job, error) {
+	return currentBackend().Dead(queue, limit)
+}
+
+//不再重试的错误, 任务直接进入死信队列
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+//包装错误, 任务返回后不再重试, 如数据格式错误
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	_, ok := err.(permanentError)
+	return ok
+}
+
+//默认的重试间隔, 第 n 次失败后等待 5s*2^(n-1), 最长1小时
+func DefaultBackoff(attempts int) time.Duration {
+	d := 5 * time.Second
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+func newJobID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func encodeJob(job *Job) (string, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func decodeJob(raw string) (*Job, error) {
+	job := &Job{}
+	if err := json.Unmarshal([]byte(raw), job); err != nil {
+		return nil, err
+	}
+	job.raw = raw
+	return job, nil
+}
+
+var errNoRedis = errors.New("queue: redis not connected")
@@ -0,0 +1,235 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//使用内存存储的消费者, 轮询和重试间隔很短
+func testConsumer(name string, handler HandlerFunc) (*Consumer, *MemoryBackend) {
+	b := NewMemoryBackend()
+	SetBackend(b)
+	c := NewConsumer(name, handler, 2)
+	c.PollInterval = time.Millisecond
+	c.Backoff = func(int) time.Duration { return time.Millisecond }
+	return c, b
+}
+
+//等待 cond 成立, 最多 1 秒
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRetryThenSucceed(t *testing.T) {
+	var calls int32
+	c, b := testConsumer("retry", func(ctx context.Context, job *Job) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("fail")
+		}
+		var v map[string]int
+		if err := job.Bind(&v); err != nil || v["n"] != 1 {
+			t.Errorf("Bind = %v, %v", v, err)
+		}
+		return nil
+	})
+	if _, err := Enqueue("retry", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	defer c.Stop(time.Second)
+
+	waitFor(t, "job done", func() bool { return b.Len("retry") == 0 })
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("handler called %d times, want 3", n)
+	}
+	if dead, _ := DeadJobs("retry", 0); len(dead) != 0 {
+		t.Errorf("dead jobs = %d, want 0", len(dead))
+	}
+}
+
+func TestBuryAfterMaxRetries(t *testing.T) {
+	var calls int32
+	c, b := testConsumer("bury", func(ctx context.Context, job *Job) error {
+		atomic.AddInt32(&calls, 1)
+		var n int
+		job.Bind(&n)
+		if n == 2 {
+			return Permanent(errors.New("bad payload"))
+		}
+		return errors.New("always fails")
+	})
+	Enqueue("bury", 1, MaxRetries(2))
+	Enqueue("bury", 2, MaxRetries(5))
+	c.Start()
+	defer c.Stop(time.Second)
+
+	waitFor(t, "jobs buried", func() bool { return b.Len("bury") == 0 })
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Errorf("handler called %d times, want 3 + 1", n)
+	}
+	dead, err := DeadJobs("bury", 0)
+	if err != nil || len(dead) != 2 {
+		t.Fatalf("DeadJobs = %d, %v, want 2", len(dead), err)
+	}
+	for _, job := range dead {
+		var n int
+		job.Bind(&n)
+		switch {
+		case n == 1 && (job.Attempts != 3 || job.LastError != "always fails"):
+			t.Errorf("job 1 attempts %d error %q, want 3 always fails", job.Attempts, job.LastError)
+		case n == 2 && (job.Attempts != 1 || job.LastError != "bad payload"):
+			t.Errorf("job 2 attempts %d error %q, want 1 bad payload", job.Attempts, job.LastError)
+		}
+	}
+	if dead, _ := DeadJobs("bury", 1); len(dead) != 1 {
+		t.Errorf("DeadJobs limit 1 = %d", len(dead))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	c, b := testConsumer("backoff", func(ctx context.Context, job *Job) error {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		return errors.New("fail")
+	})
+	var attempts []int
+	c.Backoff = func(n int) time.Duration {
+		mu.Lock()
+		attempts = append(attempts, n)
+		mu.Unlock()
+		return time.Duration(n) * 20 * time.Millisecond
+	}
+	Enqueue("backoff", 1, MaxRetries(2))
+	c.Start()
+	defer c.Stop(time.Second)
+
+	waitFor(t, "job buried", func() bool { return b.Len("backoff") == 0 })
+	mu.Lock()
+	defer mu.Unlock()
+	if len(times) != 3 || len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Fatalf("calls %d, backoff attempts %v, want 3 calls and [1 2]", len(times), attempts)
+	}
+	if d := times[1].Sub(times[0]); d < 20*time.Millisecond {
+		t.Errorf("first retry after %s, want >= 20ms", d)
+	}
+	if d := times[2].Sub(times[1]); d < 40*time.Millisecond {
+		t.Errorf("second retry after %s, want >= 40ms", d)
+	}
+
+	if DefaultBackoff(1) != 5*time.Second || DefaultBackoff(2) != 10*time.Second || DefaultBackoff(100) != time.Hour {
+		t.Errorf("DefaultBackoff = %s %s %s", DefaultBackoff(1), DefaultBackoff(2), DefaultBackoff(100))
+	}
+}
+
+func TestRedeliveryCountsAttempts(t *testing.T) {
+	b := NewMemoryBackend()
+	SetBackend(b)
+	Enqueue("redeliver", 1, MaxRetries(1))
+
+	//取出后不确认, 如进程退出
+	for i := 0; i < 2; i++ {
+		job, err := b.Pop("redeliver", time.Millisecond)
+		if err != nil || job == nil {
+			t.Fatalf("Pop %d = %v, %v", i, job, err)
+		}
+		if job.Attempts != i {
+			t.Errorf("Pop %d attempts = %d, want %d", i, job.Attempts, i)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	//第三次取出时已超过最大重试次数, 不执行直接进入死信队列
+	var calls int32
+	c := NewConsumer("redeliver", func(ctx context.Context, job *Job) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}, 1)
+	c.PollInterval = time.Millisecond
+	c.Start()
+	defer c.Stop(time.Second)
+
+	waitFor(t, "job buried", func() bool { return b.Len("redeliver") == 0 })
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("handler called %d times, want 0", n)
+	}
+	dead, _ := DeadJobs("redeliver", 0)
+	if len(dead) != 1 || dead[0].Attempts != 2 {
+		t.Fatalf("dead jobs = %v, want 1 job with 2 attempts", dead)
+	}
+}
+
+func TestGracefulStop(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var finished int32
+	c, b := testConsumer("stop", func(ctx context.Context, job *Job) error {
+		close(started)
+		select {
+		case <-release:
+			atomic.StoreInt32(&finished, 1)
+		case <-ctx.Done():
+		}
+		return nil
+	})
+	Enqueue("stop", 1)
+	c.Start()
+	<-started
+
+	//Stop 等待执行中的任务完成
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	c.Stop(time.Second)
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Errorf("Stop returned before the running job finished")
+	}
+	if b.Len("stop") != 0 {
+		t.Errorf("job not acked after Stop")
+	}
+
+	//停止后不再取新任务
+	Enqueue("stop", 2)
+	time.Sleep(10 * time.Millisecond)
+	if b.Len("stop") != 1 {
+		t.Errorf("job taken after Stop")
+	}
+}
+
+func TestStopTimeoutCancelsJobs(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	c, _ := testConsumer("cancel", func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})
+	Enqueue("cancel", 1)
+	c.Start()
+	<-started
+
+	begin := time.Now()
+	c.Stop(20 * time.Millisecond)
+	if d := time.Since(begin); d > 500*time.Millisecond {
+		t.Errorf("Stop took %s, want about 20ms", d)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Errorf("job ctx not canceled after the stop timeout")
+	}
+}
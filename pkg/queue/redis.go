@@ -0,0 +1,180 @@
+package queue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+//死信队列保留的任务数
+const deadLimit = 10000
+
+//取任务: 到时间的延迟任务和超时未确认的任务移回待执行列表, 再取一个任务放入执行中
+//超时未确认的任务在重新执行次数中加 1, 不修改任务数据(cjson 重新编码会改变 payload)
+//KEYS: 待执行列表, 延迟任务, 执行中, 重新执行次数; ARGV: 当前毫秒时间, 执行超时的毫秒时间
+//返回 {任务, 重新执行次数}, 没有任务时返回 nil
+var popScript = `
+local due = redis.call("ZRANGEBYSCORE", KEYS[2], "-inf", ARGV[1], "LIMIT", 0, 100)
+for _, v in ipairs(due) do
+	redis.call("ZREM", KEYS[2], v)
+	redis.call("LPUSH", KEYS[1], v)
+end
+local stale = redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", ARGV[1], "LIMIT", 0, 100)
+for _, v in ipairs(stale) do
+	redis.call("ZREM", KEYS[3], v)
+	redis.call("HINCRBY", KEYS[4], v, 1)
+	redis.call("LPUSH", KEYS[1], v)
+end
+local job = redis.call("RPOP", KEYS[1])
+if not job then
+	return false
+end
+redis.call("ZADD", KEYS[3], ARGV[2], job)
+return {job, tonumber(redis.call("HGET", KEYS[4], job) or 0)}`
+
+//redis 存储, 键为 queue:{<name>}(待执行列表)、queue:{<name>}:delayed、queue:{<name>}:processing、queue:{<name>}:dead
+//queue:{<name>}:redelivered 为超时重新执行的次数
+//队列名放在 {} 中, cluster 模式下同一个队列的键在同一个节点, 脚本和事务可以同时操作
+//执行中的任务超过 visibility 未确认(如进程退出)时重新执行并计入 Attempts, 任务至少执行一次
+type RedisBackend struct{}
+
+func NewRedisBackend() *RedisBackend {
+	return &RedisBackend{}
+}
+
+func queueKey(queue, suffix string) string {
+	if suffix == "" {
+		return "queue:{" + queue + "}"
+	}
+	return "queue:{" + queue + "}:" + suffix
+}
+
+func millis(t time.Time) float64 {
+	return float64(t.UnixNano() / int64(time.Millisecond))
+}
+
+func (b *RedisBackend) Push(job *Job, at time.Time) error {
+	if db.Redis == nil {
+		return errNoRedis
+	}
+	raw, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	job.raw = raw
+	if at.IsZero() || !at.After(time.Now()) {
+		return db.Redis.LPush(queueKey(job.Queue, ""), raw).Err()
+	}
+	return db.Redis.ZAdd(queueKey(job.Queue, "delayed"), redis.Z{Score: millis(at), Member: raw}).Err()
+}
+
+func (b *RedisBackend) Pop(queue string, visibility time.Duration) (*Job, error) {
+	if db.Redis == nil {
+		return nil, errNoRedis
+	}
+	now := time.Now()
+	keys := []string{queueKey(queue, ""), queueKey(queue, "delayed"), queueKey(queue, "processing"), queueKey(queue, "redelivered")}
+	res, err := db.Redis.Eval(popScript, keys, millis(now), millis(now.Add(visibility))).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values, _ := res.([]interface{})
+	if len(values) != 2 {
+		return nil, nil
+	}
+	raw, _ := values[0].(string)
+	redelivered, _ := values[1].(int64)
+	if raw == "" {
+		return nil, nil
+	}
+	job, err := decodeJob(raw)
+	if err != nil {
+		//无法解析的任务按原样移入死信队列
+		if buryErr := bury(queue, raw, raw); buryErr != nil {
+			return nil, fmt.Errorf("%s (bury failed: %s)", err, buryErr)
+		}
+		return nil, err
+	}
+	job.Attempts += int(redelivered)
+	return job, nil
+}
+
+func (b *RedisBackend) Ack(job *Job) error {
+	if db.Redis == nil {
+		return errNoRedis
+	}
+	_, err := db.Redis.TxPipelined(func(pipe db.RedisPipeliner) error {
+		pipe.ZRem(queueKey(job.Queue, "processing"), job.raw)
+		pipe.HDel(queueKey(job.Queue, "redelivered"), job.raw)
+		return nil
+	})
+	return err
+}
+
+func (b *RedisBackend) Retry(job *Job, at time.Time) error {
+	if db.Redis == nil {
+		return errNoRedis
+	}
+	raw, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	_, err = db.Redis.TxPipelined(func(pipe db.RedisPipeliner) error {
+		pipe.ZRem(queueKey(job.Queue, "processing"), job.raw)
+		pipe.HDel(queueKey(job.Queue, "redelivered"), job.raw)
+		pipe.ZAdd(queueKey(job.Queue, "delayed"), redis.Z{Score: millis(at), Member: raw})
+		return nil
+	})
+	job.raw = raw
+	return err
+}
+
+func (b *RedisBackend) Bury(job *Job) error {
+	if db.Redis == nil {
+		return errNoRedis
+	}
+	raw, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	err = bury(job.Queue, job.raw, raw)
+	job.raw = raw
+	return err
+}
+
+//从执行中移除 processing, 将 dead 放入死信队列, 死信队列只保留最近 deadLimit 个
+func bury(queue, processing, dead string) error {
+	_, err := db.Redis.TxPipelined(func(pipe db.RedisPipeliner) error {
+		pipe.ZRem(queueKey(queue, "processing"), processing)
+		pipe.HDel(queueKey(queue, "redelivered"), processing)
+		pipe.LPush(queueKey(queue, "dead"), dead)
+		pipe.LTrim(queueKey(queue, "dead"), 0, deadLimit-1)
+		return nil
+	})
+	return err
+}
+
+func (b *RedisBackend) Dead(queue string, limit int64) ([]*Job, error) {
+	if db.Redis == nil {
+		return nil, errNoRedis
+	}
+	if limit <= 0 {
+		limit = deadLimit
+	}
+	list, err := db.Redis.LRange(queueKey(queue, "dead"), 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(list))
+	for _, raw := range list {
+		if job, err := decodeJob(raw); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+func TestRedisBackendMemoryRedis(t *testing.T) {
+	mem := db.NewMemoryRedis()
+	db.Redis = mem
+	defer func() { db.Redis = nil }()
+	b := NewRedisBackend()
+	SetBackend(b)
+
+	first, _ := Enqueue("redis", 1)
+	Enqueue("redis", 2, Delay(5*time.Millisecond))
+
+	job, err := b.Pop("redis", time.Minute)
+	if err != nil || job == nil || job.ID != first.ID {
+		t.Fatalf("Pop = %v, %v, want job %s", job, err, first.ID)
+	}
+	if err := b.Ack(job); err != nil {
+		t.Errorf("Ack error: %v", err)
+	}
+	if job, err := b.Pop("redis", time.Minute); job != nil || err != nil {
+		t.Errorf("Pop before the delayed job is due = %v, %v, want nil", job, err)
+	}
+
+	//延迟任务到期后取出, 不确认时超时重新投递并计入尝试次数
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		job, err = b.Pop("redis", time.Millisecond)
+		if err != nil || job == nil {
+			t.Fatalf("Pop %d = %v, %v", i, job, err)
+		}
+		if job.Attempts != i {
+			t.Errorf("Pop %d attempts = %d, want %d", i, job.Attempts, i)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	//重试后重新投递次数清零, 已记入 job.Attempts
+	job.Attempts++
+	if err := b.Retry(job, time.Now()); err != nil {
+		t.Fatalf("Retry error: %v", err)
+	}
+	if job, err = b.Pop("redis", time.Minute); err != nil || job == nil || job.Attempts != 2 {
+		t.Fatalf("Pop after Retry = %v, %v, want 2 attempts", job, err)
+	}
+	if err := b.Bury(job); err != nil {
+		t.Errorf("Bury error: %v", err)
+	}
+	if n := mem.ZCard(queueKey("redis", "processing")).Val(); n != 0 {
+		t.Errorf("processing = %d, want 0", n)
+	}
+	if n := mem.HLen(queueKey("redis", "redelivered")).Val(); n != 0 {
+		t.Errorf("redelivered = %d, want 0", n)
+	}
+
+	Enqueue("redis", 3)
+	job, _ = b.Pop("redis", time.Minute)
+	b.Bury(job)
+	dead, err := DeadJobs("redis", 0)
+	if err != nil || len(dead) != 2 {
+		t.Fatalf("DeadJobs = %d, %v, want 2", len(dead), err)
+	}
+	if dead, _ := DeadJobs("redis", 1); len(dead) != 1 || dead[0].ID != job.ID {
+		t.Errorf("DeadJobs limit 1 = %v, want the last buried job", dead)
+	}
+}
+
+func TestRedisBackendPopInvalidJob(t *testing.T) {
+	mem := db.NewMemoryRedis()
+	db.Redis = mem
+	defer func() { db.Redis = nil }()
+	b := NewRedisBackend()
+
+	old := make([]interface{}, deadLimit)
+	for i := range old {
+		old[i] = "old"
+	}
+	mem.LPush(queueKey("bad", "dead"), old...)
+	mem.LPush(queueKey("bad", ""), "not json")
+
+	if job, err := b.Pop("bad", time.Minute); job != nil || err == nil {
+		t.Fatalf("Pop invalid job = %v, %v, want error", job, err)
+	}
+	if n := mem.ZCard(queueKey("bad", "processing")).Val(); n != 0 {
+		t.Errorf("processing = %d, want 0", n)
+	}
+	if n := mem.LLen(queueKey("bad", "dead")).Val(); n != deadLimit {
+		t.Errorf("dead = %d, want %d", n, deadLimit)
+	}
+	if raw := mem.LIndex(queueKey("bad", "dead"), 0).Val(); raw != "not json" {
+		t.Errorf("dead[0] = %q, want the invalid job", raw)
+	}
+}
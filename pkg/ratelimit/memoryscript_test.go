@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+//令牌桶脚本的 Go 实现, 只在测试中注册, db.Redis 为 db.NewMemoryRedis() 时使用
+func init() {
+	db.RegisterMemoryScript(tokenBucketScript, memoryTokenBucket)
+}
+
+func memoryTokenBucket(r db.RedisCmdable, keys []string, args []string) (interface{}, error) {
+	capacity, _ := strconv.ParseFloat(args[0], 64)
+	rate, _ := strconv.ParseFloat(args[1], 64)
+	now, _ := strconv.ParseFloat(args[2], 64)
+	cost, _ := strconv.ParseFloat(args[3], 64)
+
+	bucket, err := r.HMGet(keys[0], "tokens", "ts").Result()
+	if err != nil {
+		return nil, err
+	}
+	tokens, err1 := parseFloatReply(bucket[0])
+	ts, err2 := parseFloatReply(bucket[1])
+	if err1 != nil || err2 != nil {
+		tokens, ts = capacity, now
+	}
+	if now > ts {
+		tokens = math.Min(capacity, tokens+(now-ts)*rate)
+		ts = now
+	}
+	var allowed, wait int64
+	if tokens >= cost {
+		tokens -= cost
+		allowed = 1
+	} else {
+		wait = int64(math.Ceil((cost - tokens) / rate))
+	}
+	r.HMSet(keys[0], map[string]interface{}{
+		"tokens": strconv.FormatFloat(tokens, 'g', 14, 64),
+		"ts":     strconv.FormatFloat(ts, 'g', 14, 64),
+	})
+	r.PExpire(keys[0], time.Duration(math.Ceil(capacity/rate)+1000)*time.Millisecond)
+	return []interface{}{allowed, int64(math.Floor(tokens)), wait}, nil
+}
+
+func parseFloatReply(v interface{}) (float64, error) {
+	s, _ := v.(string)
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,245 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+//未连接 redis
+var ErrNoRedis = errors.New("ratelimit: redis not connected")
+
+//令牌桶, 桶中最多 capacity 个令牌, 每毫秒补充 rate 个, 每次请求取 cost 个
+//返回 {是否通过, 剩余令牌数, 需等待的毫秒数}
+var tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+	ts = now
+end
+local allowed = 0
+local wait = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	wait = math.ceil((cost - tokens) / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tokens, "ts", ts)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate) + 1000)
+return {allowed, math.floor(tokens), wait}`
+
+//限流规则, 每 Per 时间 Rate 次, 可连续请求 Burst 次
+type Limit struct {
+	Rate  int
+	Per   time.Duration
+	Burst int
+}
+
+//解析规则, 如 100/m、10/s、1000/h、5/10s, 可加突发数 100/m:150
+func ParseLimit(s string) (Limit, error) {
+	var l Limit
+	s = strings.TrimSpace(s)
+	if idx := strings.LastIndex(s, ":"); idx >= 0 {
+		burst, err := strconv.Atoi(s[idx+1:])
+		if err != nil || burst <= 0 {
+			return l, fmt.Errorf("invalid rate limit: %s", s)
+		}
+		l.Burst, s = burst, s[:idx]
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return l, fmt.Errorf("invalid rate limit: %s", s)
+	}
+	rate, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || rate <= 0 {
+		return l, fmt.Errorf("invalid rate limit: %s", s)
+	}
+	per := strings.TrimSpace(parts[1])
+	switch per {
+	case "s", "m", "h":
+		per = "1" + per
+	case "d":
+		per = "24h"
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return l, fmt.Errorf("invalid rate limit: %s", s)
+	}
+	l.Rate, l.Per = rate, d
+	if l.Burst == 0 {
+		l.Burst = rate
+	}
+	return l, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Rate, l.Per)
+}
+
+//限流结果
+type Result struct {
+	Allowed    bool
+	Limit      Limit
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+//按 key 取一个令牌; 未连接 redis 时返回 ErrNoRedis, redis 出错时返回错误, 结果都为放行, 由调用方决定是否放行
+func Allow(key string, limit Limit) (Result, error) {
+	res := Result{Allowed: true, Limit: limit, Remaining: limit.Burst}
+	if db.Redis == nil {
+		return res, ErrNoRedis
+	}
+	rate := float64(limit.Rate) / float64(limit.Per/time.Millisecond)
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	v, err := db.Redis.Eval(tokenBucketScript, []string{"ratelimit:" + key}, limit.Burst, rate, now, 1).Result()
+	if err != nil {
+		return res, err
+	}
+	values, ok := v.([]interface{})
+	if !ok || len(values) != 3 {
+		return res, fmt.Errorf("ratelimit: unexpected reply %v", v)
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	wait, _ := values[2].(int64)
+	res.Allowed = allowed == 1
+	res.Remaining = int(remaining)
+	res.RetryAfter = time.Duration(wait) * time.Millisecond
+	return res, nil
+}
+
+//限流规则集, 按IP、按用户、按路由
+//按路由的规则分别计数每个已登录用户或IP
+type Rules struct {
+	IP     *Limit
+	User   *Limit
+	Routes map[string]Limit
+
+	//取客户端IP时是否信任 X-Forwarded-For、X-Real-IP, 部署在代理之后时开启
+	TrustProxy bool
+
+	//redis 未连接或出错时是否放行, 不放行时按超出限流处理, 1秒后重试
+	FailOpen bool
+}
+
+//从 [ratelimit] 读取 ip、user、trust_proxy、fail_open(默认 true), routes 为路由规则, 键为 [METHOD]/path
+//	[ratelimit]
+//	ip = 600/m
+//	user = 300/m
+func RulesFromConfig(routes map[string]string) (*Rules, error) {
+	r := &Rules{
+		Routes:     map[string]Limit{},
+		TrustProxy: beego.AppConfig.DefaultBool("ratelimit::trust_proxy", false),
+		FailOpen:   beego.AppConfig.DefaultBool("ratelimit::fail_open", true),
+	}
+	for _, name := range []string{"ip", "user"} {
+		s := beego.AppConfig.String("ratelimit::" + name)
+		if s == "" {
+			continue
+		}
+		l, err := ParseLimit(s)
+		if err != nil {
+			return nil, err
+		}
+		if name == "ip" {
+			r.IP = &l
+		} else {
+			r.User = &l
+		}
+	}
+	for route, s := range routes {
+		l, err := ParseLimit(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", route, err)
+		}
+		r.Routes[route] = l
+	}
+	return r, nil
+}
+
+//依次检查路由、用户、IP 的规则, 返回第一个拒绝的结果, 都通过时返回剩余次数最少的结果
+//user 为 token 验证通过后的用户标识(如用户id), 未登录或 token 无效时为空, 按IP计数
+//不能直接传请求中的 token, 否则每次换一个 token 即可绕过限流; 规则都不适用时 ok 为 false
+func (r *Rules) Check(route, ip, user string) (res Result, ok bool) {
+	client := "ip:" + ip
+	if user != "" {
+		client = "user:" + user
+	}
+
+	check := func(key string, limit Limit) bool {
+		cur, err := Allow(key, limit)
+		if err != nil {
+			beego.Error(fmt.Sprintf("ratelimit %s: %s, fail_open=%v", key, err, r.FailOpen))
+			if r.FailOpen {
+				return true
+			}
+			cur = Result{Limit: limit, RetryAfter: time.Second}
+		}
+		if !ok || !cur.Allowed || cur.Remaining < res.Remaining {
+			res, ok = cur, true
+		}
+		return cur.Allowed
+	}
+
+	if limit, found := r.Routes[route]; found {
+		if !check("route:"+route+":"+client, limit) {
+			return res, true
+		}
+	}
+	if r.User != nil && user != "" {
+		if !check(client, *r.User) {
+			return res, true
+		}
+	}
+	if r.IP != nil {
+		check("ip:"+ip, *r.IP)
+	}
+	return res, ok
+}
+
+//客户端IP, TrustProxy 时取 X-Forwarded-For 的第一个或 X-Real-IP
+func (r *Rules) ClientIP(req *http.Request) string {
+	if r.TrustProxy {
+		if fwd := req.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+		if real := req.Header.Get("X-Real-IP"); real != "" {
+			return strings.TrimSpace(real)
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+//写入限流响应头: X-RateLimit-Limit 次数、X-RateLimit-Window 时间窗口(秒)、X-RateLimit-Remaining, 拒绝时加 Retry-After(秒)
+func SetHeaders(w http.ResponseWriter, res Result) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit.Rate))
+	w.Header().Set("X-RateLimit-Window", strconv.Itoa(int(math.Ceil(res.Limit.Per.Seconds()))))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	if !res.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+	}
+}
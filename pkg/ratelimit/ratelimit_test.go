@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yimishiji/bee/pkg/db"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+		err  bool
+	}{
+		{in: "100/m", want: Limit{Rate: 100, Per: time.Minute, Burst: 100}},
+		{in: "10/s", want: Limit{Rate: 10, Per: time.Second, Burst: 10}},
+		{in: "1000/h", want: Limit{Rate: 1000, Per: time.Hour, Burst: 1000}},
+		{in: "5000/d", want: Limit{Rate: 5000, Per: 24 * time.Hour, Burst: 5000}},
+		{in: "5/10s", want: Limit{Rate: 5, Per: 10 * time.Second, Burst: 5}},
+		{in: " 100 / m:150 ", want: Limit{Rate: 100, Per: time.Minute, Burst: 150}},
+		{in: "100", err: true},
+		{in: "0/m", err: true},
+		{in: "-1/m", err: true},
+		{in: "a/m", err: true},
+		{in: "10/w", err: true},
+		{in: "10/-1s", err: true},
+		{in: "10/m:0", err: true},
+		{in: "10/m:x", err: true},
+		{in: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseLimit(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.2")
+
+	r := &Rules{}
+	if ip := r.ClientIP(req); ip != "10.0.0.1" {
+		t.Errorf("ClientIP without trust_proxy = %s, want 10.0.0.1", ip)
+	}
+	r.TrustProxy = true
+	if ip := r.ClientIP(req); ip != "1.2.3.4" {
+		t.Errorf("ClientIP with trust_proxy = %s, want 1.2.3.4", ip)
+	}
+}
+
+func TestAllowMemoryRedis(t *testing.T) {
+	db.Redis = db.NewMemoryRedis()
+	defer func() { db.Redis = nil }()
+
+	limit := Limit{Rate: 1, Per: time.Minute, Burst: 3}
+	for i := 0; i < 3; i++ {
+		res, err := Allow("test", limit)
+		if err != nil {
+			t.Fatalf("Allow %d error: %v", i, err)
+		}
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Errorf("Allow %d = allowed %v remaining %d, want true %d", i, res.Allowed, res.Remaining, 2-i)
+		}
+	}
+	res, err := Allow("test", limit)
+	if err != nil {
+		t.Fatalf("Allow error: %v", err)
+	}
+	if res.Allowed || res.Remaining != 0 {
+		t.Errorf("Allow after burst = allowed %v remaining %d, want false 0", res.Allowed, res.Remaining)
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %s, want (0, 1m]", res.RetryAfter)
+	}
+	if res, _ := Allow("other", limit); !res.Allowed {
+		t.Errorf("Allow other key denied")
+	}
+}
+
+func TestCheckWithoutRedis(t *testing.T) {
+	limit := Limit{Rate: 10, Per: time.Minute, Burst: 10}
+	if _, err := Allow("test", limit); err != ErrNoRedis {
+		t.Fatalf("Allow without redis error = %v, want ErrNoRedis", err)
+	}
+
+	r := &Rules{IP: &limit, FailOpen: true}
+	if res, ok := r.Check("[GET]/a", "1.2.3.4", ""); ok && !res.Allowed {
+		t.Errorf("Check with fail_open denied")
+	}
+	r.FailOpen = false
+	res, ok := r.Check("[GET]/a", "1.2.3.4", "")
+	if !ok || res.Allowed || res.RetryAfter != time.Second {
+		t.Errorf("Check without fail_open = %+v %v, want denied with retry after 1s", res, ok)
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetHeaders(w, Result{Limit: Limit{Rate: 100, Per: time.Minute, Burst: 150}, Remaining: 0, RetryAfter: 1500 * time.Millisecond})
+	want := map[string]string{
+		"X-RateLimit-Limit":     "100",
+		"X-RateLimit-Window":    "60",
+		"X-RateLimit-Remaining": "0",
+		"Retry-After":           "2",
+	}
+	for k, v := range want {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
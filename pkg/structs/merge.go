@@ -2,10 +2,68 @@ package structs
 
 import (
 	"encoding/json"
+	"reflect"
+	"strings"
 )
 
-//合并结构体数据
+// 可区分"未提交"与"零值"的字段类型，如 types.NullString
+type setter interface {
+	IsSet() bool
+}
+
+//合并结构体数据, 跳过c2中未提交的可为空字段
 func StructMerge(c1 interface{}, c2 interface{}) {
 	json2, _ := json.Marshal(c2)
+
+	if unset := unsetFields(reflect.ValueOf(c2)); len(unset) > 0 {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(json2, &fields); err == nil {
+			for _, name := range unset {
+				delete(fields, name)
+			}
+			json2, _ = json.Marshal(fields)
+		}
+	}
+
 	json.Unmarshal(json2, &c1)
 }
+
+// unsetFields 返回未提交字段的json名
+func unsetFields(v reflect.Value) (names []string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			names = append(names, unsetFields(v.Field(i))...)
+			continue
+		}
+		if !v.Field(i).CanInterface() {
+			continue
+		}
+		if s, ok := v.Field(i).Interface().(setter); ok && !s.IsSet() {
+			if name == "" {
+				name = field.Name
+			}
+			names = append(names, name)
+		}
+	}
+	return
+}
@@ -0,0 +1,405 @@
+package types
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Decimal 定点小数，用于 decimal/numeric/money 字段，避免float64的精度误差
+// 接口中以字符串输出，如 "12.30"，接收字符串或数字
+type Decimal struct {
+	value *big.Int // 不含小数点的整数值
+	scale int32    // 小数位数
+}
+
+var (
+	decimalRegex = regexp.MustCompile(`^([+-]?)([0-9]*)(?:\.([0-9]*))?(?:[eE]([+-]?[0-9]+))?$`)
+	bigTen       = big.NewInt(10)
+)
+
+// MaxDecimalScale 解析时允许的最大小数位数和指数，超出时返回错误，避免 "1e30000000" 之类的输入耗尽CPU和内存
+const MaxDecimalScale = 1000
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// NewDecimal 返回 value * 10^-scale, 如 NewDecimal(1230, 2) = 12.30
+func NewDecimal(value int64, scale int32) Decimal {
+	if scale < 0 {
+		return Decimal{value: new(big.Int).Mul(big.NewInt(value), pow10(-scale))}
+	}
+	return Decimal{value: big.NewInt(value), scale: scale}
+}
+
+// NewDecimalFromInt 整数转为Decimal
+func NewDecimalFromInt(i int64) Decimal {
+	return NewDecimal(i, 0)
+}
+
+// NewDecimalFromFloat 浮点数转为Decimal，取最短的能表示该浮点数的小数
+func NewDecimalFromFloat(f float64) Decimal {
+	d, _ := ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+	return d
+}
+
+// ParseDecimal 解析字符串，如 "-12.30"、"1e-3"；小数位数和指数的绝对值不超过 MaxDecimalScale
+func ParseDecimal(s string) (Decimal, error) {
+	m := decimalRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || m[2]+m[3] == "" {
+		return Decimal{}, fmt.Errorf("types.Decimal: invalid decimal %q", s)
+	}
+	value, ok := new(big.Int).SetString(m[1]+m[2]+m[3], 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("types.Decimal: invalid decimal %q", s)
+	}
+	scale := int64(len(m[3]))
+	if m[4] != "" {
+		exp, err := strconv.ParseInt(m[4], 10, 32)
+		if err != nil || exp > MaxDecimalScale || exp < -MaxDecimalScale {
+			return Decimal{}, fmt.Errorf("types.Decimal: invalid exponent %q", s)
+		}
+		scale -= exp
+	}
+	if scale > MaxDecimalScale || scale < -MaxDecimalScale {
+		return Decimal{}, fmt.Errorf("types.Decimal: scale out of range %q", s)
+	}
+	if scale < 0 {
+		return Decimal{value: value.Mul(value, pow10(int32(-scale)))}, nil
+	}
+	return Decimal{value: value, scale: int32(scale)}, nil
+}
+
+// MustParseDecimal 解析字符串，失败时panic，用于常量
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) unscaled() *big.Int {
+	if d.value == nil {
+		return new(big.Int)
+	}
+	return d.value
+}
+
+// rescale 扩大小数位数，不改变数值
+func (d Decimal) rescale(scale int32) Decimal {
+	if scale <= d.scale {
+		return d
+	}
+	return Decimal{value: new(big.Int).Mul(d.unscaled(), pow10(scale-d.scale)), scale: scale}
+}
+
+func align(d1, d2 Decimal) (Decimal, Decimal) {
+	if d1.scale < d2.scale {
+		return d1.rescale(d2.scale), d2
+	}
+	return d1, d2.rescale(d1.scale)
+}
+
+// Add d + d2
+func (d Decimal) Add(d2 Decimal) Decimal {
+	a, b := align(d, d2)
+	return Decimal{value: new(big.Int).Add(a.unscaled(), b.unscaled()), scale: a.scale}
+}
+
+// Sub d - d2
+func (d Decimal) Sub(d2 Decimal) Decimal {
+	a, b := align(d, d2)
+	return Decimal{value: new(big.Int).Sub(a.unscaled(), b.unscaled()), scale: a.scale}
+}
+
+// Mul d * d2, 小数位数为两者之和
+func (d Decimal) Mul(d2 Decimal) Decimal {
+	return Decimal{value: new(big.Int).Mul(d.unscaled(), d2.unscaled()), scale: d.scale + d2.scale}
+}
+
+// Div d / d2, 结果保留places位小数并四舍五入，d2为0时panic
+func (d Decimal) Div(d2 Decimal, places int32) Decimal {
+	if d2.IsZero() {
+		panic("types.Decimal: division by zero")
+	}
+	if places < 0 {
+		places = 0
+	}
+	num, den := d.unscaled(), d2.unscaled()
+	if e := d2.scale - d.scale + places; e >= 0 {
+		num = new(big.Int).Mul(num, pow10(e))
+	} else {
+		den = new(big.Int).Mul(den, pow10(-e))
+	}
+	return Decimal{value: quoRound(num, den), scale: places}
+}
+
+// quoRound num/den 四舍五入(远离零)
+func quoRound(num, den *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(new(big.Int).Abs(den)) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign()*den.Sign())))
+	}
+	return q
+}
+
+// Round 四舍五入保留places位小数，不足places位时补0
+func (d Decimal) Round(places int32) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return d.rescale(places)
+	}
+	return Decimal{value: quoRound(d.unscaled(), pow10(d.scale-places)), scale: places}
+}
+
+// Truncate 截断保留places位小数
+func (d Decimal) Truncate(places int32) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return d
+	}
+	return Decimal{value: new(big.Int).Quo(d.unscaled(), pow10(d.scale-places)), scale: places}
+}
+
+// Neg -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{value: new(big.Int).Neg(d.unscaled()), scale: d.scale}
+}
+
+// Abs |d|
+func (d Decimal) Abs() Decimal {
+	return Decimal{value: new(big.Int).Abs(d.unscaled()), scale: d.scale}
+}
+
+// Cmp 比较大小，d < d2 返回-1，相等返回0，d > d2 返回1
+func (d Decimal) Cmp(d2 Decimal) int {
+	a, b := align(d, d2)
+	return a.unscaled().Cmp(b.unscaled())
+}
+
+// Equal 数值是否相等，12.3 与 12.30 相等
+func (d Decimal) Equal(d2 Decimal) bool {
+	return d.Cmp(d2) == 0
+}
+
+// GreaterThan d > d2
+func (d Decimal) GreaterThan(d2 Decimal) bool {
+	return d.Cmp(d2) > 0
+}
+
+// LessThan d < d2
+func (d Decimal) LessThan(d2 Decimal) bool {
+	return d.Cmp(d2) < 0
+}
+
+// Sign 负数返回-1，0返回0，正数返回1
+func (d Decimal) Sign() int {
+	return d.unscaled().Sign()
+}
+
+// IsZero 是否为0
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Scale 小数位数
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// FitsPrecision 是否可以存入 decimal(digits, decimals) 字段而不丢失精度
+func (d Decimal) FitsPrecision(digits, decimals int) bool {
+	// 去掉小数末尾的0
+	v, scale := new(big.Int).Set(d.unscaled()), d.scale
+	r := new(big.Int)
+	for scale > 0 {
+		q, _ := new(big.Int).QuoRem(v, bigTen, r)
+		if r.Sign() != 0 {
+			break
+		}
+		v, scale = q, scale-1
+	}
+	if int(scale) > decimals {
+		return false
+	}
+	intPart := new(big.Int).Abs(new(big.Int).Quo(v, pow10(scale)))
+	intDigits := 0
+	if intPart.Sign() != 0 {
+		intDigits = len(intPart.String())
+	}
+	return intDigits <= digits-decimals
+}
+
+// IntPart 整数部分
+func (d Decimal) IntPart() int64 {
+	return d.Truncate(0).unscaled().Int64()
+}
+
+// Float64 转为float64，可能丢失精度，仅用于展示或统计
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String 不使用科学计数法的字符串，保留全部小数位，如 "12.30"
+func (d Decimal) String() string {
+	v := d.unscaled()
+	if d.scale <= 0 {
+		return v.String()
+	}
+	s := new(big.Int).Abs(v).String()
+	if len(s) <= int(d.scale) {
+		s = strings.Repeat("0", int(d.scale)-len(s)+1) + s
+	}
+	sign := ""
+	if v.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + s[:len(s)-int(d.scale)] + "." + s[len(s)-int(d.scale):]
+}
+
+// StringFixed 四舍五入保留places位小数的字符串
+func (d Decimal) StringFixed(places int32) string {
+	return d.Round(places).String()
+}
+
+// Scan 实现 sql.Scanner
+func (d *Decimal) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Decimal{}
+	case []byte:
+		return d.scanString(string(v))
+	case string:
+		return d.scanString(v)
+	case int64:
+		*d = NewDecimalFromInt(v)
+	case float64:
+		*d = NewDecimalFromFloat(v)
+	default:
+		return fmt.Errorf("types.Decimal: cannot scan %T", src)
+	}
+	return nil
+}
+
+// scanString 数据库中的字符串，postgres 的 money 类型带货币符号和千分位，如 "-$1,234.56"、"($1.00)"
+func (d *Decimal) scanString(s string) error {
+	if err := d.parse(s); err == nil {
+		return nil
+	}
+	return d.parse(stripMoney(s))
+}
+
+// stripMoney 去掉货币符号、千分位和空格，括号表示负数；小数点需为 "."
+func stripMoney(s string) string {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	if neg {
+		s = s[1 : len(s)-1]
+	}
+	s = strings.Map(func(r rune) rune {
+		if r == ',' || unicode.IsSpace(r) || unicode.Is(unicode.Sc, r) {
+			return -1
+		}
+		return r
+	}, s)
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+func (d *Decimal) parse(s string) error {
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Value 实现 driver.Valuer，以字符串写入避免精度丢失
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// MarshalJSON 输出为字符串
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON 接收字符串或数字
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, jsonNull) {
+		return nil
+	}
+	return d.parse(string(bytes.Trim(data, `"`)))
+}
+
+// NullDecimal 可为空的定点小数
+type NullDecimal struct {
+	Decimal
+	Valid bool
+	Set   bool `json:"-"`
+}
+
+// NewNullDecimal 非空定点小数
+func NewNullDecimal(d Decimal) NullDecimal {
+	return NullDecimal{Decimal: d, Valid: true, Set: true}
+}
+
+// IsSet json中是否出现了该字段
+func (n NullDecimal) IsSet() bool {
+	return n.Set
+}
+
+// Scan 实现 sql.Scanner
+func (n *NullDecimal) Scan(src interface{}) error {
+	if src == nil {
+		n.Decimal, n.Valid = Decimal{}, false
+		return nil
+	}
+	n.Valid = true
+	return n.Decimal.Scan(src)
+}
+
+// Value 实现 driver.Valuer
+func (n NullDecimal) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Decimal.Value()
+}
+
+// MarshalJSON 空值输出null
+func (n NullDecimal) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return jsonNull, nil
+	}
+	return n.Decimal.MarshalJSON()
+}
+
+// UnmarshalJSON 接收null、字符串或数字，空字符串视为null
+func (n *NullDecimal) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if bytes.Equal(data, jsonNull) || bytes.Equal(data, []byte(`""`)) {
+		n.Decimal, n.Valid = Decimal{}, false
+		return nil
+	}
+	if err := n.Decimal.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
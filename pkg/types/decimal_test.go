@@ -0,0 +1,85 @@
+package types
+
+import "testing"
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  string
+		scale int32
+		err   bool
+	}{
+		{in: "12.30", want: "12.30", scale: 2},
+		{in: "-0.5", want: "-0.5", scale: 1},
+		{in: "+7", want: "7"},
+		{in: " 3 ", want: "3"},
+		{in: ".5", want: "0.5", scale: 1},
+		{in: "5.", want: "5"},
+		{in: "1e-3", want: "0.001", scale: 3},
+		{in: "1.5E2", want: "150"},
+		{in: "1e1000", want: "1" + zeros(1000)},
+		{in: "", err: true},
+		{in: ".", err: true},
+		{in: "abc", err: true},
+		{in: "1.2.3", err: true},
+		{in: "1e", err: true},
+		{in: "1e1001", err: true},
+		{in: "1e30000000", err: true},
+		{in: "1e-2147483648", err: true},
+		{in: "1e99999999999", err: true},
+		{in: "0." + zeros(1001), err: true},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseDecimal(%q) = %s, want error", tt.in, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDecimal(%q) error: %v", tt.in, err)
+			continue
+		}
+		if d.String() != tt.want || d.scale != tt.scale {
+			t.Errorf("ParseDecimal(%q) = %s (scale %d), want %s (scale %d)", tt.in, d, d.scale, tt.want, tt.scale)
+		}
+	}
+}
+
+func TestDecimalScanMoney(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{"$1,234.56", "1234.56"},
+		{"-$1,234.56", "-1234.56"},
+		{"($1.00)", "-1.00"},
+		{[]byte("€ 12.30"), "12.30"},
+		{"99.5", "99.5"},
+		{int64(3), "3"},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		if err := d.Scan(tt.in); err != nil {
+			t.Errorf("Scan(%v) error: %v", tt.in, err)
+			continue
+		}
+		if d.String() != tt.want {
+			t.Errorf("Scan(%v) = %s, want %s", tt.in, d, tt.want)
+		}
+	}
+
+	var d Decimal
+	if err := d.Scan("$abc"); err == nil {
+		t.Errorf("Scan(%q) want error", "$abc")
+	}
+}
+
+func zeros(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = '0'
+	}
+	return string(b)
+}
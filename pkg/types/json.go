@@ -0,0 +1,119 @@
+package types
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var jsonNull = []byte("null")
+
+// JSON 数据库json/jsonb字段类型，接口输出原始json而不是转义后的字符串
+type JSON json.RawMessage
+
+// NewJSON 将任意值编码为JSON
+func NewJSON(v interface{}) (JSON, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return JSON(data), nil
+}
+
+// MarshalJSON 原样输出，空值输出null
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return jsonNull, nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON 保存原始json
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	if j == nil {
+		return errors.New("types.JSON: UnmarshalJSON on nil pointer")
+	}
+	*j = append((*j)[0:0], data...)
+	return nil
+}
+
+// Value 实现 driver.Valuer，写入数据库
+func (j JSON) Value() (driver.Value, error) {
+	if j.IsNull() {
+		return nil, nil
+	}
+	if !json.Valid(j) {
+		return nil, fmt.Errorf("types.JSON: invalid json %q", string(j))
+	}
+	return string(j), nil
+}
+
+// Scan 实现 sql.Scanner，从数据库读取
+func (j *JSON) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[0:0], v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return fmt.Errorf("types.JSON: cannot scan %T", src)
+	}
+	return nil
+}
+
+// IsNull 是否为空或json null
+func (j JSON) IsNull() bool {
+	return len(j) == 0 || bytes.Equal(j, jsonNull)
+}
+
+// Unmarshal 解码到指定结构
+func (j JSON) Unmarshal(v interface{}) error {
+	if j.IsNull() {
+		return nil
+	}
+	return json.Unmarshal(j, v)
+}
+
+// IsSet json中是否出现了该字段
+func (j JSON) IsSet() bool {
+	return len(j) > 0
+}
+
+// String 返回json字符串
+func (j JSON) String() string {
+	return string(j)
+}
+
+// ScanJSON 自定义json字段类型的 Scan 实现，
+// 例：func (e *OrderExtra) Scan(src interface{}) error { return types.ScanJSON(src, e) }
+func ScanJSON(src interface{}, dest interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		if len(v) == 0 {
+			return nil
+		}
+		return json.Unmarshal(v, dest)
+	case string:
+		if v == "" {
+			return nil
+		}
+		return json.Unmarshal([]byte(v), dest)
+	}
+	return fmt.Errorf("types: cannot scan %T into %T", src, dest)
+}
+
+// JSONValue 自定义json字段类型的 Value 实现，
+// 例：func (e OrderExtra) Value() (driver.Value, error) { return types.JSONValue(e) }
+func JSONValue(v interface{}) (driver.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,338 @@
+package types
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// 可为空字段类型
+// Valid 为false时写入数据库NULL，接口输出null；
+// Set 记录json中是否出现了该字段，用于区分"未提交"与"提交了null"，
+// structs.StructMerge 合并时会跳过未提交的字段
+
+// NullString 可为空字符串
+type NullString struct {
+	sql.NullString
+	Set bool `json:"-"`
+}
+
+// NewNullString 非空字符串
+func NewNullString(s string) NullString {
+	return NullString{NullString: sql.NullString{String: s, Valid: true}, Set: true}
+}
+
+// IsSet json中是否出现了该字段
+func (n NullString) IsSet() bool {
+	return n.Set
+}
+
+// MarshalJSON 空值输出null
+func (n NullString) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return jsonNull, nil
+	}
+	return json.Marshal(n.String)
+}
+
+// UnmarshalJSON 接收null或字符串
+func (n *NullString) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if bytes.Equal(data, jsonNull) {
+		n.String, n.Valid = "", false
+		return nil
+	}
+	if err := json.Unmarshal(data, &n.String); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// NullInt64 可为空整数，用于所有可为空的整型字段
+type NullInt64 struct {
+	sql.NullInt64
+	Set bool `json:"-"`
+}
+
+// NewNullInt64 非空整数
+func NewNullInt64(i int64) NullInt64 {
+	return NullInt64{NullInt64: sql.NullInt64{Int64: i, Valid: true}, Set: true}
+}
+
+// IsSet json中是否出现了该字段
+func (n NullInt64) IsSet() bool {
+	return n.Set
+}
+
+// MarshalJSON 空值输出null
+func (n NullInt64) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return jsonNull, nil
+	}
+	return []byte(strconv.FormatInt(n.Int64, 10)), nil
+}
+
+// UnmarshalJSON 接收null、数字或数字字符串
+func (n *NullInt64) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if bytes.Equal(data, jsonNull) {
+		n.Int64, n.Valid = 0, false
+		return nil
+	}
+	i, err := strconv.ParseInt(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("types.NullInt64: invalid value %s", data)
+	}
+	n.Int64, n.Valid = i, true
+	return nil
+}
+
+// NullUint64 可为空无符号整数，用于 bigint unsigned 等超出 int64 范围的字段
+type NullUint64 struct {
+	Uint64 uint64
+	Valid  bool
+	Set    bool `json:"-"`
+}
+
+// NewNullUint64 非空无符号整数
+func NewNullUint64(i uint64) NullUint64 {
+	return NullUint64{Uint64: i, Valid: true, Set: true}
+}
+
+// IsSet json中是否出现了该字段
+func (n NullUint64) IsSet() bool {
+	return n.Set
+}
+
+// Scan 实现 sql.Scanner
+func (n *NullUint64) Scan(src interface{}) error {
+	n.Uint64, n.Valid = 0, false
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case uint64:
+		n.Uint64, n.Valid = v, true
+		return nil
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("types.NullUint64: cannot scan negative %d", v)
+		}
+		n.Uint64, n.Valid = uint64(v), true
+		return nil
+	case []byte:
+		return n.parse(string(v))
+	case string:
+		return n.parse(v)
+	}
+	return fmt.Errorf("types.NullUint64: cannot scan %T", src)
+}
+
+func (n *NullUint64) parse(s string) error {
+	i, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("types.NullUint64: cannot parse %q", s)
+	}
+	n.Uint64, n.Valid = i, true
+	return nil
+}
+
+// Value 实现 driver.Valuer, 超出 int64 的值以字符串写入
+func (n NullUint64) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	if n.Uint64 > math.MaxInt64 {
+		return strconv.FormatUint(n.Uint64, 10), nil
+	}
+	return int64(n.Uint64), nil
+}
+
+// MarshalJSON 空值输出null
+func (n NullUint64) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return jsonNull, nil
+	}
+	return []byte(strconv.FormatUint(n.Uint64, 10)), nil
+}
+
+// UnmarshalJSON 接收null、数字或数字字符串
+func (n *NullUint64) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if bytes.Equal(data, jsonNull) {
+		n.Uint64, n.Valid = 0, false
+		return nil
+	}
+	if err := n.parse(string(bytes.Trim(data, `"`))); err != nil {
+		return fmt.Errorf("types.NullUint64: invalid value %s", data)
+	}
+	return nil
+}
+
+// NullFloat64 可为空浮点数
+type NullFloat64 struct {
+	sql.NullFloat64
+	Set bool `json:"-"`
+}
+
+// NewNullFloat64 非空浮点数
+func NewNullFloat64(f float64) NullFloat64 {
+	return NullFloat64{NullFloat64: sql.NullFloat64{Float64: f, Valid: true}, Set: true}
+}
+
+// IsSet json中是否出现了该字段
+func (n NullFloat64) IsSet() bool {
+	return n.Set
+}
+
+// MarshalJSON 空值输出null
+func (n NullFloat64) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return jsonNull, nil
+	}
+	return json.Marshal(n.Float64)
+}
+
+// UnmarshalJSON 接收null、数字或数字字符串
+func (n *NullFloat64) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if bytes.Equal(data, jsonNull) {
+		n.Float64, n.Valid = 0, false
+		return nil
+	}
+	f, err := strconv.ParseFloat(string(bytes.Trim(data, `"`)), 64)
+	if err != nil {
+		return fmt.Errorf("types.NullFloat64: invalid value %s", data)
+	}
+	n.Float64, n.Valid = f, true
+	return nil
+}
+
+// NullBool 可为空布尔值
+type NullBool struct {
+	sql.NullBool
+	Set bool `json:"-"`
+}
+
+// NewNullBool 非空布尔值
+func NewNullBool(b bool) NullBool {
+	return NullBool{NullBool: sql.NullBool{Bool: b, Valid: true}, Set: true}
+}
+
+// IsSet json中是否出现了该字段
+func (n NullBool) IsSet() bool {
+	return n.Set
+}
+
+// MarshalJSON 空值输出null
+func (n NullBool) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return jsonNull, nil
+	}
+	return json.Marshal(n.Bool)
+}
+
+// UnmarshalJSON 接收null或布尔值
+func (n *NullBool) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if bytes.Equal(data, jsonNull) {
+		n.Bool, n.Valid = false, false
+		return nil
+	}
+	if err := json.Unmarshal(data, &n.Bool); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// NullTime 可为空时间
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+	Set   bool `json:"-"`
+}
+
+// NewNullTime 非空时间
+func NewNullTime(t time.Time) NullTime {
+	return NullTime{Time: t, Valid: true, Set: true}
+}
+
+// IsSet json中是否出现了该字段
+func (n NullTime) IsSet() bool {
+	return n.Set
+}
+
+var nullTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"15:04:05",
+	time.RFC3339Nano,
+}
+
+// Scan 实现 sql.Scanner
+func (n *NullTime) Scan(src interface{}) error {
+	n.Time, n.Valid = time.Time{}, false
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		n.Time, n.Valid = v, true
+		return nil
+	case []byte:
+		return n.parse(string(v))
+	case string:
+		return n.parse(v)
+	}
+	return fmt.Errorf("types.NullTime: cannot scan %T", src)
+}
+
+func (n *NullTime) parse(s string) error {
+	for _, layout := range nullTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			n.Time, n.Valid = t, true
+			return nil
+		}
+	}
+	return fmt.Errorf("types.NullTime: cannot parse %q", s)
+}
+
+// Value 实现 driver.Valuer
+func (n NullTime) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Time, nil
+}
+
+// MarshalJSON 空值输出null
+func (n NullTime) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return jsonNull, nil
+	}
+	return n.Time.MarshalJSON()
+}
+
+// UnmarshalJSON 接收null、RFC3339时间或 2006-01-02 15:04:05 格式
+func (n *NullTime) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if bytes.Equal(data, jsonNull) {
+		n.Time, n.Valid = time.Time{}, false
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		n.Time, n.Valid = time.Time{}, false
+		return nil
+	}
+	return n.parse(s)
+}
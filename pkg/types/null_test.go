@@ -0,0 +1,50 @@
+package types
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestNullUint64(t *testing.T) {
+	var n NullUint64
+	for _, src := range []interface{}{uint64(math.MaxUint64), []byte("18446744073709551615"), "18446744073709551615"} {
+		if err := n.Scan(src); err != nil || !n.Valid || n.Uint64 != math.MaxUint64 {
+			t.Errorf("Scan(%v) = %v, %v", src, n, err)
+		}
+	}
+	if err := n.Scan(int64(-1)); err == nil {
+		t.Errorf("Scan(-1) want error")
+	}
+	if err := n.Scan(nil); err != nil || n.Valid {
+		t.Errorf("Scan(nil) = %v, %v, want invalid", n, err)
+	}
+
+	if v, _ := NewNullUint64(math.MaxUint64).Value(); v != "18446744073709551615" {
+		t.Errorf("Value above MaxInt64 = %#v, want string", v)
+	}
+	if v, _ := NewNullUint64(7).Value(); v != int64(7) {
+		t.Errorf("Value = %#v, want int64 7", v)
+	}
+	if v, _ := (NullUint64{}).Value(); v != nil {
+		t.Errorf("Value of null = %#v, want nil", v)
+	}
+
+	data, _ := json.Marshal(NewNullUint64(math.MaxUint64))
+	if string(data) != "18446744073709551615" {
+		t.Errorf("MarshalJSON = %s", data)
+	}
+	for _, in := range []string{`18446744073709551615`, `"18446744073709551615"`} {
+		var u NullUint64
+		if err := json.Unmarshal([]byte(in), &u); err != nil || !u.Set || u.Uint64 != math.MaxUint64 {
+			t.Errorf("UnmarshalJSON(%s) = %v, %v", in, u, err)
+		}
+	}
+	var u NullUint64
+	if err := json.Unmarshal([]byte(`null`), &u); err != nil || !u.Set || u.Valid {
+		t.Errorf("UnmarshalJSON(null) = %v, %v", u, err)
+	}
+	if err := json.Unmarshal([]byte(`-1`), &u); err == nil {
+		t.Errorf("UnmarshalJSON(-1) want error")
+	}
+}